@@ -21,7 +21,7 @@ func callLLM(ctx context.Context, messages []any) (string, error) {
 		return "", err // buildRequest已经处理了错误日志
 	}
 
-	aiCfg := config.Config.AI
+	aiCfg := config.Get().AI
 	fullModelName := aiCfg.Provider + "/" + aiCfg.Providers[aiCfg.Provider].Model
 
 	start := time.Now()
@@ -11,7 +11,7 @@ import (
 
 // buildRequest 构造 OpenAI 兼容的 HTTP 请求
 func buildRequest(ctx context.Context, messages any) (*http.Request, error) {
-	cfg := &config.Config.AI
+	cfg := &config.Get().AI
 	if cfg == nil || !cfg.Enable || cfg.Provider == "" {
 		err := libs.NewError(libs.ErrCodeConfigInvalid, "AI 配置不完整或未启用")
 		libs.LogError(err, "AI请求构建")
@@ -134,14 +134,14 @@ func CallWeatherTool(ctx context.Context, args map[string]any) (string, error) {
 	}
 
 	// 配置验证
-	if config.Config == nil {
+	if config.Get() == nil {
 		err := libs.NewError(libs.ErrCodeConfigNotFound, "系统配置未初始化")
 		libs.LogError(err, "天气工具调用")
 		return "", err
 	}
 
-	apiHost := config.Config.Weather.ApiHost
-	apiKey := config.Config.Weather.ApiKey
+	apiHost := config.Get().Weather.ApiHost
+	apiKey := config.Get().Weather.ApiKey
 
 	if apiHost == "" || apiKey == "" {
 		err := libs.NewError(libs.ErrCodeConfigInvalid, "天气API主机或密钥未配置")
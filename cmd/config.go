@@ -0,0 +1,123 @@
+// Package cmd @Author lanpang
+// @Date 2025/8/11 下午2:00:00
+// @Desc 配置文件管理
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"vhagar/config"
+	"vhagar/libs"
+	"vhagar/task"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "配置文件管理",
+	Long:  `检查配置文件，加密配置中的敏感信息`,
+	// 配置文件可能无法解析，不走默认的加载流程
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		libs.InitLoggerWithConfig("warn", false)
+		return resolveConfigFile()
+	},
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "校验配置文件",
+	Long: `按已开启的定时任务和服务检查必填项、cron 表达式、URL、AI 服务商配置和域名列表文件，
+并提示未知的配置项，问题定位到行号。存在错误时退出码为 1`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, issues, err := config.ValidateFile(cfgFile, taskNames())
+		if err != nil {
+			cmd.PrintErrln("读取配置文件失败:", err)
+			os.Exit(1)
+		}
+		name := filepath.Base(cfgFile)
+		var errCount, warnCount int
+		for _, issue := range issues {
+			level := "\033[33mwarning\033[0m"
+			if issue.Level == config.LevelError {
+				level = "\033[31merror\033[0m"
+				errCount++
+			} else {
+				warnCount++
+			}
+			fmt.Printf("%s:%d: %s: %s\n", name, issue.Line, level, issue)
+		}
+		if len(issues) == 0 {
+			fmt.Printf("%s: 配置校验通过\n", name)
+			return
+		}
+		fmt.Printf("\n%d 个错误，%d 个警告\n", errCount, warnCount)
+		if config.HasError(issues) {
+			os.Exit(1)
+		}
+	},
+}
+
+var keyFile string
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt [value]",
+	Short: "加密敏感配置",
+	Long: `使用本地密钥加密密码、机器人 key 等敏感信息，输出 enc: 开头的值，可直接写入配置文件。
+未指定 value 时从标准输入读取，避免明文留在 shell 历史中。
+密钥优先取环境变量 WSCTL_SECRET_KEY，其次为密钥文件（默认配置文件同目录下的 secret.key），
+密钥文件不存在时自动生成，请妥善保管，不要提交到代码仓库`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if keyFile == "" {
+			keyFile = config.SecretKeyFile(cfgFile)
+		}
+		key, created, err := config.EnsureSecretKey(keyFile)
+		if err != nil {
+			cmd.PrintErrln("读取密钥失败:", err)
+			os.Exit(1)
+		}
+		if created {
+			cmd.PrintErrln("已生成密钥文件", keyFile)
+		}
+		var value string
+		if len(args) > 0 {
+			value = args[0]
+		} else {
+			cmd.PrintErr("请输入要加密的内容: ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				cmd.PrintErrln("读取输入失败:", err)
+				os.Exit(1)
+			}
+			value = strings.TrimRight(line, "\r\n")
+		}
+		encrypted, err := config.Encrypt(key, value)
+		if err != nil {
+			cmd.PrintErrln("加密失败:", err)
+			os.Exit(1)
+		}
+		fmt.Println(encrypted)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(validateCmd)
+	configCmd.AddCommand(encryptCmd)
+	encryptCmd.Flags().StringVar(&keyFile, "key-file", "", "密钥文件，默认为配置文件同目录下的 secret.key")
+}
+
+// taskNames 已注册的任务名
+func taskNames() []string {
+	names := make([]string, 0, len(task.Creators))
+	for name := range task.Creators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -4,9 +4,14 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
 	"vhagar/config"
 	"vhagar/libs"
 	"vhagar/metric"
+	"vhagar/notify"
 	"vhagar/task"
 
 	"github.com/robfig/cron/v3"
@@ -17,16 +22,31 @@ var crontabCmd = &cobra.Command{
 	Use:   "cron",
 	Short: "启动定时任务",
 	Long: `可自定义周期性运行 task
-相关配置见配置文件的 [crontab]
+相关配置见配置文件的 [crontab]，收到 SIGHUP 时重新加载配置
 `,
 	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signalContext()
+		defer stop()
+		// 重新加载后调度器按 [cron] 增删任务，metrics 采集器按新配置重启
+		watchHangup(ctx, reloadConfig)
+		var wg sync.WaitGroup
 		// 启动 metric 服务
-		if config.Config.Metric.Enable {
-			go metric.StartMetric()
+		if config.Get().Metric.Enable {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := metric.StartMetric(ctx); err != nil {
+					libs.Logger.Errorw("metrics 服务异常退出", "err", err)
+				}
+			}()
 		}
 		// 启动定时任务
 		libs.Logger.Warnw("启动任务调度")
-		crontabJob()
+		if err := crontabJob(ctx); err != nil {
+			libs.Logger.Fatalw("启动任务调度失败", "err", err)
+		}
+		wg.Wait()
+		notify.Flush()
 	},
 }
 
@@ -34,28 +54,98 @@ func init() {
 	rootCmd.AddCommand(crontabCmd)
 }
 
-func crontabJob() {
-	c := cron.New() //创建一个cron实例
-	// 获取等待时间
-	duration := config.GetRandomDuration()
-	config.Config.Global.Duration = duration
-	config.Config.Global.Report = true
-	cronCfg := config.Config.Cron
-	// 添加任务
-	for name, cronJob := range cronCfg {
+// scheduledJob 已添加到调度器的定时任务
+type scheduledJob struct {
+	spec string
+	id   cron.EntryID
+}
+
+// scheduler 定时任务调度，配置重新加载后按 [cron] 增删任务
+type scheduler struct {
+	mu   sync.Mutex
+	cron *cron.Cron
+	jobs map[string]scheduledJob
+}
+
+// crontabJob 按配置添加定时任务并运行，ctx 取消后等待正在运行的任务结束再返回
+func crontabJob(ctx context.Context) error {
+	// 报告在投递队列中错峰延后发送，不阻塞任务
+	notify.EnableSpread()
+	s := &scheduler{cron: cron.New(), jobs: map[string]scheduledJob{}}
+	if err := s.sync(); err != nil {
+		return err
+	}
+	// 定期补发断网期间未送达的报告
+	if _, err := s.cron.AddFunc("@every 5m", notify.ReplayOutbox); err != nil {
+		return fmt.Errorf("添加补发任务失败: %w", err)
+	}
+	cancel := config.OnReload(func() {
+		if err := s.sync(); err != nil {
+			libs.Logger.Errorw("更新定时任务失败", "err", err)
+		}
+	})
+	defer cancel()
+	s.cron.Start()
+	libs.Ready(ctx)
+	<-ctx.Done()
+	libs.Logger.Warnw("正在停止任务调度")
+	<-s.cron.Stop().Done()
+	return nil
+}
+
+// sync 使调度器中的任务与当前配置一致：新增、删除或更新表达式变化的任务
+func (s *scheduler) sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 汇总模式下参与汇总的任务报告暂存，窗口结束后合并发送
+	notify.SetHeld(getDigestTasks()...)
+	for name, job := range s.jobs {
+		cronJob, ok := config.Get().Cron[name]
+		if ok && cronJob.Crontab && cronJob.Scheducron == job.spec {
+			continue
+		}
+		libs.Logger.Warnw("移除定时任务", "task", name)
+		s.cron.Remove(job.id)
+		delete(s.jobs, name)
+	}
+	for name, cronJob := range config.Get().Cron {
 		// 判断是否是定时任务
+		if !cronJob.Crontab {
+			continue
+		}
+		if _, ok := s.jobs[name]; ok {
+			continue
+		}
 		taskName := name
-		if cronJob.Crontab {
-			libs.Logger.Warnw("添加定时任务", "task", taskName)
-			_, err := c.AddFunc(cronJob.Scheducron, func() {
-				task.Do(taskName)
-			})
-			if err != nil {
-				libs.Logger.Fatalw("添加定时任务失败", "err", err)
+		libs.Logger.Warnw("添加定时任务", "task", taskName, "cron", cronJob.Scheducron)
+		id, err := s.cron.AddFunc(cronJob.Scheducron, func() {
+			result := task.Run(taskName, task.RunOptions{Report: true, Scheduled: true})
+			if notify.IsHeld(taskName) {
+				task.AddToDigest(result)
 			}
+		})
+		if err != nil {
+			return fmt.Errorf("添加定时任务 %s 失败: %w", taskName, err)
+		}
+		s.jobs[name] = scheduledJob{spec: cronJob.Scheducron, id: id}
+	}
+	return nil
+}
+
+// getDigestTasks 返回参与汇总的定时任务，未开启汇总时为空
+func getDigestTasks() []string {
+	cfg := config.Get().Digest
+	if !cfg.Enable {
+		return nil
+	}
+	var tasks []string
+	for name, cronJob := range config.Get().Cron {
+		if !cronJob.Crontab {
+			continue
+		}
+		if len(cfg.Tasks) == 0 || slices.Contains(cfg.Tasks, name) {
+			tasks = append(tasks, name)
 		}
 	}
-	//启动/关闭
-	c.Run()
-	defer c.Stop()
+	return tasks
 }
@@ -0,0 +1,643 @@
+// Package cmd @Author lanpang
+// @Date 2025/8/14 上午10:00:00
+// @Desc 交互式生成配置文件：填写项目、中间件和机器人，测试连接后写入带注释的配置
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+)
+
+// 单个连接测试的超时时间，部分客户端没有连接超时
+const connTestTimeout = 10 * time.Second
+
+var forceInit bool
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "交互式生成配置文件",
+	Long: `依次填写项目名称、PG、ES、Doris、Redis 地址和企微机器人，测试各中间件连接后
+生成带注释的配置文件（-c 指定路径，默认 config.toml）。中间件地址留空则跳过。
+其他配置（nacos、rocketmq、metric、ai 等）参考仓库中的 config.toml 示例`,
+	// 配置文件还不存在，不走默认的加载流程
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return resolveConfigFile()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := os.Stat(cfgFile); err == nil && !forceInit {
+			cmd.PrintErrf("配置文件 %s 已存在，使用 --force 覆盖\n", cfgFile)
+			os.Exit(1)
+		}
+		p := tea.NewProgram(newWizardModel())
+		final, err := p.Run()
+		if err != nil {
+			fmt.Println("出错:", err)
+			os.Exit(1)
+		}
+		m := final.(wizardModel)
+		if m.state != wizardWrite {
+			fmt.Println("已取消，未写入配置文件")
+			return
+		}
+		if err := writeInitConfig(m); err != nil {
+			cmd.PrintErrln("写入配置文件失败:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("已生成配置文件 %s，可运行 wsctl config validate 检查\n", cfgFile)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().BoolVar(&forceInit, "force", false, "覆盖已存在的配置文件")
+}
+
+// wizardField 向导中的一个输入项，section 的 ip/addr 留空时跳过该段其余输入项
+type wizardField struct {
+	key      string
+	label    string
+	value    string // 默认值
+	password bool
+	yesNo    bool
+}
+
+var wizardFields = []wizardField{
+	{key: "projectname", label: "项目名称"},
+	{key: "corp", label: "租户 corpid，多个用逗号分隔，可留空"},
+	{key: "pg.ip", label: "PG 地址，留空跳过"},
+	{key: "pg.port", label: "PG 端口", value: "5432"},
+	{key: "pg.username", label: "PG 用户名", value: "postgres"},
+	{key: "pg.password", label: "PG 密码", password: true},
+	{key: "es.ip", label: "ES 地址，留空跳过"},
+	{key: "es.port", label: "ES 端口", value: "9200"},
+	{key: "es.username", label: "ES 用户名", value: "elastic"},
+	{key: "es.password", label: "ES 密码", password: true},
+	{key: "es.sslmode", label: "ES 是否使用 https (y/N)", yesNo: true},
+	{key: "doris.ip", label: "Doris FE 地址，留空跳过"},
+	{key: "doris.port", label: "Doris 查询端口", value: "9030"},
+	{key: "doris.username", label: "Doris 用户名", value: "root"},
+	{key: "doris.password", label: "Doris 密码", password: true},
+	{key: "doris.httpport", label: "Doris HTTP 端口", value: "8030"},
+	{key: "redis.addr", label: "Redis 地址 host:port，留空跳过"},
+	{key: "redis.password", label: "Redis 密码", password: true},
+	{key: "notify.robotkey", label: "企微机器人 key，多个用逗号分隔"},
+	{key: "notify.userlist", label: "告警@人，多个用逗号分隔，可留空"},
+	{key: "encrypt", label: "密码和机器人 key 是否加密写入 (y/N)", yesNo: true},
+}
+
+// 向导状态
+const (
+	wizardInput = iota
+	wizardTesting
+	wizardConfirm
+	wizardWrite
+	wizardQuit
+)
+
+// connTest 连接测试结果
+type connTest struct {
+	name string
+	done bool
+	err  error
+}
+
+type connTestMsg struct {
+	name string
+	err  error
+}
+
+type wizardModel struct {
+	state     int
+	index     int
+	values    map[string]string
+	textInput textinput.Model
+	errMsg    string
+	tests     []connTest
+}
+
+func newWizardModel() wizardModel {
+	ti := textinput.New()
+	ti.Focus()
+	ti.CharLimit = 256
+	ti.Width = 50
+	m := wizardModel{values: map[string]string{}, textInput: ti}
+	m.showField()
+	return m
+}
+
+// showField 切换输入框到当前输入项，已填写过的值优先于默认值
+func (m *wizardModel) showField() {
+	field := wizardFields[m.index]
+	m.textInput.Reset()
+	m.textInput.Placeholder = field.value
+	m.textInput.EchoMode = textinput.EchoNormal
+	if field.password {
+		m.textInput.EchoMode = textinput.EchoPassword
+	}
+	if value, ok := m.values[field.key]; ok {
+		m.textInput.SetValue(value)
+	} else {
+		m.textInput.SetValue(field.value)
+	}
+	m.textInput.CursorEnd()
+}
+
+// skipped 所在中间件未填写地址时跳过
+func (m wizardModel) skipped(index int) bool {
+	key := wizardFields[index].key
+	section, name, ok := strings.Cut(key, ".")
+	if !ok || section == "notify" || name == "ip" || name == "addr" {
+		return false
+	}
+	return !m.enabled(section)
+}
+
+func (m wizardModel) enabled(section string) bool {
+	if section == "redis" {
+		return m.values["redis.addr"] != ""
+	}
+	return m.values[section+".ip"] != ""
+}
+
+func (m wizardModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m wizardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			m.state = wizardQuit
+			return m, tea.Quit
+		}
+		switch m.state {
+		case wizardInput:
+			return m.updateInput(msg)
+		case wizardConfirm:
+			switch msg.String() {
+			case "y", "enter":
+				m.state = wizardWrite
+				return m, tea.Quit
+			case "r":
+				return m.startTests()
+			case "e":
+				m.state = wizardInput
+				m.index = 0
+				m.showField()
+				return m, textinput.Blink
+			case "q", "esc":
+				m.state = wizardQuit
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case connTestMsg:
+		for i := range m.tests {
+			if m.tests[i].name == msg.name {
+				m.tests[i].done = true
+				m.tests[i].err = msg.err
+			}
+		}
+		if m.state == wizardTesting && m.testsDone() {
+			m.state = wizardConfirm
+		}
+		return m, nil
+	}
+	if m.state != wizardInput {
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.textInput, cmd = m.textInput.Update(msg)
+	return m, cmd
+}
+
+func (m wizardModel) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		// 返回上一项
+		for i := m.index - 1; i >= 0; i-- {
+			if !m.skipped(i) {
+				m.index = i
+				m.errMsg = ""
+				m.showField()
+				break
+			}
+		}
+		return m, nil
+	case "enter":
+		field := wizardFields[m.index]
+		value := strings.TrimSpace(m.textInput.Value())
+		if err := checkWizardValue(field, value); err != nil {
+			m.errMsg = err.Error()
+			return m, nil
+		}
+		m.errMsg = ""
+		m.values[field.key] = value
+		for m.index++; m.index < len(wizardFields) && m.skipped(m.index); m.index++ {
+		}
+		if m.index < len(wizardFields) {
+			m.showField()
+			return m, nil
+		}
+		m.index = len(wizardFields) - 1
+		return m.startTests()
+	}
+	var cmd tea.Cmd
+	m.textInput, cmd = m.textInput.Update(msg)
+	return m, cmd
+}
+
+func checkWizardValue(field wizardField, value string) error {
+	switch {
+	case field.key == "projectname" && value == "":
+		return fmt.Errorf("项目名称不能为空")
+	case strings.HasSuffix(field.key, "port"):
+		if port, err := strconv.Atoi(value); err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("端口 %q 无效", value)
+		}
+	case field.key == "redis.addr" && value != "":
+		if _, _, ok := strings.Cut(value, ":"); !ok {
+			return fmt.Errorf("Redis 地址格式为 host:port")
+		}
+	}
+	return nil
+}
+
+// startTests 并发测试已填写的中间件连接
+func (m wizardModel) startTests() (tea.Model, tea.Cmd) {
+	m.state = wizardTesting
+	m.tests = nil
+	var cmds []tea.Cmd
+	add := func(name string, fn func() error) {
+		m.tests = append(m.tests, connTest{name: name})
+		cmds = append(cmds, testConn(name, fn))
+	}
+	if m.enabled("pg") {
+		db := m.db("pg")
+		add("PG", func() error {
+			conn, err := libs.NewPGClient(db, "qv30")
+			if err != nil {
+				return err
+			}
+			return conn.Close(context.Background())
+		})
+	}
+	if m.enabled("es") {
+		db := m.db("es")
+		add("ES", func() error {
+			client, err := libs.NewESClient(libs.ESConfig{DB: db})
+			if err != nil {
+				return err
+			}
+			client.Stop()
+			return nil
+		})
+	}
+	if m.enabled("doris") {
+		db := m.db("doris")
+		add("Doris", func() error {
+			conn, err := libs.NewMysqlClient(db, "wshoto")
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		})
+	}
+	if m.enabled("redis") {
+		redisCfg := libs.RedisConfig{Addr: m.values["redis.addr"], Password: m.values["redis.password"]}
+		add("Redis", func() error {
+			client, err := libs.NewRedisClient(redisCfg)
+			if err != nil {
+				return err
+			}
+			return client.Close()
+		})
+	}
+	if len(cmds) == 0 {
+		m.state = wizardConfirm
+		return m, nil
+	}
+	return m, tea.Batch(cmds...)
+}
+
+func testConn(name string, fn func() error) tea.Cmd {
+	return func() tea.Msg {
+		done := make(chan error, 1)
+		go func() {
+			done <- fn()
+		}()
+		select {
+		case err := <-done:
+			return connTestMsg{name: name, err: err}
+		case <-time.After(connTestTimeout):
+			return connTestMsg{name: name, err: fmt.Errorf("连接超时（%s）", connTestTimeout)}
+		}
+	}
+}
+
+func (m wizardModel) testsDone() bool {
+	for _, test := range m.tests {
+		if !test.done {
+			return false
+		}
+	}
+	return true
+}
+
+func (m wizardModel) db(section string) libs.DB {
+	port, _ := strconv.Atoi(m.values[section+".port"])
+	return libs.DB{
+		Ip:       m.values[section+".ip"],
+		Port:     port,
+		Username: m.values[section+".username"],
+		Password: m.values[section+".password"],
+		Sslmode:  yes(m.values[section+".sslmode"]),
+	}
+}
+
+func yes(value string) bool {
+	value = strings.ToLower(value)
+	return value == "y" || value == "yes"
+}
+
+func (m wizardModel) View() string {
+	var b strings.Builder
+	b.WriteString("wsctl 配置向导（Enter 确认，Esc 返回上一项，Ctrl+C 退出）\n\n")
+	b.WriteString(fmt.Sprintf("配置文件: %s\n\n", cfgFile))
+	switch m.state {
+	case wizardInput:
+		b.WriteString(fmt.Sprintf("[%d/%d] %s\n", m.index+1, len(wizardFields), wizardFields[m.index].label))
+		b.WriteString(m.textInput.View() + "\n")
+		if m.errMsg != "" {
+			b.WriteString("\n\033[31m" + m.errMsg + "\033[0m\n")
+		}
+	case wizardTesting, wizardConfirm:
+		if len(m.tests) == 0 {
+			b.WriteString("未填写中间件地址，跳过连接测试\n")
+		} else {
+			b.WriteString("连接测试:\n")
+		}
+		failed := 0
+		for _, test := range m.tests {
+			switch {
+			case !test.done:
+				b.WriteString(fmt.Sprintf("  ... %s 连接中\n", test.name))
+			case test.err != nil:
+				failed++
+				b.WriteString(fmt.Sprintf("  \033[31m✗\033[0m %s: %v\n", test.name, test.err))
+			default:
+				b.WriteString(fmt.Sprintf("  \033[32m✓\033[0m %s\n", test.name))
+			}
+		}
+		if m.state == wizardConfirm {
+			if failed > 0 {
+				b.WriteString(fmt.Sprintf("\n%d 个连接失败，仍可写入后再修改配置\n", failed))
+			}
+			b.WriteString("\ny 写入配置文件  r 重新测试  e 返回修改  q 放弃\n")
+		}
+	}
+	return b.String()
+}
+
+// initConfigTemplate 生成的配置文件，未填写的中间件以注释形式保留示例
+var initConfigTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"quote": tomlValue,
+	"list":  tomlList,
+}).Parse(`# 由 wsctl init 生成，完整配置项参考仓库中的 config.toml 示例，修改后可运行 wsctl config validate 检查
+# 敏感信息支持 "${ENV_VAR}"、"file:/run/secrets/x" 和 wsctl config encrypt 生成的 "enc:..." 写法
+logLevel = "warn"          # 日志级别: debug, info, warn, error
+logToFile = false          # 日志是否保存到本地文件 logs/vhagar.log
+projectname = {{quote .ProjectName}}
+# 出网域名检测列表
+domainListName = "domain_list.txt"
+
+# 租户配置，如果是服务商模式，租户填写加密 ID
+[tenant]
+{{- range .Corps}}
+    [[tenant.corp]]
+        corpid = {{quote .}}
+        convenabled = true # 是否开通会话存档功能
+{{- else}}
+    # [[tenant.corp]]
+    #     corpid = "xxxxxxxx"
+    #     convenabled = true # 是否开通会话存档功能
+{{- end}}
+
+# 定时任务，只开启了已配置中间件的任务
+[cron]
+{{- range .Cron}}
+    [cron.{{.Name}}]
+        crontab = {{.Enable}} # 是否启动
+        scheducron = {{quote .Spec}}
+{{- end}}
+
+# 告警通知
+[notify]
+    # 默认机器人，支持配置多个
+    robotkey = {{list .RobotKeys}}
+    # 默认告警@人
+    userlist = {{list .Users}}
+{{with .PG}}
+[pg]
+    ip = {{quote .Ip}}
+    port = {{.Port}}
+    username = {{quote .Username}}
+    password = {{quote .Password}}
+    sslmode = {{.Sslmode}}
+{{else}}
+# [pg]
+#     ip = "x.x.x.x"
+#     port = 5432
+#     username = "postgres"
+#     password = "xxx"
+#     sslmode = false
+{{end}}
+{{- with .ES}}
+[es]
+    ip = {{quote .Ip}}
+    port = {{.Port}}
+    username = {{quote .Username}}
+    password = {{quote .Password}}
+    sslmode = {{.Sslmode}} # 是否使用 https
+{{else}}
+# [es]
+#     ip = "x.x.x.x"
+#     port = 9200
+#     username = "elastic"
+#     password = "xxx"
+#     sslmode = false
+{{end}}
+{{- with .Doris}}
+[doris]
+    ip = {{quote .Ip}}
+    port = {{.Port}} # 查询端口
+    username = {{quote .Username}}
+    password = {{quote .Password}}
+    sslmode = false
+    httpPort = {{$.DorisHttpPort}}
+{{else}}
+# [doris]
+#     ip = "x.x.x.x"
+#     port = 9030
+#     username = "root"
+#     password = "xxx"
+#     sslmode = false
+#     httpPort = 8030
+{{end}}
+{{- with .Redis}}
+[redis]
+    addr = {{quote .Addr}}
+    Password = {{quote .Password}}
+    DB = 0
+{{else}}
+# [redis]
+#     addr = "x.x.x.x:6379"
+#     Password = "xxxx"
+#     DB = 0
+{{end}}
+# wsctl serve 在一个进程中启动以下组件
+[serve]
+    web = true
+    cron = true
+    mcp = false
+    watch = true # 配置文件修改后自动重新加载
+
+# Web 服务：管理界面和 API
+[web]
+    port = "8099"
+`))
+
+type initCron struct {
+	Name   string
+	Enable bool
+	Spec   string
+}
+
+type initConfigData struct {
+	ProjectName   string
+	Corps         []string
+	Cron          []initCron
+	RobotKeys     []string
+	Users         []string
+	PG, ES, Doris *libs.DB
+	DorisHttpPort int
+	Redis         *libs.RedisConfig
+}
+
+// writeInitConfig 按向导填写的内容生成配置文件，选择加密时密码和机器人 key 写为 enc: 值
+func writeInitConfig(m wizardModel) error {
+	secret := func(value string) (string, error) { return value, nil }
+	if yes(m.values["encrypt"]) {
+		keyFile := config.SecretKeyFile(cfgFile)
+		key, created, err := config.EnsureSecretKey(keyFile)
+		if err != nil {
+			return err
+		}
+		if created {
+			fmt.Println("已生成密钥文件", keyFile)
+		}
+		secret = func(value string) (string, error) {
+			if value == "" {
+				return value, nil
+			}
+			return config.Encrypt(key, value)
+		}
+	}
+
+	data := initConfigData{
+		ProjectName: m.values["projectname"],
+		Corps:       splitList(m.values["corp"]),
+		Users:       splitList(m.values["notify.userlist"]),
+	}
+	for _, robot := range splitList(m.values["notify.robotkey"]) {
+		value, err := secret(robot)
+		if err != nil {
+			return err
+		}
+		data.RobotKeys = append(data.RobotKeys, value)
+	}
+	for _, section := range []string{"pg", "es", "doris"} {
+		if !m.enabled(section) {
+			continue
+		}
+		db := m.db(section)
+		password, err := secret(db.Password)
+		if err != nil {
+			return err
+		}
+		db.Password = password
+		switch section {
+		case "pg":
+			data.PG = &db
+		case "es":
+			data.ES = &db
+		case "doris":
+			data.Doris = &db
+			data.DorisHttpPort, _ = strconv.Atoi(m.values["doris.httpport"])
+		}
+	}
+	if m.enabled("redis") {
+		password, err := secret(m.values["redis.password"])
+		if err != nil {
+			return err
+		}
+		data.Redis = &libs.RedisConfig{Addr: m.values["redis.addr"], Password: password}
+	}
+
+	// 有机器人时开启依赖已满足的任务
+	notify := len(data.RobotKeys) > 0
+	hasCorp := len(data.Corps) > 0
+	data.Cron = []initCron{
+		{Name: "tenant", Enable: notify && hasCorp && data.PG != nil && data.Doris != nil, Spec: "30 09 * * *"},
+		{Name: "doris", Enable: notify && data.Doris != nil, Spec: "30 09 * * *"},
+		{Name: "message", Enable: notify && hasCorp && data.PG != nil && data.ES != nil, Spec: "0 10 * * *"},
+		{Name: "es", Enable: notify && data.ES != nil, Spec: "0 10 * * *"},
+		{Name: "redis", Enable: notify && data.Redis != nil, Spec: "0 10 * * *"},
+	}
+
+	var buf bytes.Buffer
+	if err := initConfigTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	// 配置中有密码，只允许当前用户读写
+	return os.WriteFile(cfgFile, buf.Bytes(), 0o600)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '，' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func tomlList(items []string) (string, error) {
+	if items == nil {
+		items = []string{}
+	}
+	return tomlValue(items)
+}
+
+// tomlValue 使用 toml 编码器生成配置值，保证密码等包含特殊字符时生成的配置仍可解析
+func tomlValue(value any) (string, error) {
+	var b strings.Builder
+	if err := toml.NewEncoder(&b).Encode(map[string]any{"v": value}); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(b.String(), "v = "), "\n"), nil
+}
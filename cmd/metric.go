@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"vhagar/libs"
 	"vhagar/metric"
 )
 
@@ -14,7 +15,11 @@ var metricCmd = &cobra.Command{
 	Long:  `监控指标metric`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// 启动 metric 服务
-		metric.StartMetric()
+		ctx, stop := signalContext()
+		defer stop()
+		if err := metric.StartMetric(ctx); err != nil {
+			libs.Logger.Fatalw("metrics 服务启动失败", "err", err)
+		}
 	},
 }
 
@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"vhagar/config"
 	"vhagar/libs"
+	"vhagar/notify"
+	"vhagar/web"
 
 	"github.com/spf13/cobra"
 	"github.com/tomasen/realip"
@@ -27,11 +32,14 @@ var rootCmd = &cobra.Command{
 	Long:  `A longer description that vhagar`,
 	Run: func(cmd *cobra.Command, args []string) {
 		libs.Logger.Warnw("wsctl go go go！！！")
-		startWeb(port)
-
+		ctx, stop := signalContext()
+		defer stop()
+		if err := startWeb(ctx, webPort(cmd)); err != nil {
+			libs.Logger.Fatalw("Web 服务启动失败", "err", err)
+		}
 	},
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		preFunc()
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return preFunc(cmd)
 	},
 }
 
@@ -44,34 +52,77 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "config.toml", "config file")
+	rootCmd.PersistentFlags().StringVar(&config.Profile, "profile", "", "使用的环境，对应配置文件中的 [profiles.<name>]")
 	rootCmd.Flags().StringVarP(&port, "port", "p", "8099", "web 端口")
 }
 
-func preFunc() {
+// preFunc 加载配置并初始化日志，配置有误时返回错误，由 cobra 输出后退出
+func preFunc(cmd *cobra.Command) error {
+	// 配置错误不是命令用法错误，不输出帮助信息
+	cmd.SilenceUsage = true
+	if err := resolveConfigFile(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(cfgFile); os.IsNotExist(err) {
+		return fmt.Errorf("配置文件不存在: %s，可运行 wsctl init 生成", cfgFile)
+	}
+	if _, err := config.InitConfig(cfgFile); err != nil {
+		return fmt.Errorf("初始化配置失败: %w", err)
+	}
+	libs.InitLoggerWithConfig(config.Get().LogLevel, config.Get().LogToFile)
+	return nil
+}
+
+// resolveConfigFile 配置文件路径转为绝对路径
+func resolveConfigFile() error {
 	if !filepath.IsAbs(cfgFile) {
 		currentDir, err := os.Getwd()
 		if err != nil {
-			panic(err)
+			return err
 		}
 		cfgFile = filepath.Join(currentDir, cfgFile)
 	}
-	if _, err := os.Stat(cfgFile); os.IsNotExist(err) {
-		panic("配置文件不存在: " + cfgFile)
+	return nil
+}
+
+// signalContext 收到 SIGTERM 或 Ctrl+C 时取消，用于优雅退出
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// webPort 命令行 -p 优先，其次为配置文件 [web] port
+func webPort(cmd *cobra.Command) string {
+	if !cmd.Flags().Changed("port") && config.Get().Web.Port != "" {
+		return config.Get().Web.Port
 	}
-	if _, err := config.InitConfig(cfgFile); err != nil {
-		panic("初始化配置失败: " + err.Error())
+	return port
+}
+
+// newWebHandler Web 服务使用独立的 mux，避免与同进程的 metrics 服务混用路由。
+// routes 用于注册额外路由，如 serve 的健康检查和 MCP
+func newWebHandler(routes ...func(mux *http.ServeMux)) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", ping)
+	// 告警升级消息中的确认链接，链接自带 token 校验
+	mux.HandleFunc("/ack", notify.AckHandler)
+	web.RegisterAPI(mux)
+	web.RegisterDashboard(mux)
+	for _, route := range routes {
+		route(mux)
 	}
-	libs.InitLoggerWithConfig(config.Config.LogLevel, config.Config.LogToFile)
+	return libs.WithAuth(mux, config.Get().Web.Auth, "/ack", "/healthz", "/readyz")
 }
 
-func startWeb(port string) {
+// startWeb 启动 Web 服务，ctx 取消后优雅关闭
+func startWeb(ctx context.Context, port string, routes ...func(mux *http.ServeMux)) error {
 	Hostname, _ = os.Hostname()
-	http.HandleFunc("/", ping)
-	libs.Logger.Warnw("启动 Web 服务", "url", fmt.Sprintf("http://%s:%s/", getClientIp(), port))
-	err := http.ListenAndServe(":"+port, nil)
-	if err != nil {
-		libs.Logger.Fatalw("Web 服务启动失败", "err", err)
+	cfg := config.Get().Web
+	scheme := "http"
+	if cfg.TLS.Enable {
+		scheme = "https"
 	}
+	libs.Logger.Warnw("启动 Web 服务", "url", fmt.Sprintf("%s://%s:%s/", scheme, getClientIp(), port), "auth", cfg.Auth.Enabled())
+	return libs.ListenAndServe(ctx, ":"+port, newWebHandler(routes...), cfg.TLS)
 }
 
 func ping(w http.ResponseWriter, r *http.Request) {
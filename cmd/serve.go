@@ -0,0 +1,328 @@
+// Package cmd @Author lanpang
+// @Date 2025/8/7 上午10:00:00
+// @Desc 统一进程：按配置启动 Web、metrics、定时任务和 MCP，共用一个生命周期
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+	"vhagar/metric"
+	"vhagar/notify"
+	"vhagar/task"
+
+	"github.com/spf13/cobra"
+)
+
+// 组件状态
+const (
+	componentStarting = "starting"
+	componentRunning  = "running"
+	componentFailed   = "failed"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "启动常驻服务",
+	Long: `在一个进程中启动 Web、metrics、定时任务和 MCP 服务
+相关配置见配置文件的 [serve]，收到 SIGTERM 时优雅退出，收到 SIGHUP 时重新加载配置
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signalContext()
+		defer stop()
+		err := serve(ctx, func() string { return webPort(cmd) })
+		// 尚未到发送时间的报告写入发件箱，下次启动后补发
+		notify.Flush()
+		if err != nil {
+			libs.Logger.Fatalw("服务异常退出", "err", err)
+		}
+		libs.Logger.Warnw("服务已退出")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVarP(&port, "port", "p", "8099", "web 端口")
+}
+
+// component 常驻组件，run 阻塞到 ctx 取消或出错。
+// fingerprint 为需要重启才能生效的配置，重新加载配置后发生变化时重启该组件
+type component struct {
+	name        string
+	fingerprint string
+	run         func(ctx context.Context) error
+}
+
+// componentState 组件状态，供健康检查使用
+type componentState struct {
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// runningComponent 正在运行的组件
+type runningComponent struct {
+	fingerprint string
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// supervisor 启动组件并跟踪状态，任一组件出错时停止全部组件
+type supervisor struct {
+	mu      sync.Mutex
+	states  map[string]*componentState
+	running map[string]*runningComponent
+	failed  chan error
+}
+
+func newSupervisor() *supervisor {
+	return &supervisor{
+		states:  map[string]*componentState{},
+		running: map[string]*runningComponent{},
+		failed:  make(chan error, 1),
+	}
+}
+
+func (s *supervisor) set(name, status string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := &componentState{Status: status, Since: time.Now()}
+	if err != nil {
+		state.Error = err.Error()
+	}
+	s.states[name] = state
+}
+
+// sync 使运行中的组件与配置一致：停止已关闭或配置变化的组件，启动新开启的组件
+func (s *supervisor) sync(ctx context.Context, components []component) {
+	wanted := make(map[string]component, len(components))
+	for _, c := range components {
+		wanted[c.name] = c
+	}
+	for name, running := range s.running {
+		if c, ok := wanted[name]; ok && c.fingerprint == running.fingerprint {
+			continue
+		}
+		libs.Logger.Warnw("停止组件", "component", name)
+		s.stop(name)
+	}
+	for _, c := range components {
+		if _, ok := s.running[c.name]; !ok {
+			s.start(ctx, c)
+		}
+	}
+}
+
+func (s *supervisor) start(ctx context.Context, c component) {
+	runCtx, cancel := context.WithCancel(ctx)
+	running := &runningComponent{fingerprint: c.fingerprint, cancel: cancel, done: make(chan struct{})}
+	s.running[c.name] = running
+	// 组件绑定端口或启动调度后才算运行中，之前就绪检查返回未就绪
+	s.set(c.name, componentStarting, nil)
+	libs.Logger.Warnw("启动组件", "component", c.name)
+	ready := libs.WithReady(runCtx, func() {
+		s.set(c.name, componentRunning, nil)
+	})
+	go func() {
+		defer close(running.done)
+		err := c.run(ready)
+		if runCtx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("组件意外退出")
+		}
+		libs.Logger.Errorw("组件异常退出", "component", c.name, "err", err)
+		s.set(c.name, componentFailed, err)
+		select {
+		case s.failed <- fmt.Errorf("%s: %w", c.name, err):
+		default:
+		}
+	}()
+}
+
+// stop 停止组件并等待退出
+func (s *supervisor) stop(name string) {
+	running, ok := s.running[name]
+	if !ok {
+		return
+	}
+	running.cancel()
+	<-running.done
+	delete(s.running, name)
+	s.mu.Lock()
+	delete(s.states, name)
+	s.mu.Unlock()
+}
+
+func (s *supervisor) stopAll() {
+	for name := range s.running {
+		s.stop(name)
+	}
+}
+
+// snapshot 所有组件状态，ok 表示全部满足 check
+func (s *supervisor) snapshot(check func(status string) bool) (map[string]componentState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make(map[string]componentState, len(s.states))
+	ok := true
+	for name, state := range s.states {
+		states[name] = *state
+		if !check(state.Status) {
+			ok = false
+		}
+	}
+	return states, ok
+}
+
+// healthz 存活检查，没有组件失败即为健康
+func (s *supervisor) healthz(w http.ResponseWriter, r *http.Request) {
+	states, ok := s.snapshot(func(status string) bool { return status != componentFailed })
+	writeHealth(w, ok, states)
+}
+
+// readyz 就绪检查，所有组件都在运行
+func (s *supervisor) readyz(w http.ResponseWriter, r *http.Request) {
+	states, ok := s.snapshot(func(status string) bool { return status == componentRunning })
+	writeHealth(w, ok, states)
+}
+
+func (s *supervisor) routes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /readyz", s.readyz)
+}
+
+func writeHealth(w http.ResponseWriter, ok bool, states map[string]componentState) {
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": ok, "components": states})
+}
+
+// components 按当前配置组装要启动的组件
+func (s *supervisor) components(port string) []component {
+	cfg := config.Get().Serve
+	var components []component
+	if cfg.Web {
+		routes := []func(mux *http.ServeMux){s.routes}
+		if cfg.MCP {
+			routes = append(routes, func(mux *http.ServeMux) {
+				mux.Handle("/mcp", task.MCPHandler())
+			})
+		}
+		web := config.Get().Web
+		components = append(components, component{
+			name:        "web",
+			fingerprint: fmt.Sprintf("%s %v %v %v", port, web.TLS, web.Auth, cfg.MCP),
+			run: func(ctx context.Context) error {
+				return startWeb(ctx, port, routes...)
+			},
+		})
+	} else if cfg.MCP {
+		libs.Logger.Warnw("MCP 服务挂载在 Web 服务上，未开启 web 时不启动")
+	}
+	if metricCfg := config.Get().Metric; metricCfg.Enable {
+		// 采集目标变化由 metric 自行重新加载，只有监听参数变化才重启
+		components = append(components, component{
+			name:        "metric",
+			fingerprint: fmt.Sprintf("%s %v %v", metricCfg.Port, metricCfg.TLS, metricCfg.Auth),
+			run: func(ctx context.Context) error {
+				return metric.StartMetric(ctx, s.routes)
+			},
+		})
+	}
+	if cfg.Cron {
+		// 定时任务变化由调度器自行增删，不需要重启
+		components = append(components, component{name: "cron", run: crontabJob})
+	}
+	if !cfg.Web && !config.Get().Metric.Enable {
+		libs.Logger.Warnw("未开启 Web 和 metrics 服务，无法提供 /healthz 和 /readyz")
+	}
+	return components
+}
+
+// serve 启动组件直到 ctx 取消。收到 SIGHUP 或配置文件变化（[serve] watch）时重新加载配置：
+// 校验通过后在没有任务运行时整体替换，各模块按新配置调整，只重启监听参数变化的组件
+func serve(ctx context.Context, port func() string) error {
+	reload := make(chan struct{}, 1)
+	trigger := func() {
+		select {
+		case reload <- struct{}{}:
+		default:
+		}
+	}
+	watchHangup(ctx, trigger)
+	go func() {
+		err := config.Watch(ctx, cfgFile, func() {
+			if config.Get().Serve.Watch {
+				libs.Logger.Warnw("配置文件已修改，重新加载配置", "config", cfgFile)
+				trigger()
+			}
+		})
+		if err != nil {
+			libs.Logger.Errorw("监听配置文件失败", "err", err)
+		}
+	}()
+
+	s := newSupervisor()
+	defer s.stopAll()
+	for {
+		components := s.components(port())
+		if len(components) == 0 {
+			return errors.New("未开启任何组件，请检查配置 [serve] 和 [metric]")
+		}
+		s.sync(ctx, components)
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-s.failed:
+			return err
+		case <-reload:
+			reloadConfig()
+		}
+	}
+}
+
+// watchHangup 收到 SIGHUP 时调用 onHangup，ctx 取消后停止监听
+func watchHangup(ctx context.Context, onHangup func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				libs.Logger.Warnw("收到 SIGHUP，重新加载配置", "config", cfgFile)
+				onHangup()
+			}
+		}
+	}()
+}
+
+// reloadConfig 重新读取并校验配置，通过后在没有任务运行时整体替换，各模块按新配置调整
+func reloadConfig() {
+	cfg, err := config.Load(cfgFile, taskNames())
+	if err != nil {
+		libs.Logger.Errorw("配置校验失败，继续使用原配置", "err", err)
+		return
+	}
+	task.Exclusive(func() {
+		config.Apply(cfg)
+	})
+	libs.Logger.Warnw("配置已重新加载")
+}
@@ -6,8 +6,10 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 	"vhagar/config"
+	"vhagar/notify"
 	"vhagar/task"
 	_ "vhagar/task/domain"
 	_ "vhagar/task/doris"
@@ -15,8 +17,10 @@ import (
 	_ "vhagar/task/host"
 	_ "vhagar/task/message"
 	_ "vhagar/task/nacos"
+	_ "vhagar/task/probe"
 	_ "vhagar/task/redis"
 	_ "vhagar/task/rocketmq"
+	_ "vhagar/task/sqlcheck"
 	_ "vhagar/task/tenant"
 
 	"github.com/spf13/cobra"
@@ -28,6 +32,9 @@ var (
 	watch     bool
 	writefile string
 	interval  time.Duration
+
+	allProfiles bool
+	scanKeys    bool
 )
 
 var taskCmd = &cobra.Command{
@@ -41,15 +48,15 @@ var taskCmd = &cobra.Command{
 				cmd.Help()
 				os.Exit(1)
 			}
-			task.Do(_task)
+		}
+		if allProfiles {
+			runAllProfiles(cmd)
 		} else {
-			for name := range task.Creators {
-				task.Do(name)
-			}
+			runTasks()
 		}
 
 		// 新增：所有任务执行完后，若 AI 总结开关开启，则读取巡检内容并调用 AI 总结
-		if config.Config.AI.Enable && config.Config.AI.Provider != "" {
+		if config.Get().AI.Enable && config.Get().AI.Provider != "" {
 			summary, err := task.AISummarize("task_output.log")
 			if err != nil {
 				cmd.PrintErrln("AI 总结失败:", err)
@@ -58,6 +65,9 @@ var taskCmd = &cobra.Command{
 			}
 		}
 
+		// 等待投递队列发送完巡检报告
+		notify.Wait()
+
 		// 所有任务执行完后清空日志文件
 		_ = task.ClearOutputFile()
 	},
@@ -68,16 +78,65 @@ var taskCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(taskCmd)
-	taskCmd.Flags().StringVarP(&_task, "task", "t", "", "指定要检查的服务 (host, tenant, nacos, doris, rocketmq, es, redis，domain, message)") // 更新帮助信息
+	taskCmd.Flags().StringVarP(&_task, "task", "t", "", "指定要检查的服务 (host, tenant, nacos, doris, rocketmq, es, redis，domain, message, sqlcheck, http)") // 更新帮助信息
 	taskCmd.Flags().BoolVarP(&watch, "watch", "w", false, "nacos服务，定时刷新")
 	taskCmd.Flags().DurationVarP(&interval, "second", "i", 5*time.Second, "自定义监控服务间隔刷新时间")
 	taskCmd.Flags().BoolVarP(&report, "report", "r", false, "上报企微机器人")
 	taskCmd.Flags().StringVarP(&writefile, "write", "o", "", "导出json文件, prometheus 自动发现文件路径")
+	taskCmd.Flags().BoolVar(&scanKeys, "scan-keys", false, "redis 限速扫描所有键，统计大键、前缀占比和热键")
+	taskCmd.Flags().BoolVar(&allProfiles, "all-profiles", false, "依次巡检配置文件中的所有环境 [profiles.<name>]")
+}
+
+// runTasks 执行指定任务，未指定时执行全部任务
+func runTasks() {
+	if _task != "" {
+		task.Do(_task)
+		return
+	}
+	for _, name := range taskNames() {
+		task.Do(name)
+	}
+}
+
+// runAllProfiles 依次切换到各环境的配置执行任务，输出和报告按环境标注
+func runAllProfiles(cmd *cobra.Command) {
+	profiles, err := config.Profiles(cfgFile)
+	if err != nil {
+		cmd.PrintErrln("读取配置文件失败:", err)
+		os.Exit(1)
+	}
+	if len(profiles) == 0 {
+		cmd.PrintErrln("配置文件中没有定义环境 [profiles.<name>]")
+		os.Exit(1)
+	}
+	base := config.Get()
+	defer config.Apply(base)
+	var failed []string
+	for _, profile := range profiles {
+		cfg, err := config.LoadProfile(cfgFile, profile)
+		if err != nil {
+			cmd.PrintErrf("环境 %s 配置错误，跳过: %v\n", profile, err)
+			failed = append(failed, profile)
+			continue
+		}
+		config.Apply(cfg)
+		// 命令行参数同样作用于各环境
+		setEnv()
+		out := task.GetOutputWriter()
+		fmt.Fprintf(out, "\n================ 环境: %s ================\n", profile)
+		runTasks()
+	}
+	if len(failed) > 0 {
+		cmd.PrintErrln("以下环境未巡检:", strings.Join(failed, ", "))
+	}
 }
 
 func setEnv() {
-	config.Config.Global.Watch = watch
-	config.Config.Global.Interval = interval
-	config.Config.Global.Report = report
-	config.Config.Nacos.Writefile = writefile
+	config.Get().Global.Watch = watch
+	config.Get().Global.Interval = interval
+	config.Get().Global.Report = report
+	config.Get().Nacos.Writefile = writefile
+	if scanKeys {
+		config.Get().Redis.Scan.Enable = true
+	}
 }
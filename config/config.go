@@ -4,9 +4,11 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sync/atomic"
 	"time"
 	"vhagar/libs"
 
@@ -16,25 +18,40 @@ import (
 
 const VERSION = "v5.2"
 
-var (
-	Config *CfgType
-)
+// current 当前生效的配置，热加载时整体替换，并发读取安全
+var current atomic.Pointer[CfgType]
+
+// Get 返回当前配置。调用方不应长期持有返回值，需要新配置时重新获取
+func Get() *CfgType {
+	return current.Load()
+}
 
 type CfgType struct {
 	Global
 	DomainListName  string             `toml:"domainListName"`
+	Domain          DomainCfg          `toml:"domain"`
 	NasDir          string             `toml:"nasDir"`
 	VictoriaMetrics string             `toml:"victoriaMetrics"`
 	Cron            map[string]Crontab `toml:"cron"`
 	Nacos           NacosCfg           `toml:"nacos"`
 	Tenant          Tenant             `toml:"tenant"`
 	PG              libs.DB            `toml:"pg"`
-	ES              libs.DB            `toml:"es"`
+	ES              ESCfg              `toml:"es"`
 	Customer        libs.DB            `toml:"customer"`
 	Doris           DorisCfg           `toml:"doris"`
 	RocketMQ        RocketMQCfg        `toml:"rocketmq"`
 	Metric          MetricCfg          `toml:"metric"`
-	Redis           libs.RedisConfig   `toml:"redis"`
+	Web             WebCfg             `toml:"web"`
+	Serve           ServeCfg           `toml:"serve"`
+	Redis           RedisCfg           `toml:"redis"`
+	SQLCheck        SQLCheckCfg        `toml:"sqlcheck"`
+	HTTP            HTTPCfg            `toml:"http"`
+	Digest          DigestCfg          `toml:"digest"`
+	History         HistoryCfg         `toml:"history"`
+	// 各环境的覆盖配置，由 decode 按 --profile 叠加到基础配置上
+	Profiles map[string]toml.Primitive `toml:"profiles" json:"-"`
+	// 当前使用的环境
+	Profile string `toml:"-" json:"-"`
 
 	AI      AICfg      `toml:"ai"`
 	Weather WeatherCfg `toml:"weather"`
@@ -69,7 +86,6 @@ type Global struct {
 	Watch       bool          `toml:"watch"`
 	Report      bool          `toml:"report"`
 	Interval    time.Duration `toml:"interval"`
-	Duration    time.Duration `toml:"duration"`
 }
 
 type Crontab struct {
@@ -78,44 +94,105 @@ type Crontab struct {
 }
 
 type Notify struct {
-	Robotkey []string            `toml:"robotkey"`
-	Userlist []string            `toml:"userlist"`
-	Notifier map[string]Notifier `toml:"notifier"`
+	Robotkey   []string            `toml:"robotkey"`
+	Userlist   []string            `toml:"userlist"`
+	Notifier   map[string]Notifier `toml:"notifier"`
+	MaxRetries int                 `toml:"maxRetries"` // 限流、网络错误时的最大尝试次数
+	OutboxDir  string              `toml:"outboxDir"`  // 未送达消息的落盘目录
+	Window     time.Duration       `toml:"window"`     // 定时任务推送的随机错峰窗口
+	RateLimit  int                 `toml:"rateLimit"`  // 单个机器人每分钟最多发送条数
+	Mention    []MentionRule       `toml:"mention"`
+	Escalation EscalationCfg       `toml:"escalation"`
+}
+
+// EscalationCfg 严重告警升级链
+type EscalationCfg struct {
+	Enable     bool              `toml:"enable"`
+	AckBaseURL string            `toml:"ackBaseURL"` // 确认链接的访问地址，如 http://x.x.x.x:8099
+	StateFile  string            `toml:"stateFile"`  // 告警状态落盘文件
+	Levels     []EscalationLevel `toml:"levels"`
+}
+
+// EscalationLevel 升级级别，持续时间或连续次数满足任一即通知该级
+type EscalationLevel struct {
+	After    time.Duration `toml:"after"`
+	Runs     int           `toml:"runs"`
+	Robotkey []string      `toml:"robotkey"`
+	Users    []string      `toml:"users"`
+}
+
+// MentionRule @人规则，按任务、最低告警级别和值班时间匹配
+type MentionRule struct {
+	Task     string   `toml:"task"`     // 为空匹配所有任务
+	Severity string   `toml:"severity"` // 最低级别 info/warning/critical，为空匹配所有
+	Users    []string `toml:"users"`
+	Weekdays []int    `toml:"weekdays"` // 1-7 表示周一到周日，为空不限
+	Hours    string   `toml:"hours"`    // 如 "09:00-18:00"，支持跨天 "22:00-08:00"，为空不限
 }
 
 type Notifier struct {
 	Robotkey []string `json:"robotkey"`
 }
 
+// DigestCfg 汇总报告，窗口内完成的定时任务合并为一条消息
+type DigestCfg struct {
+	Enable bool          `toml:"enable"`
+	Window time.Duration `toml:"window"` // 从第一个任务完成开始计时的收集窗口
+	AI     bool          `toml:"ai"`     // 报告顶部附加 AI 总结，需开启 [ai]
+	Tasks  []string      `toml:"tasks"`  // 参与汇总的任务，为空时为所有定时任务
+}
+
+// HistoryCfg 巡检运行记录，供 API 查询历史
+type HistoryCfg struct {
+	File  string `toml:"file"`  // 记录文件，每行一条 JSON
+	Limit int    `toml:"limit"` // 保留的记录条数
+}
+
 type MetricCfg struct {
 	Enable    bool
 	Port      string
 	HealthApi string
+	TLS       libs.TLSConfig  `toml:"tls"`
+	Auth      libs.AuthConfig `toml:"auth"`
+}
+
+// WebCfg Web 服务（管理界面和 API）
+type WebCfg struct {
+	Port string          `toml:"port"` // 命令行 -p 优先
+	TLS  libs.TLSConfig  `toml:"tls"`
+	Auth libs.AuthConfig `toml:"auth"`
+}
+
+// ServeCfg wsctl serve 启动的组件，metrics 服务由 [metric] enable 控制
+type ServeCfg struct {
+	Web   bool `toml:"web"`   // 管理界面和 API，见 [web]
+	Cron  bool `toml:"cron"`  // 定时任务，见 [cron]
+	MCP   bool `toml:"mcp"`   // MCP 服务，挂载在 Web 服务的 /mcp，需同时开启 web
+	Watch bool `toml:"watch"` // 配置文件修改后自动重新加载
 }
 
 func InitConfig(cfgFile string) (*CfgType, error) {
 	//configFile := path.Join(configDir, "config.toml")
-	Config = &CfgType{}
+	current.Store(&CfgType{})
 
 	log.Printf("读取配置文件 %s \n", cfgFile)
-	defer func() {
-		if err := recover(); err != nil {
-			log.Fatalf("Failed Info: 配置文件格式错误 %s", err)
-			return
-		}
-	}()
 	if _, err := os.Stat(cfgFile); err != nil {
 		if os.IsNotExist(err) {
 			//log.Fatalf("读取配置文件 %s 失败，报错：%s", cfgFile, err)
 			return nil, fmt.Errorf("configuration file(%s) not found", cfgFile)
 		}
 	} else {
-		if _, err := toml.DecodeFile(cfgFile, Config); err != nil {
-			//log.Fatalf("Failed Info: 配置文件格式错误 %s", err)
-			return nil, fmt.Errorf("failed to load configs of dir: %s err:%s", cfgFile, err)
+		cfg, err := LoadProfile(cfgFile, Profile)
+		if err != nil {
+			var parseErr toml.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("配置文件 %s 格式错误，可运行 wsctl config validate 检查\n%s", cfgFile, parseErr.ErrorWithPosition())
+			}
+			return nil, fmt.Errorf("failed to load configs of dir: %s err:%s，可运行 wsctl config validate 检查", cfgFile, err)
 		}
+		current.Store(cfg)
 		//log.Println(Config.Notify)
 	}
 	// log.Println("配置文件加载成功", "config", Config)
-	return Config, nil
+	return Get(), nil
 }
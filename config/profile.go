@@ -0,0 +1,88 @@
+// Package config @Author lanpang
+// @Date 2025/8/13 上午10:00:00
+// @Desc 多环境配置：[profiles.<name>] 覆盖基础配置，通过 --profile 选择
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Profile 当前使用的环境，为空时只使用基础配置
+var Profile string
+
+// decode 解析配置内容并叠加 profile 中的配置。profile 中出现的配置项覆盖基础配置，
+// 数组整体替换，未出现的保持基础配置的值。未选中的环境也会解析一遍，用于发现未知配置项
+func decode(data string, profile string) (*CfgType, toml.MetaData, error) {
+	cfg := &CfgType{}
+	md, err := toml.Decode(data, cfg)
+	if err != nil {
+		return nil, md, err
+	}
+	if profile != "" {
+		if _, ok := cfg.Profiles[profile]; !ok {
+			return nil, md, fmt.Errorf("环境 %s 不存在，可选: %s", profile, strings.Join(profileNames(cfg), ", "))
+		}
+	}
+	for _, name := range profileNames(cfg) {
+		target := &CfgType{}
+		if name == profile {
+			target = cfg
+		}
+		if err := md.PrimitiveDecode(cfg.Profiles[name], target); err != nil {
+			return nil, md, fmt.Errorf("环境 %s 配置错误: %w", name, err)
+		}
+	}
+	cfg.Profile = profile
+	// 未配置错峰窗口时使用默认值，显式配置为 0 表示不错峰
+	if !md.IsDefined("notify", "window") && (profile == "" || !md.IsDefined("profiles", profile, "notify", "window")) {
+		cfg.Notify.Window = defaultNotifyWindow
+	}
+	// 未单独配置项目名称时，在项目名称后标注环境，区分各环境的巡检报告
+	if profile != "" && !md.IsDefined("profiles", profile, "projectname") {
+		cfg.ProjectName = fmt.Sprintf("%s[%s]", cfg.ProjectName, profile)
+	}
+	return cfg, md, nil
+}
+
+// Profiles 配置文件中定义的环境名称
+func Profiles(cfgFile string) ([]string, error) {
+	data, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &CfgType{}
+	if _, err := toml.Decode(string(data), cfg); err != nil {
+		return nil, err
+	}
+	return profileNames(cfg), nil
+}
+
+func profileNames(cfg *CfgType) []string {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadProfile 读取指定环境的配置，不修改全局配置，用于依次巡检多个环境
+func LoadProfile(cfgFile, profile string) (*CfgType, error) {
+	data, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := decode(string(data), profile)
+	if err != nil {
+		return nil, err
+	}
+	if errs := resolveSecrets(cfg, cfgFile); len(errs) > 0 {
+		return nil, fmt.Errorf("配置项 %s 解析失败: %w", errs[0].key, errs[0].err)
+	}
+	return cfg, nil
+}
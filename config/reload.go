@@ -0,0 +1,115 @@
+// Package config @Author lanpang
+// @Date 2025/8/8 上午10:00:00
+// @Desc 配置热加载：校验通过后整体替换全局配置，并通知各模块按新配置调整
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+	"vhagar/libs"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 编辑器保存时可能连续触发多个事件，合并后再加载
+const watchDebounce = time.Second
+
+var (
+	hookMu sync.Mutex
+	hookID int
+	hooks  = map[int]func(){}
+)
+
+// Load 读取并校验配置文件，不修改全局配置。有错误级别的问题时返回 error，警告只记录日志
+func Load(cfgFile string, tasks []string) (*CfgType, error) {
+	cfg, issues, err := ValidateFile(cfgFile, tasks)
+	if err != nil {
+		return nil, err
+	}
+	if err := IssuesError(cfgFile, issues); err != nil {
+		return nil, err
+	}
+	for _, issue := range issues {
+		libs.Logger.Warnw("配置警告", "line", issue.Line, "issue", issue.String())
+	}
+	return cfg, nil
+}
+
+// Apply 原子替换全局配置并通知订阅者，命令行设置的运行参数保持不变。
+// 并发读取的模块读到旧配置或新配置之一；任务会持有配置，调用方需保证没有任务正在运行
+func Apply(cfg *CfgType) {
+	if old := Get(); old != nil {
+		cfg.Global.Watch = old.Global.Watch
+		cfg.Global.Report = old.Global.Report
+		cfg.Global.Interval = old.Global.Interval
+	}
+	current.Store(cfg)
+
+	hookMu.Lock()
+	list := make([]func(), 0, len(hooks))
+	for _, hook := range hooks {
+		list = append(list, hook)
+	}
+	hookMu.Unlock()
+	for _, hook := range list {
+		hook()
+	}
+}
+
+// OnReload 注册配置重新加载后的回调，返回取消注册的函数
+func OnReload(hook func()) (cancel func()) {
+	hookMu.Lock()
+	defer hookMu.Unlock()
+	hookID++
+	id := hookID
+	hooks[id] = hook
+	return func() {
+		hookMu.Lock()
+		defer hookMu.Unlock()
+		delete(hooks, id)
+	}
+}
+
+// Watch 监听配置文件变化，变化后调用 onChange，ctx 取消后返回。
+// 监听所在目录而不是文件本身，兼容编辑器先写临时文件再重命名的保存方式
+func Watch(ctx context.Context, cfgFile string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(cfgFile)); err != nil {
+		return err
+	}
+	target := filepath.Clean(cfgFile)
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != target || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(watchDebounce, onChange)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			libs.Logger.Errorw("监听配置文件失败", "err", err)
+		}
+	}
+}
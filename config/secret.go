@@ -0,0 +1,249 @@
+// Package config @Author lanpang
+// @Date 2025/8/12 上午10:00:00
+// @Desc 配置中的敏感信息：环境变量插值、file: 引用和 enc: 加密值
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	filePrefix = "file:"
+	encPrefix  = "enc:"
+	// SecretKeyEnv 密钥（base64）环境变量，优先于密钥文件
+	SecretKeyEnv = "WSCTL_SECRET_KEY"
+	// SecretKeyFileEnv 密钥文件路径环境变量，默认为配置文件同目录下的 secret.key
+	SecretKeyFileEnv = "WSCTL_SECRET_KEY_FILE"
+	secretKeyName    = "secret.key"
+	secretKeySize    = 32
+)
+
+// ${VAR} 或 ${VAR:-默认值}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// secretError 某个配置项解析失败
+type secretError struct {
+	key string
+	err error
+}
+
+// SecretKeyFile 密钥文件路径
+func SecretKeyFile(cfgFile string) string {
+	if file := os.Getenv(SecretKeyFileEnv); file != "" {
+		return file
+	}
+	return filepath.Join(filepath.Dir(cfgFile), secretKeyName)
+}
+
+// resolveSecrets 展开配置中所有字符串的环境变量，并读取 file: 引用、解密 enc: 值。
+// 返回的配置项路径与配置文件中的写法一致，便于定位
+func resolveSecrets(cfg *CfgType, cfgFile string) []secretError {
+	r := &secretResolver{keyFile: SecretKeyFile(cfgFile)}
+	r.walk("", reflect.ValueOf(cfg).Elem())
+	return r.errs
+}
+
+type secretResolver struct {
+	keyFile string
+	key     []byte
+	errs    []secretError
+}
+
+func (r *secretResolver) walk(path string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			r.walk(path, v.Elem())
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("toml"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			// 匿名嵌入的结构体字段直接展开在当前表中
+			sub := path
+			if !field.Anonymous || name != "" {
+				if name == "" {
+					name = field.Name
+				}
+				sub = joinKey(path, strings.ToLower(name))
+			}
+			r.walk(sub, v.Field(i))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			r.walk(joinKey(path, strconv.Itoa(i)), v.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			// map 的值不可寻址，复制后处理再写回
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(k))
+			r.walk(joinKey(path, strings.ToLower(fmt.Sprint(k.Interface()))), elem)
+			v.SetMapIndex(k, elem)
+		}
+	case reflect.String:
+		if !v.CanSet() {
+			return
+		}
+		value, err := r.resolve(v.String())
+		if err != nil {
+			r.errs = append(r.errs, secretError{key: path, err: err})
+			return
+		}
+		v.SetString(value)
+	}
+}
+
+func (r *secretResolver) resolve(value string) (string, error) {
+	value, err := expandEnv(value)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case strings.HasPrefix(value, filePrefix):
+		file := strings.TrimPrefix(value, filePrefix)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("读取密钥文件 %s 失败: %w", file, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case strings.HasPrefix(value, encPrefix):
+		if r.key == nil {
+			key, err := loadSecretKey(r.keyFile)
+			if err != nil {
+				return "", err
+			}
+			r.key = key
+		}
+		return decrypt(r.key, strings.TrimPrefix(value, encPrefix))
+	}
+	return value, nil
+}
+
+// expandEnv 替换 ${VAR}，变量未设置且没有默认值时报错，避免带着空密码连接
+func expandEnv(value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	var missing []string
+	value = envPattern.ReplaceAllStringFunc(value, func(s string) string {
+		m := envPattern.FindStringSubmatch(s)
+		if env, ok := os.LookupEnv(m[1]); ok {
+			return env
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		missing = append(missing, m[1])
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("环境变量 %s 未设置", strings.Join(missing, "、"))
+	}
+	return value, nil
+}
+
+// loadSecretKey 读取密钥，环境变量优先
+func loadSecretKey(keyFile string) ([]byte, error) {
+	encoded := os.Getenv(SecretKeyEnv)
+	source := SecretKeyEnv
+	if encoded == "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取密钥失败，请设置 %s 或提供密钥文件 %s: %w", SecretKeyEnv, keyFile, err)
+		}
+		encoded = string(data)
+		source = keyFile
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != secretKeySize {
+		return nil, fmt.Errorf("密钥 %s 无效，应为 base64 编码的 %d 字节", source, secretKeySize)
+	}
+	return key, nil
+}
+
+// EnsureSecretKey 读取密钥，未设置环境变量且密钥文件不存在时生成新的密钥文件
+func EnsureSecretKey(keyFile string) (key []byte, created bool, err error) {
+	if os.Getenv(SecretKeyEnv) != "" {
+		key, err = loadSecretKey(keyFile)
+		return key, false, err
+	}
+	if _, err := os.Stat(keyFile); err == nil {
+		key, err = loadSecretKey(keyFile)
+		return key, false, err
+	} else if !os.IsNotExist(err) {
+		return nil, false, err
+	}
+	key = make([]byte, secretKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, false, err
+	}
+	if dir := filepath.Dir(keyFile); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
+
+// Encrypt 使用 AES-256-GCM 加密，返回可直接写入配置文件的 enc: 值
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decrypt(key []byte, encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.New("加密值不是有效的 base64")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("加密值长度不正确")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("解密失败，请确认密钥与加密时使用的一致")
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
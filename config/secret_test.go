@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("WSCTL_TEST_HOST", "10.0.0.1")
+	t.Setenv("WSCTL_TEST_EMPTY", "")
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "plain", false},
+		{"${WSCTL_TEST_HOST}", "10.0.0.1", false},
+		{"http://${WSCTL_TEST_HOST}:9200", "http://10.0.0.1:9200", false},
+		{"${WSCTL_TEST_EMPTY}", "", false},
+		{"${WSCTL_TEST_EMPTY:-默认}", "", false},
+		{"${WSCTL_TEST_UNSET:-6379}", "6379", false},
+		{"${WSCTL_TEST_UNSET:-}", "", false},
+		{"${WSCTL_TEST_UNSET}", "", true},
+		{"$WSCTL_TEST_HOST", "$WSCTL_TEST_HOST", false},
+	}
+	for _, tt := range tests {
+		got, err := expandEnv(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandEnv(%q) err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("expandEnv(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	t.Setenv(SecretKeyEnv, "")
+	t.Setenv(SecretKeyFileEnv, "")
+	key, created, err := EnsureSecretKey(filepath.Join(t.TempDir(), secretKeyName))
+	if err != nil || !created {
+		t.Fatalf("EnsureSecretKey created=%v err=%v", created, err)
+	}
+	other := make([]byte, secretKeySize)
+	for _, plaintext := range []string{"", "p@ss:word", "中文密码", strings.Repeat("x", 1024)} {
+		encoded, err := Encrypt(key, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(encoded, encPrefix) {
+			t.Errorf("Encrypt(%q) = %q, 缺少 %s 前缀", plaintext, encoded, encPrefix)
+		}
+		got, err := decrypt(key, strings.TrimPrefix(encoded, encPrefix))
+		if err != nil || got != plaintext {
+			t.Errorf("decrypt(Encrypt(%q)) = %q, %v", plaintext, got, err)
+		}
+		if _, err := decrypt(other, strings.TrimPrefix(encoded, encPrefix)); err == nil {
+			t.Errorf("使用错误的密钥解密 %q 应失败", plaintext)
+		}
+	}
+	if _, err := decrypt(key, "不是base64"); err == nil {
+		t.Error("无效的 base64 应解密失败")
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.toml")
+	t.Setenv(SecretKeyEnv, "")
+	t.Setenv(SecretKeyFileEnv, "")
+	key, _, err := EnsureSecretKey(SecretKeyFile(cfgFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := Encrypt(key, "pg-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwordFile := filepath.Join(dir, "redis.pass")
+	if err := os.WriteFile(passwordFile, []byte("redis-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WSCTL_TEST_ES_PASSWORD", "es-secret")
+
+	cfg := &CfgType{}
+	cfg.PG.Password = encrypted
+	cfg.Redis.Password = filePrefix + passwordFile
+	cfg.ES.Password = "${WSCTL_TEST_ES_PASSWORD}"
+	cfg.Doris.Password = "${WSCTL_TEST_UNSET}"
+	errs := resolveSecrets(cfg, cfgFile)
+
+	if cfg.PG.Password != "pg-secret" {
+		t.Errorf("enc: 值解密为 %q", cfg.PG.Password)
+	}
+	if cfg.Redis.Password != "redis-secret" {
+		t.Errorf("file: 引用读取为 %q", cfg.Redis.Password)
+	}
+	if cfg.ES.Password != "es-secret" {
+		t.Errorf("环境变量展开为 %q", cfg.ES.Password)
+	}
+	if len(errs) != 1 || errs[0].key != "doris.password" {
+		t.Errorf("errs = %v, want doris.password 的环境变量未设置", errs)
+	}
+}
@@ -0,0 +1,88 @@
+// Package config @Author lanpang
+// @Date 2025/8/25 上午10:00:00
+// @Desc 配置定义的 SQL 检查：数据源、带日期占位符的 SQL、期望条件和告警级别
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"vhagar/libs"
+)
+
+// SQL 检查的数据源
+const (
+	SourceDoris = "doris"
+	SourceMySQL = "mysql"
+	SourcePG    = "pg"
+)
+
+// SQLCheckCfg SQL 检查任务
+type SQLCheckCfg struct {
+	Timeout time.Duration `toml:"timeout"` // 单条 SQL 的超时，默认 30s
+	MySQL   libs.DB       `toml:"mysql"`   // datasource = "mysql" 时的连接，doris、pg 使用 [doris]、[pg]
+	Checks  []SQLCheck    `toml:"checks"`
+}
+
+// SQLCheck 一项检查，SQL 的第一行第一列为 value，结果行数为 rows
+type SQLCheck struct {
+	Name       string `toml:"name"`
+	Datasource string `toml:"datasource"` // doris、mysql、pg
+	Database   string `toml:"database"`   // 库名，默认 doris 为 wshoto，pg 为 qv30
+	SQL        string `toml:"sql"`
+	Condition  string `toml:"condition"` // 期望满足的条件，不满足时告警
+	Severity   string `toml:"severity"`  // info、warning、critical，默认 warning
+}
+
+// Condition 解析后的期望条件
+type Condition struct {
+	Subject string  // rows、value 或 avg（与 7 天平均值比较）
+	Op      string  // == != > >= < <=，avg 时为 within
+	Value   float64 // 比较值，avg 时为允许偏离的百分比
+}
+
+var (
+	compareCondition = regexp.MustCompile(`^(rows|value)\s*(==|!=|>=|<=|>|<)\s*(-?[0-9.]+)$`)
+	avgCondition     = regexp.MustCompile(`^value\s+within\s+([0-9.]+)%\s+of\s+avg7d$`)
+)
+
+// ParseCondition 支持 "rows == 0"、"value > 100"、"value within 20% of avg7d"
+func ParseCondition(condition string) (Condition, error) {
+	text := strings.ToLower(strings.Join(strings.Fields(condition), " "))
+	if match := avgCondition.FindStringSubmatch(text); match != nil {
+		percent, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return Condition{}, fmt.Errorf("百分比 %q 无效", match[1])
+		}
+		return Condition{Subject: "avg", Op: "within", Value: percent}, nil
+	}
+	if match := compareCondition.FindStringSubmatch(text); match != nil {
+		value, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			return Condition{}, fmt.Errorf("比较值 %q 无效", match[3])
+		}
+		return Condition{Subject: match[1], Op: match[2], Value: value}, nil
+	}
+	return Condition{}, fmt.Errorf("条件 %q 无效，示例：rows == 0、value > 100、value within 20%% of avg7d", condition)
+}
+
+// Compare 按运算符比较
+func (c Condition) Compare(actual float64) bool {
+	switch c.Op {
+	case "==":
+		return actual == c.Value
+	case "!=":
+		return actual != c.Value
+	case ">":
+		return actual > c.Value
+	case ">=":
+		return actual >= c.Value
+	case "<":
+		return actual < c.Value
+	case "<=":
+		return actual <= c.Value
+	}
+	return false
+}
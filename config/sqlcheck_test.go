@@ -0,0 +1,63 @@
+package config
+
+import "testing"
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      Condition
+		wantErr   bool
+	}{
+		{"rows == 0", Condition{"rows", "==", 0}, false},
+		{"ROWS   !=   0", Condition{"rows", "!=", 0}, false},
+		{"value>100", Condition{"value", ">", 100}, false},
+		{"value >= -1.5", Condition{"value", ">=", -1.5}, false},
+		{"value < 10", Condition{"value", "<", 10}, false},
+		{"value <= 10", Condition{"value", "<=", 10}, false},
+		{"value within 20% of avg7d", Condition{"avg", "within", 20}, false},
+		{" Value  Within 12.5%  of AVG7D ", Condition{"avg", "within", 12.5}, false},
+		{"", Condition{}, true},
+		{"rows = 0", Condition{}, true},
+		{"count > 1", Condition{}, true},
+		{"value > 1.2.3", Condition{}, true},
+		{"rows within 20% of avg7d", Condition{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCondition(tt.condition)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCondition(%q) err = %v, wantErr %v", tt.condition, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseCondition(%q) = %+v, want %+v", tt.condition, got, tt.want)
+		}
+	}
+}
+
+func TestConditionCompare(t *testing.T) {
+	tests := []struct {
+		op     string
+		actual float64
+		want   bool
+	}{
+		{"==", 10, true},
+		{"==", 11, false},
+		{"!=", 11, true},
+		{"!=", 10, false},
+		{">", 11, true},
+		{">", 10, false},
+		{">=", 10, true},
+		{">=", 9, false},
+		{"<", 9, true},
+		{"<", 10, false},
+		{"<=", 10, true},
+		{"<=", 11, false},
+		{"within", 10, false},
+	}
+	for _, tt := range tests {
+		c := Condition{Subject: "value", Op: tt.op, Value: 10}
+		if got := c.Compare(tt.actual); got != tt.want {
+			t.Errorf("%v %s 10 = %v, want %v", tt.actual, tt.op, got, tt.want)
+		}
+	}
+}
@@ -14,6 +14,102 @@ type DorisCfg struct {
 	HttpPort int `toml:"httpport"`
 }
 
+// ESCfg ES 连接和索引检查配置
+type ESCfg struct {
+	libs.ESConfig
+	Index ESIndexCfg `toml:"index"`
+}
+
+// ESIndexCfg 索引检查阈值
+type ESIndexCfg struct {
+	Production     bool  `toml:"production"`     // 生产环境，副本数为 0 的索引告警
+	MaxShardSize   int64 `toml:"maxShardSize"`   // 单个分片大小上限（GB），默认 50
+	SmallShardSize int64 `toml:"smallShardSize"` // 主分片平均大小低于该值（GB）且主分片数大于 1 视为分片过多，默认 1
+	Explain        int   `toml:"explain"`        // 最多解释的未分配分片数，默认 10
+}
+
+// RedisCfg 兼容只配置一个实例的旧写法，多个实例在 [[redis.instances]] 中配置
+type RedisCfg struct {
+	libs.RedisConfig
+	Instances []libs.RedisConfig `toml:"instances"`
+	Scan      RedisScanCfg       `toml:"scan"`
+}
+
+// RedisScanCfg 大键、热键扫描，命令行 --scan-keys 开启
+type RedisScanCfg struct {
+	Enable    bool          `toml:"enable"`
+	Count     int64         `toml:"count"`     // 每次 SCAN 的 COUNT，默认 500
+	Rate      int           `toml:"rate"`      // 每个节点每秒最多检查的键数，默认 2000
+	Limit     int           `toml:"limit"`     // 每个节点最多检查的键数，0 为不限
+	Samples   int           `toml:"samples"`   // MEMORY USAGE 对集合类型的采样数，默认 5
+	TopN      int           `toml:"topN"`      // 输出的大键、热键和前缀数量，默认 20
+	Separator string        `toml:"separator"` // 键前缀分隔符，默认 ":"
+	Timeout   time.Duration `toml:"timeout"`   // 单个实例的扫描超时，默认 30m
+}
+
+// List 所有需要巡检的实例
+func (r RedisCfg) List() []libs.RedisConfig {
+	var list []libs.RedisConfig
+	if len(r.Nodes()) > 0 {
+		list = append(list, r.RedisConfig)
+	}
+	return append(list, r.Instances...)
+}
+
+// HTTPCfg HTTP 探测任务，task 和 metric 模式共用
+type HTTPCfg struct {
+	Timeout        time.Duration `toml:"timeout"`        // 单个目标的请求超时，默认 10s
+	Interval       time.Duration `toml:"interval"`       // metric 模式的探测间隔，默认 60s
+	CertExpiryDays int           `toml:"certExpiryDays"` // HTTPS 证书剩余天数低于该值告警，默认 14
+	Targets        []HTTPTarget  `toml:"targets"`
+}
+
+// HTTPTarget 一个探测目标
+type HTTPTarget struct {
+	Name           string            `toml:"name"`
+	URL            string            `toml:"url"`
+	Method         string            `toml:"method"` // 默认 GET
+	Headers        map[string]string `toml:"headers"`
+	Body           string            `toml:"body"`
+	ExpectCodes    []int             `toml:"expectCodes"`    // 期望的状态码，默认 2xx
+	JSON           []JSONAssert      `toml:"json"`           // 响应体 JSON 断言，路径为 gjson 语法
+	BodyRegex      string            `toml:"bodyRegex"`      // 响应体需匹配的正则
+	MaxLatency     time.Duration     `toml:"maxLatency"`     // 超过该耗时告警，0 为不检查
+	CertExpiryDays int               `toml:"certExpiryDays"` // 覆盖全局的证书剩余天数阈值
+	Redirect       string            `toml:"redirect"`       // follow（默认）、none 不跟随、error 重定向视为失败
+	Insecure       bool              `toml:"insecure"`       // 跳过证书校验，仍检查证书有效期
+	Proxy          bool              `toml:"proxy"`          // 通过 proxyurl 访问
+	Severity       string            `toml:"severity"`       // 探测失败的告警级别，默认 warning
+}
+
+// JSONAssert 响应体 JSON 断言
+type JSONAssert struct {
+	Path  string `toml:"path"`
+	Op    string `toml:"op"` // == != > >= < <= contains exists，默认 ==
+	Value string `toml:"value"`
+}
+
+// DomainCfg 域名检测，域名列表文件由 domainListName 指定
+type DomainCfg struct {
+	TLS DomainTLSCfg `toml:"tls"`
+	DNS DomainDNSCfg `toml:"dns"`
+}
+
+// DomainDNSCfg 域名解析检查
+type DomainDNSCfg struct {
+	Disable    bool          `toml:"disable"`    // 关闭解析检查
+	Resolvers  []string      `toml:"resolvers"`  // DNS 服务器，如 "223.5.5.5"、"10.0.0.2:53"，为空时使用系统解析
+	Timeout    time.Duration `toml:"timeout"`    // 单次解析超时，默认 3s
+	MaxLatency time.Duration `toml:"maxLatency"` // 解析耗时超过该值告警，0 为不检查
+}
+
+// DomainTLSCfg 443 端口的证书检查
+type DomainTLSCfg struct {
+	Disable bool          `toml:"disable"` // 关闭证书检查，只检测连通性
+	Windows []int         `toml:"windows"` // 证书到期提醒窗口（天），默认 [30, 14, 7]，最小的窗口为严重告警
+	Timeout time.Duration `toml:"timeout"` // 单次 TLS 握手超时，默认 5s
+}
+
 type RocketMQCfg struct {
 	RocketmqDashboard string `toml:"rocketmqdashboard"`
 	Username          string `json:"username"`
@@ -28,13 +124,12 @@ type NacosCfg struct {
 	Writefile string
 }
 
-func GetRandomDuration() time.Duration {
-	// 创建一个新的随机数生成器，使用当前时间作为种子
-	source := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(source)
-	// 生成随机数
-	randomSeconds := r.Intn(300)
-	// 将随机秒数转换为时间.Duration
-	duration := time.Duration(randomSeconds) * time.Second
-	return duration
+const defaultNotifyWindow = 300 * time.Second
+
+// GetRandomDuration 在错峰窗口内取随机偏移，窗口为 0 时不错峰
+func GetRandomDuration(window time.Duration) time.Duration {
+	if window <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(window)))
 }
@@ -0,0 +1,703 @@
+// Package config @Author lanpang
+// @Date 2025/8/11 上午10:00:00
+// @Desc 配置校验：按已开启的任务检查必填项、cron 表达式、URL、AI 服务商等，问题定位到行号
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"vhagar/libs"
+
+	"github.com/BurntSushi/toml"
+	"github.com/robfig/cron/v3"
+)
+
+// 问题级别
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+var hoursPattern = regexp.MustCompile(`^\s*\d{1,2}:\d{2}\s*-\s*\d{1,2}:\d{2}\s*$`)
+
+// Issue 配置中的一个问题
+type Issue struct {
+	Level   string `json:"level"`
+	Key     string `json:"key"`  // 配置项路径，数组表带序号，如 notify.mention.0.hours
+	Line    int    `json:"line"` // 所在行号，未知时为 0
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Key == "" {
+		return i.Message
+	}
+	return fmt.Sprintf("[%s] %s", i.Key, i.Message)
+}
+
+// HasError 问题列表中是否有错误级别的问题
+func HasError(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// IssuesError 错误级别的问题合并为一个 error，没有错误时返回 nil
+func IssuesError(cfgFile string, issues []Issue) error {
+	var messages []string
+	for _, issue := range issues {
+		if issue.Level == LevelError {
+			messages = append(messages, fmt.Sprintf("%s:%d %s", filepath.Base(cfgFile), issue.Line, issue))
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// validator 收集校验问题
+type validator struct {
+	cfg    *CfgType
+	lines  map[string]int
+	tasks  []string
+	issues []Issue
+	owner  string // 正在检查的任务所在配置项，依赖的配置缺失时定位到这里
+}
+
+// ValidateFile 读取并校验配置文件。tasks 为已注册的任务名，用于检查 [cron] 中的任务是否存在，为空时不检查。
+// 文件无法读取时返回 error，格式错误、配置问题以 Issue 返回，格式错误时 cfg 为 nil
+func ValidateFile(cfgFile string, tasks []string) (*CfgType, []Issue, error) {
+	data, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, md, err := decode(string(data), Profile)
+	if err != nil {
+		issue := Issue{Level: LevelError, Message: "TOML 格式错误: " + err.Error()}
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			issue.Line = parseErr.Position.Line
+		} else {
+			issue.Message = err.Error()
+		}
+		return nil, []Issue{issue}, nil
+	}
+	v := &validator{cfg: cfg, lines: keyLines(data), tasks: tasks}
+	for _, key := range md.Undecoded() {
+		v.warnf(strings.ToLower(key.String()), "未知配置项，不会生效，请检查拼写或层级")
+	}
+	for _, e := range resolveSecrets(cfg, cfgFile) {
+		v.errorf(e.key, "%v", e.err)
+	}
+	v.check()
+	sort.SliceStable(v.issues, func(i, j int) bool { return v.issues[i].Line < v.issues[j].Line })
+	return cfg, v.issues, nil
+}
+
+// add 记录问题，多个任务依赖同一配置时只记录一次
+func (v *validator) add(level, key, format string, args ...interface{}) {
+	issue := Issue{Level: level, Key: key, Line: v.line(key), Message: fmt.Sprintf(format, args...)}
+	if !slices.Contains(v.issues, issue) {
+		v.issues = append(v.issues, issue)
+	}
+}
+
+func (v *validator) errorf(key, format string, args ...interface{}) {
+	v.add(LevelError, key, format, args...)
+}
+
+func (v *validator) warnf(key, format string, args ...interface{}) {
+	v.add(LevelWarning, key, format, args...)
+}
+
+// line 配置项所在行，配置项缺失时取所在表的行，整个表都缺失时取依赖它的任务所在行
+func (v *validator) line(key string) int {
+	if line := v.lookup(key); line > 0 || v.owner == "" {
+		return line
+	}
+	return v.lookup(v.owner)
+}
+
+// lookup 选中环境时优先定位到 profile 中覆盖的配置项
+func (v *validator) lookup(key string) int {
+	for key != "" {
+		if line, ok := v.lines["profiles."+strings.ToLower(v.cfg.Profile)+"."+key]; ok && v.cfg.Profile != "" {
+			return line
+		}
+		if line, ok := v.lines[key]; ok {
+			return line
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return 0
+}
+
+// enabledTasks 已开启定时的任务
+func (v *validator) enabledTasks() []string {
+	var names []string
+	for name, job := range v.cfg.Cron {
+		if job.Crontab {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (v *validator) check() {
+	cfg := v.cfg
+	if cfg.LogLevel != "" && !slices.Contains([]string{"debug", "info", "warn", "error"}, cfg.LogLevel) {
+		v.errorf("loglevel", "日志级别 %q 无效，可选 debug、info、warn、error", cfg.LogLevel)
+	}
+	v.checkURL("proxyurl", cfg.ProxyURL, false)
+
+	enabled := v.enabledTasks()
+	v.checkCron()
+	for _, name := range enabled {
+		v.owner = "cron." + strings.ToLower(name)
+		v.checkTask(name)
+	}
+	if cfg.Metric.Enable {
+		v.owner = "metric.enable"
+		v.checkMetric()
+	}
+	v.owner = ""
+	v.checkNotify(len(enabled) > 0)
+	v.checkDigest(enabled)
+	v.checkAI()
+	v.checkServer("web", cfg.Web.TLS, cfg.Web.Auth)
+	v.checkServer("metric", cfg.Metric.TLS, cfg.Metric.Auth)
+	if cfg.Serve.MCP && !cfg.Serve.Web {
+		v.warnf("serve.mcp", "MCP 服务挂载在 Web 服务上，需同时开启 web")
+	}
+	if cfg.History.Limit < 0 {
+		v.errorf("history.limit", "不能为负数")
+	}
+}
+
+func (v *validator) checkCron() {
+	for name, job := range v.cfg.Cron {
+		key := "cron." + strings.ToLower(name)
+		if len(v.tasks) > 0 && !slices.Contains(v.tasks, name) {
+			v.errorf(key, "任务 %s 不存在，可选 %s", name, strings.Join(v.tasks, "、"))
+		}
+		if !job.Crontab {
+			continue
+		}
+		if _, err := cron.ParseStandard(job.Scheducron); err != nil {
+			v.errorf(key+".scheducron", "cron 表达式 %q 无效: %s", job.Scheducron, err)
+		}
+	}
+}
+
+// checkTask 按任务检查依赖的中间件配置
+func (v *validator) checkTask(name string) {
+	cfg := v.cfg
+	switch name {
+	case "tenant":
+		v.checkDB("doris", cfg.Doris.DB, name)
+		v.checkDB("pg", cfg.PG, name)
+		v.checkCorp(name)
+	case "doris":
+		v.checkDB("doris", cfg.Doris.DB, name)
+		if cfg.Doris.HttpPort == 0 {
+			v.errorf("doris.httpport", "任务 %s 需要配置 Doris FE 的 HTTP 端口", name)
+		}
+	case "message":
+		v.checkES(name)
+		v.checkDB("pg", cfg.PG, name)
+		v.checkCorp(name)
+	case "es":
+		v.checkES(name)
+	case "redis":
+		v.checkRedis(name)
+	case "nacos":
+		v.checkURL("nacos.server", cfg.Nacos.Server, true)
+	case "rocketmq":
+		v.checkURL("rocketmq.rocketmqdashboard", cfg.RocketMQ.RocketmqDashboard, true)
+	case "host":
+		v.checkURL("victoriametrics", cfg.VictoriaMetrics, true)
+	case "sqlcheck":
+		v.checkSQLChecks(name)
+	case "http":
+		if len(cfg.HTTP.Targets) == 0 {
+			v.errorf("http", "任务 %s 需要在 [[http.targets]] 中配置探测目标", name)
+		}
+		v.checkHTTPTargets()
+	case "domain":
+		if cfg.DomainListName == "" {
+			v.errorf("domainlistname", "任务 %s 需要配置域名列表文件", name)
+		} else if _, err := os.Stat(cfg.DomainListName); err != nil {
+			v.errorf("domainlistname", "域名列表文件 %s 不存在（相对于运行目录）", cfg.DomainListName)
+		}
+		for _, window := range cfg.Domain.TLS.Windows {
+			if window <= 0 {
+				v.errorf("domain.tls.windows", "证书到期提醒窗口 %d 无效，应为正整数天数", window)
+			}
+		}
+		for _, resolver := range cfg.Domain.DNS.Resolvers {
+			host := resolver
+			if h, _, err := splitHostPort(resolver); err == nil {
+				host = h
+			}
+			if host == "" || strings.ContainsAny(host, "/ ") {
+				v.errorf("domain.dns.resolvers", "DNS 服务器 %q 无效，应为 IP 或 IP:端口", resolver)
+			}
+		}
+	}
+}
+
+func (v *validator) checkMetric() {
+	cfg := v.cfg
+	if cfg.Metric.Port == "" {
+		v.errorf("metric.port", "开启 metrics 服务时需要配置端口")
+	} else if _, err := strconv.Atoi(cfg.Metric.Port); err != nil {
+		v.errorf("metric.port", "端口 %q 无效", cfg.Metric.Port)
+	}
+	// 采集器依赖 nacos、rocketmq 和 ES
+	v.checkURL("nacos.server", cfg.Nacos.Server, true)
+	v.checkURL("rocketmq.rocketmqdashboard", cfg.RocketMQ.RocketmqDashboard, true)
+	v.checkES("metric")
+	v.checkHTTPTargets()
+}
+
+func (v *validator) checkDB(section string, db libs.DB, user string) {
+	if db.Ip == "" {
+		v.errorf(section+".ip", "%s 依赖 [%s]，需要配置 ip", user, section)
+	} else {
+		v.checkPlaceholder(section+".ip", db.Ip)
+	}
+	if db.Port <= 0 || db.Port > 65535 {
+		v.errorf(section+".port", "%s 依赖 [%s]，端口 %d 无效", user, section, db.Port)
+	}
+	if db.Password != "" {
+		v.checkPlaceholder(section+".password", db.Password)
+	}
+}
+
+// checkES 配置了 urls 时检查各节点地址，否则检查 ip、port
+func (v *validator) checkES(user string) {
+	es := v.cfg.ES
+	if len(es.URLs) == 0 {
+		v.checkDB("es", es.DB, user)
+	}
+	for _, u := range es.URLs {
+		v.checkURL("es.urls", u, true)
+	}
+	if es.CACert != "" {
+		if _, err := os.Stat(es.CACert); err != nil {
+			v.errorf("es.cacert", "CA 证书文件 %s 不存在", es.CACert)
+		}
+	}
+	if es.APIKey != "" {
+		v.checkPlaceholder("es.apikey", es.APIKey)
+	}
+}
+
+func (v *validator) checkRedis(user string) {
+	redis := v.cfg.Redis
+	if len(redis.List()) == 0 {
+		v.errorf("redis.addr", "任务 %s 需要配置 Redis 地址", user)
+		return
+	}
+	check := func(section string, instance libs.RedisConfig) {
+		switch instance.Mode {
+		case "", libs.RedisStandalone, libs.RedisCluster:
+		case libs.RedisSentinel:
+			if instance.MasterName == "" {
+				v.errorf(section+".mastername", "sentinel 模式需要配置 masterName")
+			}
+		default:
+			v.errorf(section+".mode", "模式 %q 无效，可选 standalone、cluster、sentinel", instance.Mode)
+		}
+		if len(instance.Nodes()) == 0 {
+			v.errorf(section+".addr", "未配置 Redis 地址")
+		}
+		for _, addr := range instance.Nodes() {
+			if _, _, err := splitHostPort(addr); err != nil {
+				v.errorf(section+".addr", "地址 %q 应为 host:port", addr)
+			} else {
+				v.checkPlaceholder(section+".addr", addr)
+			}
+		}
+	}
+	if len(redis.Nodes()) > 0 {
+		check("redis", redis.RedisConfig)
+	}
+	for i, instance := range redis.Instances {
+		check(fmt.Sprintf("redis.instances.%d", i), instance)
+	}
+}
+
+// checkSQLChecks 检查 SQL 检查项的数据源、条件和告警级别
+func (v *validator) checkSQLChecks(user string) {
+	cfg := v.cfg
+	if len(cfg.SQLCheck.Checks) == 0 {
+		v.errorf("sqlcheck", "任务 %s 需要在 [[sqlcheck.checks]] 中配置检查项", user)
+		return
+	}
+	names := map[string]bool{}
+	sources := map[string]bool{}
+	for i, check := range cfg.SQLCheck.Checks {
+		key := fmt.Sprintf("sqlcheck.checks.%d", i)
+		if check.Name == "" {
+			v.errorf(key+".name", "需要配置检查名称")
+		} else if names[check.Name] {
+			v.errorf(key+".name", "检查名称 %q 重复", check.Name)
+		}
+		names[check.Name] = true
+		if strings.TrimSpace(check.SQL) == "" {
+			v.errorf(key+".sql", "需要配置 SQL")
+		}
+		if _, err := ParseCondition(check.Condition); err != nil {
+			v.errorf(key+".condition", "%s", err)
+		}
+		if check.Severity != "" && !slices.Contains([]string{"info", "warning", "critical"}, check.Severity) {
+			v.errorf(key+".severity", "告警级别 %q 无效，可选 info、warning、critical", check.Severity)
+		}
+		switch check.Datasource {
+		case SourceDoris, SourceMySQL, SourcePG:
+			sources[check.Datasource] = true
+		default:
+			v.errorf(key+".datasource", "数据源 %q 无效，可选 doris、mysql、pg", check.Datasource)
+		}
+	}
+	if sources[SourceDoris] {
+		v.checkDB("doris", cfg.Doris.DB, user)
+	}
+	if sources[SourceMySQL] {
+		v.checkDB("sqlcheck.mysql", cfg.SQLCheck.MySQL, user)
+	}
+	if sources[SourcePG] {
+		v.checkDB("pg", cfg.PG, user)
+	}
+}
+
+// checkHTTPTargets 检查探测目标的地址、方法、正则、断言和重定向策略
+func (v *validator) checkHTTPTargets() {
+	for i, target := range v.cfg.HTTP.Targets {
+		key := fmt.Sprintf("http.targets.%d", i)
+		v.checkURL(key+".url", target.URL, true)
+		if target.Method != "" && !slices.Contains([]string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}, strings.ToUpper(target.Method)) {
+			v.errorf(key+".method", "请求方法 %q 无效", target.Method)
+		}
+		if target.BodyRegex != "" {
+			if _, err := regexp.Compile(target.BodyRegex); err != nil {
+				v.errorf(key+".bodyregex", "正则无效: %s", err)
+			}
+		}
+		if !slices.Contains([]string{"", "follow", "none", "error"}, target.Redirect) {
+			v.errorf(key+".redirect", "重定向策略 %q 无效，可选 follow、none、error", target.Redirect)
+		}
+		if target.Severity != "" && !slices.Contains([]string{"info", "warning", "critical"}, target.Severity) {
+			v.errorf(key+".severity", "告警级别 %q 无效，可选 info、warning、critical", target.Severity)
+		}
+		if target.Proxy && v.cfg.ProxyURL == "" {
+			v.warnf(key+".proxy", "开启了 proxy 但未配置 proxyurl，将直接访问")
+		}
+		for j, assert := range target.JSON {
+			assertKey := fmt.Sprintf("%s.json.%d", key, j)
+			if assert.Path == "" {
+				v.errorf(assertKey+".path", "需要配置 JSON 路径")
+			}
+			switch assert.Op {
+			case "", "==", "!=", "contains", "exists":
+			case ">", ">=", "<", "<=":
+				if _, err := strconv.ParseFloat(assert.Value, 64); err != nil {
+					v.errorf(assertKey+".value", "运算符 %s 的比较值 %q 应为数字", assert.Op, assert.Value)
+				}
+			default:
+				v.errorf(assertKey+".op", "运算符 %q 无效，可选 == != > >= < <= contains exists", assert.Op)
+			}
+		}
+	}
+}
+
+func (v *validator) checkCorp(user string) {
+	if len(v.cfg.Tenant.Corp) == 0 {
+		v.errorf("tenant", "%s 需要在 [[tenant.corp]] 中配置租户", user)
+		return
+	}
+	for i, corp := range v.cfg.Tenant.Corp {
+		key := fmt.Sprintf("tenant.corp.%d.corpid", i)
+		if corp.Corpid == "" {
+			v.errorf(key, "corpid 不能为空")
+			continue
+		}
+		v.checkPlaceholder(key, corp.Corpid)
+	}
+}
+
+func (v *validator) checkNotify(cronEnabled bool) {
+	notify := v.cfg.Notify
+	if cronEnabled && len(notify.Robotkey) == 0 {
+		v.errorf("notify.robotkey", "已开启定时任务，需要配置默认机器人")
+	}
+	for name, notifier := range notify.Notifier {
+		if len(notifier.Robotkey) == 0 {
+			v.warnf("notify.notifier."+strings.ToLower(name)+".robotkey", "机器人为空，任务 %s 的报告不会发送", name)
+		}
+	}
+	if notify.MaxRetries < 0 {
+		v.errorf("notify.maxretries", "不能为负数")
+	}
+	if notify.RateLimit < 0 {
+		v.errorf("notify.ratelimit", "不能为负数")
+	}
+	if notify.Window < 0 {
+		v.errorf("notify.window", "不能为负数")
+	}
+	for i, rule := range notify.Mention {
+		key := fmt.Sprintf("notify.mention.%d", i)
+		if rule.Severity != "" && !slices.Contains([]string{"info", "warning", "critical"}, rule.Severity) {
+			v.errorf(key+".severity", "级别 %q 无效，可选 info、warning、critical", rule.Severity)
+		}
+		for _, day := range rule.Weekdays {
+			if day < 1 || day > 7 {
+				v.errorf(key+".weekdays", "星期 %d 无效，应为 1-7", day)
+			}
+		}
+		if rule.Hours != "" && !validHours(rule.Hours) {
+			v.errorf(key+".hours", "时间段 %q 无效，应为 HH:MM-HH:MM", rule.Hours)
+		}
+		if len(rule.Users) == 0 {
+			v.warnf(key+".users", "没有配置需要 @ 的人")
+		}
+	}
+	escalation := notify.Escalation
+	if !escalation.Enable {
+		return
+	}
+	if len(escalation.Levels) == 0 {
+		v.errorf("notify.escalation", "开启告警升级时需要配置 [[notify.escalation.levels]]")
+	}
+	v.checkURL("notify.escalation.ackbaseurl", escalation.AckBaseURL, false)
+	for i, level := range escalation.Levels {
+		key := fmt.Sprintf("notify.escalation.levels.%d", i)
+		if len(level.Robotkey) == 0 {
+			v.errorf(key+".robotkey", "升级级别需要配置机器人")
+		}
+		if level.After <= 0 && level.Runs <= 0 {
+			v.errorf(key, "需要配置 after 或 runs 作为升级条件")
+		}
+	}
+}
+
+func (v *validator) checkDigest(enabled []string) {
+	digest := v.cfg.Digest
+	if !digest.Enable {
+		return
+	}
+	for _, name := range digest.Tasks {
+		if !slices.Contains(enabled, name) {
+			v.warnf("digest.tasks", "任务 %s 未开启定时，不会参与汇总", name)
+		}
+	}
+	if digest.AI && !v.cfg.AI.Enable {
+		v.warnf("digest.ai", "汇总 AI 总结需要开启 [ai]")
+	}
+}
+
+// checkAI 开启 AI 时检查所选服务商配置完整
+func (v *validator) checkAI() {
+	ai := v.cfg.AI
+	if !ai.Enable {
+		return
+	}
+	if ai.Provider == "" {
+		v.errorf("ai.provider", "开启 AI 时需要选择服务商")
+		return
+	}
+	provider, ok := ai.Providers[ai.Provider]
+	if !ok {
+		var names []string
+		for name := range ai.Providers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		v.errorf("ai.provider", "服务商 %s 未在 [ai.providers] 中配置，已配置 %s", ai.Provider, strings.Join(names, "、"))
+		return
+	}
+	key := "ai.providers." + strings.ToLower(ai.Provider)
+	if provider.ApiKey == "" {
+		v.errorf(key+".api_key", "服务商 %s 缺少 api_key", ai.Provider)
+	}
+	if provider.Model == "" {
+		v.errorf(key+".model", "服务商 %s 缺少 model", ai.Provider)
+	}
+	v.checkURL(key+".api_url", provider.ApiUrl, true)
+}
+
+func (v *validator) checkServer(section string, tlsCfg libs.TLSConfig, auth libs.AuthConfig) {
+	if tlsCfg.Enable {
+		if (tlsCfg.CertFile == "") != (tlsCfg.KeyFile == "") {
+			v.errorf(section+".tls", "certFile 和 keyFile 需同时配置，都为空时使用自签名证书")
+		}
+		for key, file := range map[string]string{"certfile": tlsCfg.CertFile, "keyfile": tlsCfg.KeyFile} {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				v.errorf(section+".tls."+key, "文件 %s 不存在", file)
+			}
+		}
+	}
+	if auth.Username != "" && auth.Password == "" {
+		v.errorf(section+".auth.password", "配置 username 时需要配置 password")
+	}
+}
+
+// checkURL 检查 http/https 地址，required 为 false 时允许为空
+func (v *validator) checkURL(key, value string, required bool) {
+	if value == "" {
+		if required {
+			v.errorf(key, "不能为空")
+		}
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.errorf(key, "地址 %q 无效，应为 http(s)://host:port", value)
+		return
+	}
+	v.checkPlaceholder(key, u.Hostname())
+}
+
+// checkPlaceholder 示例配置中的占位值
+func (v *validator) checkPlaceholder(key, value string) {
+	if strings.Contains(value, "x.x.x.x") || strings.Contains(strings.ToLower(value), "xxx") {
+		v.warnf(key, "%q 疑似示例占位值，请替换为实际配置", value)
+	}
+}
+
+func validHours(hours string) bool {
+	if !hoursPattern.MatchString(hours) {
+		return false
+	}
+	for _, clock := range strings.SplitN(hours, "-", 2) {
+		if _, err := time.Parse("15:04", strings.TrimSpace(clock)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func splitHostPort(addr string) (string, int, error) {
+	i := strings.LastIndex(addr, ":")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("缺少端口")
+	}
+	port, err := strconv.Atoi(addr[i+1:])
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("端口无效")
+	}
+	return addr[:i], port, nil
+}
+
+// keyLines 记录每个配置项所在的行号，键为小写的完整路径。
+// 数组表的配置项按序号区分，如 tenant.corp.1.corpid，同时以不带序号的路径记录第一次出现的位置
+func keyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	arrays := map[string]int{}
+	set := func(key string, line int) {
+		if _, ok := lines[key]; !ok {
+			lines[key] = line
+		}
+	}
+	table, plain := "", ""
+	for i, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(stripComment(raw))
+		no := i + 1
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "[["):
+			name := normalizeKey(strings.Trim(line, "[] \t"))
+			index := arrays[name]
+			arrays[name]++
+			plain = name
+			table = indexedTable(name, arrays) + "." + strconv.Itoa(index)
+			set(name, no)
+			set(table, no)
+		case strings.HasPrefix(line, "["):
+			name := normalizeKey(strings.Trim(line, "[] \t"))
+			plain = name
+			table = indexedTable(name, arrays)
+			set(name, no)
+			set(table, no)
+		default:
+			eq := strings.Index(line, "=")
+			if eq <= 0 {
+				continue
+			}
+			key := normalizeKey(line[:eq])
+			set(joinKey(table, key), no)
+			set(joinKey(plain, key), no)
+		}
+	}
+	return lines
+}
+
+// indexedTable 表名中属于数组表的部分加上当前序号，如 notify.escalation.levels 在第二个数组元素下为 notify.escalation.levels.1
+func indexedTable(name string, arrays map[string]int) string {
+	parts := strings.Split(name, ".")
+	var result []string
+	for i := range parts {
+		result = append(result, parts[i])
+		prefix := strings.Join(parts[:i+1], ".")
+		// 只给祖先数组表加序号，当前数组表的序号由调用方添加
+		if count, ok := arrays[prefix]; ok && i < len(parts)-1 {
+			result = append(result, strconv.Itoa(count-1))
+		}
+	}
+	return strings.Join(result, ".")
+}
+
+func joinKey(table, key string) string {
+	if table == "" {
+		return key
+	}
+	return table + "." + key
+}
+
+func normalizeKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.Trim(strings.TrimSpace(part), `"'`))
+	}
+	return strings.Join(parts, ".")
+}
+
+// stripComment 去掉行尾注释，忽略字符串中的 #
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
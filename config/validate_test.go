@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	cfgFile := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(cfgFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cfgFile
+}
+
+func findIssue(issues []Issue, key string) (Issue, bool) {
+	for _, issue := range issues {
+		if issue.Key == key {
+			return issue, true
+		}
+	}
+	return Issue{}, false
+}
+
+func TestValidateFile(t *testing.T) {
+	const content = `loglevel = "verbose"
+loglevl = "info"
+
+[cron]
+  [cron.es]
+  crontab = true
+  scheducron = "0 9 * *"
+
+[notify]
+robotkey = ["key"]
+
+[[notify.mention]]
+users = ["zhangsan"]
+
+[[notify.mention]]
+users = ["lisi"]
+hours = "白天"
+`
+	_, issues, err := ValidateFile(writeConfig(t, content), []string{"es", "redis"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key   string
+		level string
+		line  int
+	}{
+		{"loglevel", LevelError, 1},
+		{"loglevl", LevelWarning, 2},
+		{"cron.es.scheducron", LevelError, 7},
+		{"notify.mention.1.hours", LevelError, 17},
+	}
+	for _, tt := range tests {
+		issue, ok := findIssue(issues, tt.key)
+		if !ok {
+			t.Errorf("缺少 %s 的问题，实际 %v", tt.key, issues)
+			continue
+		}
+		if issue.Level != tt.level || issue.Line != tt.line {
+			t.Errorf("%s: level=%s line=%d, want level=%s line=%d", tt.key, issue.Level, issue.Line, tt.level, tt.line)
+		}
+	}
+	for i := 1; i < len(issues); i++ {
+		if issues[i].Line < issues[i-1].Line {
+			t.Errorf("问题未按行号排序: %v", issues)
+			break
+		}
+	}
+}
+
+func TestValidateFileParseError(t *testing.T) {
+	cfg, issues, err := ValidateFile(writeConfig(t, "proxyurl = \"\"\n\nloglevel = info\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != nil {
+		t.Error("格式错误时 cfg 应为 nil")
+	}
+	if len(issues) != 1 || issues[0].Level != LevelError || issues[0].Line != 3 {
+		t.Errorf("issues = %v, want 第 3 行的格式错误", issues)
+	}
+}
+
+func TestKeyLines(t *testing.T) {
+	const content = `# 注释
+loglevel = "info" # 行尾注释
+proxyurl = "http://a#b"
+
+[Redis]
+addr = "127.0.0.1:6379"
+
+[[tenant.corp]]
+corpid = "a"
+
+[[tenant.corp]]
+corpid = "b"
+`
+	lines := keyLines([]byte(content))
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"loglevel", 2},
+		{"proxyurl", 3},
+		{"redis", 5},
+		{"redis.addr", 6},
+		{"tenant.corp", 8},
+		{"tenant.corp.0.corpid", 9},
+		{"tenant.corp.1", 11},
+		{"tenant.corp.1.corpid", 12},
+		{"tenant.corp.corpid", 9},
+	}
+	for _, tt := range tests {
+		if got := lines[tt.key]; got != tt.want {
+			t.Errorf("keyLines[%q] = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestInitConfigParseError(t *testing.T) {
+	_, err := InitConfig(writeConfig(t, "proxyurl = \"\"\n\nloglevel = info\n"))
+	if err == nil {
+		t.Fatal("格式错误时应返回 error")
+	}
+	if !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "loglevel = info") {
+		t.Errorf("错误信息应包含行号和出错的行: %v", err)
+	}
+}
@@ -4,6 +4,10 @@
 package libs
 
 import (
+	"net"
+	"strconv"
+	"strings"
+
 	"github.com/jackc/pgx/v5"
 )
 
@@ -23,8 +27,61 @@ type PGClienter struct {
 	Conn map[string]*pgx.Conn
 }
 
+// Redis 部署模式
+const (
+	RedisStandalone = "standalone"
+	RedisCluster    = "cluster"
+	RedisSentinel   = "sentinel"
+)
+
 type RedisConfig struct {
+	Name     string // 实例名称，默认为地址
+	Mode     string // standalone（默认）、cluster、sentinel
 	Addr     string
+	Addrs    []string // cluster 的种子节点或 sentinel 的哨兵地址，为空时使用 Addr
 	Password string
 	DB       int
+	// sentinel 模式监控的主节点名称和哨兵密码
+	MasterName       string `toml:"masterName"`
+	SentinelPassword string `toml:"sentinelPassword"`
+}
+
+// Nodes 连接地址，Addrs 为空时使用 Addr
+func (r RedisConfig) Nodes() []string {
+	if len(r.Addrs) > 0 {
+		return r.Addrs
+	}
+	if r.Addr == "" {
+		return nil
+	}
+	return []string{r.Addr}
+}
+
+// Label 实例名称，未配置时为地址
+func (r RedisConfig) Label() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return strings.Join(r.Nodes(), ",")
+}
+
+// ESConfig ES/OpenSearch 连接配置，兼容只配置 ip、port 的旧写法
+type ESConfig struct {
+	DB
+	URLs        []string `toml:"urls"`        // 节点地址，如 https://es1:9200，配置后忽略 ip、port
+	APIKey      string   `toml:"apiKey"`      // base64 编码的 id:api_key，配置后不再使用用户名密码
+	CACert      string   `toml:"caCert"`      // 自定义 CA 证书文件（PEM）
+	Fingerprint string   `toml:"fingerprint"` // 证书的 SHA-256 指纹，配置后以指纹代替证书链校验
+}
+
+// Nodes 连接地址，URLs 为空时按 ip、port、sslmode 拼接
+func (c ESConfig) Nodes() []string {
+	if len(c.URLs) > 0 {
+		return c.URLs
+	}
+	if c.Ip == "" {
+		return nil
+	}
+	scheme := map[bool]string{true: "https", false: "http"}[c.Sslmode]
+	return []string{scheme + "://" + net.JoinHostPort(c.Ip, strconv.Itoa(c.Port))}
 }
@@ -5,33 +5,153 @@ package libs
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
 	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/olivere/elastic/v7"
 	"go.uber.org/zap"
 )
 
-func NewESClient(conf DB) (*elastic.Client, error) {
-	scheme := map[bool]string{true: "https", false: "http"}[conf.Sslmode]
-	esurl := scheme + "://" + conf.Ip + ":" + strconv.Itoa(conf.Port)
-	client, err := elastic.NewClient(
-		elastic.SetSniff(false),
-		elastic.SetScheme(scheme),
-		elastic.SetURL(esurl),
-		elastic.SetBasicAuth(conf.Username, conf.Password),
-		elastic.SetHealthcheck(false))
+// ES 8 兼容 7.x 的媒体类型，请求和响应按 7.x 的格式处理
+const (
+	esCompatJSON   = "application/vnd.elasticsearch+json;compatible-with=7"
+	esCompatNDJSON = "application/vnd.elasticsearch+x-ndjson;compatible-with=7"
+)
+
+// ESClient ES 和 OpenSearch 共用的客户端，es、message 任务和 metric 采集都通过它访问集群
+type ESClient struct {
+	*elastic.Client
+	Distribution string // elasticsearch、opensearch
+	Version      string
+}
+
+// Major 主版本号
+func (c *ESClient) Major() int {
+	major, _ := strconv.Atoi(strings.SplitN(c.Version, ".", 2)[0])
+	return major
+}
+
+// OpenSearch 是否为 OpenSearch 集群
+func (c *ESClient) OpenSearch() bool {
+	return c.Distribution == "opensearch"
+}
+
+// esTransport 添加 API Key 认证，ES 8 时改写为兼容 7.x 的媒体类型
+type esTransport struct {
+	base   http.RoundTripper
+	apiKey string
+	compat atomic.Bool // 连接后识别版本再设置，多节点时健康检查在后台并发请求
+}
+
+func (t *esTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+t.apiKey)
+	}
+	if t.compat.Load() {
+		req.Header.Set("Accept", esCompatJSON)
+		if strings.Contains(req.Header.Get("Content-Type"), "ndjson") {
+			req.Header.Set("Content-Type", esCompatNDJSON)
+		} else if req.Header.Get("Content-Type") != "" {
+			req.Header.Set("Content-Type", esCompatJSON)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// esTLSConfig 自定义 CA 和证书指纹
+func esTLSConfig(conf ESConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if conf.CACert != "" {
+		pem, err := os.ReadFile(conf.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA 证书 %s 无效", conf.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if conf.Fingerprint != "" {
+		want := strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(conf.Fingerprint))
+		// 自签证书无法通过证书链校验，改为校验证书链中任一证书的指纹
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, cert := range state.PeerCertificates {
+				sum := sha256.Sum256(cert.Raw)
+				if hex.EncodeToString(sum[:]) == want {
+					return nil
+				}
+			}
+			return errors.New("ES 证书指纹不匹配")
+		}
+	}
+	return tlsConfig, nil
+}
 
+func NewESClient(conf ESConfig) (*ESClient, error) {
+	urls := conf.Nodes()
+	if len(urls) == 0 {
+		return nil, errors.New("未配置 ES 地址")
+	}
+	tlsConfig, err := esTLSConfig(conf)
 	if err != nil {
 		zap.S().Errorw("创建 ES client 失败", "err", err)
 		return nil, err
 	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = tlsConfig
+	transport := &esTransport{base: base, apiKey: conf.APIKey}
 
-	// 在创建客户端后立即执行一次Ping操作，检查连接是否正常
-	_, _, err = client.Ping(esurl).Do(context.Background())
+	options := []elastic.ClientOptionFunc{
+		elastic.SetSniff(false),
+		elastic.SetURL(urls...),
+		elastic.SetHttpClient(&http.Client{Transport: transport}),
+		// 多个节点时通过健康检查剔除不可用的节点
+		elastic.SetHealthcheck(len(urls) > 1),
+	}
+	if conf.APIKey == "" && conf.Username != "" {
+		options = append(options, elastic.SetBasicAuth(conf.Username, conf.Password))
+	}
+	client, err := elastic.NewClient(options...)
 	if err != nil {
+		zap.S().Errorw("创建 ES client 失败", "err", err)
+		return nil, err
+	}
+
+	// 创建客户端后立即请求根路径，检查连接并识别发行版和版本
+	resp, err := client.PerformRequest(context.Background(), elastic.PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		client.Stop()
 		zap.S().Errorw("连接 ES 失败", "err", err)
 		return nil, err
 	}
-	zap.S().Infow("ES 连接成功！")
-	return client, nil
+	var info struct {
+		Version struct {
+			Number       string `json:"number"`
+			Distribution string `json:"distribution"`
+		} `json:"version"`
+	}
+	if err := json.Unmarshal(resp.Body, &info); err != nil {
+		client.Stop()
+		return nil, fmt.Errorf("解析 ES 版本失败: %w", err)
+	}
+	esClient := &ESClient{Client: client, Distribution: "elasticsearch", Version: info.Version.Number}
+	if info.Version.Distribution == "opensearch" {
+		esClient.Distribution = "opensearch"
+	}
+	transport.compat.Store(!esClient.OpenSearch() && esClient.Major() >= 8)
+	zap.S().Infow("ES 连接成功！", "distribution", esClient.Distribution, "version", esClient.Version)
+	return esClient, nil
 }
@@ -1,6 +1,8 @@
 package libs
 
 import (
+	"errors"
+
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 )
@@ -20,3 +22,38 @@ func NewRedisClient(cfg RedisConfig) (*redis.Client, error) {
 	zap.S().Infow("redis 连接成功！")
 	return client, nil
 }
+
+// NewRedisClusterClient 连接 Redis Cluster，种子节点为 Addrs
+func NewRedisClusterClient(cfg RedisConfig) (*redis.ClusterClient, error) {
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:    cfg.Nodes(),
+		Password: cfg.Password,
+	})
+
+	_, err := client.Ping(client.Context()).Result()
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	zap.S().Infow("redis cluster 连接成功！")
+	return client, nil
+}
+
+// NewRedisSentinelClient 依次尝试连接哨兵，返回第一个可用的哨兵
+func NewRedisSentinelClient(cfg RedisConfig) (*redis.SentinelClient, error) {
+	err := errors.New("未配置哨兵地址")
+	for _, addr := range cfg.Nodes() {
+		client := redis.NewSentinelClient(&redis.Options{
+			Addr:     addr,
+			Password: cfg.SentinelPassword,
+		})
+		if _, err = client.Ping(client.Context()).Result(); err == nil {
+			zap.S().Infow("redis sentinel 连接成功！", "addr", addr)
+			return client, nil
+		}
+		_ = client.Close()
+		zap.S().Errorw("连接 redis sentinel 失败", "addr", addr, "err", err)
+	}
+	return nil, err
+}
@@ -0,0 +1,224 @@
+// Package libs @Author lanpang
+// @Date 2025/8/6 上午10:30:00
+// @Desc 内置 HTTP 服务：可选 TLS（含自签名证书）、Basic/Bearer 认证、收到退出信号时优雅关闭
+package libs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCertFile = "tls/wsctl.crt"
+	defaultKeyFile  = "tls/wsctl.key"
+	shutdownTimeout = 10 * time.Second
+)
+
+// TLSConfig HTTPS 配置，未指定证书时使用首次启动生成的自签名证书
+type TLSConfig struct {
+	Enable   bool   `toml:"enable"`
+	CertFile string `toml:"certFile"`
+	KeyFile  string `toml:"keyFile"`
+}
+
+// AuthConfig 访问认证，配置 token 时支持 Bearer Token，配置 username 时支持 Basic Auth，都为空时不认证
+type AuthConfig struct {
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	Token    string `toml:"token"`
+}
+
+// Enabled 是否开启认证
+func (a AuthConfig) Enabled() bool {
+	return a.Token != "" || a.Username != ""
+}
+
+// WithAuth 为 handler 增加认证，public 中的路径（前缀匹配）不需要认证
+func WithAuth(handler http.Handler, auth AuthConfig, public ...string) http.Handler {
+	if !auth.Enabled() {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range public {
+			if r.URL.Path == path || strings.HasPrefix(r.URL.Path, strings.TrimSuffix(path, "/")+"/") {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+		if authorized(r, auth) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if auth.Username != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="wsctl", charset="UTF-8"`)
+		}
+		appErr := NewError(ErrCodeUnauthorized, "未授权")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(appErr.GetHTTPStatus())
+		_ = json.NewEncoder(w).Encode(appErr)
+	})
+}
+
+func authorized(r *http.Request, auth AuthConfig) bool {
+	if auth.Token != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(token, auth.Token) {
+			return true
+		}
+	}
+	if auth.Username != "" {
+		if username, password, ok := r.BasicAuth(); ok &&
+			secureEqual(username, auth.Username) && secureEqual(password, auth.Password) {
+			return true
+		}
+	}
+	return false
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+type readyKey struct{}
+
+// WithReady 返回携带就绪回调的 ctx，组件完成启动（如端口绑定成功）后通过 Ready 调用
+func WithReady(ctx context.Context, ready func()) context.Context {
+	return context.WithValue(ctx, readyKey{}, ready)
+}
+
+// Ready 调用 ctx 中的就绪回调，未设置时忽略
+func Ready(ctx context.Context) {
+	if ready, ok := ctx.Value(readyKey{}).(func()); ok {
+		ready()
+	}
+}
+
+// ListenAndServe 启动 HTTP 服务，端口绑定成功后调用 Ready。ctx 取消后停止接收新请求，等待处理中的请求完成后返回
+func ListenAndServe(ctx context.Context, addr string, handler http.Handler, tlsCfg TLSConfig) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	certFile, keyFile := tlsCfg.CertFile, tlsCfg.KeyFile
+	if tlsCfg.Enable {
+		if certFile == "" && keyFile == "" {
+			certFile, keyFile = defaultCertFile, defaultKeyFile
+			if err := ensureSelfSignedCert(certFile, keyFile); err != nil {
+				return WrapError(ErrCodeConfigInvalid, "生成自签名证书失败", err)
+			}
+		}
+		// 先加载证书，证书错误时不进入就绪状态
+		if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return WrapError(ErrCodeConfigInvalid, "加载证书失败", err)
+		}
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	Ready(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if tlsCfg.Enable {
+			err = server.ServeTLS(listener, certFile, keyFile)
+		} else {
+			err = server.Serve(listener)
+		}
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	Logger.Warnw("正在关闭 HTTP 服务", "addr", addr)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ensureSelfSignedCert 证书不存在时生成有效期 10 年的自签名证书，包含本机主机名和 IP
+func ensureSelfSignedCert(certFile, keyFile string) error {
+	if _, err := os.Stat(certFile); err == nil {
+		if _, err := os.Stat(keyFile); err == nil {
+			return nil
+		}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "wsctl", Organization: []string{"wsctl self-signed"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if hostname != "" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
+				template.IPAddresses = append(template.IPAddresses, ipnet.IP)
+			}
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	Logger.Warnw("已生成自签名证书", "cert", certFile, "key", keyFile)
+	return nil
+}
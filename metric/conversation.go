@@ -4,6 +4,7 @@
 package metric
 
 import (
+	"context"
 	"time"
 	"vhagar/config"
 	"vhagar/libs"
@@ -27,12 +28,12 @@ func init() {
 	prometheus.MustRegister(messageCount)
 }
 
-func setMessageCount() {
+func setMessageCount(ctx context.Context) {
 	// 不再在此注册指标，避免重复注册
 	// prometheus.MustRegister(messageCount)
 
 	// 初始化 esclient
-	esclient, _ := libs.NewESClient(config.Config.ES)
+	esclient, _ := libs.NewESClient(config.Get().ES.ESConfig)
 	defer func() {
 		if esclient != nil {
 			esclient.Stop()
@@ -41,7 +42,7 @@ func setMessageCount() {
 	if esclient == nil {
 		return
 	}
-	corpList := config.Config.Tenant.Corp
+	corpList := config.Get().Tenant.Corp
 	for {
 		dateNow := time.Now()
 		for _, corp := range corpList {
@@ -51,6 +52,10 @@ func setMessageCount() {
 				libs.Logger.Infow("corp messagenum", "corpid", corp.Corpid, "messagenum", messagenum)
 			}
 		}
-		time.Sleep(300 * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(300 * time.Second):
+		}
 	}
 }
@@ -0,0 +1,83 @@
+// Package metric @Author lanpang
+// @Date 2025/8/27 上午10:00:00
+// @Desc [[http.targets]] 的探测指标
+package metric
+
+import (
+	"context"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+	"vhagar/task/probe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	httpProbeSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "http_probe_success",
+			Help: "Whether the HTTP probe target passed all assertions",
+		},
+		[]string{"name", "url"},
+	)
+	httpProbeStatusCode = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "http_probe_status_code",
+			Help: "HTTP status code of the probe target, 0 if the request failed",
+		},
+		[]string{"name", "url"},
+	)
+	httpProbeDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "http_probe_duration_seconds",
+			Help: "Duration of the HTTP probe request including body",
+		},
+		[]string{"name", "url"},
+	)
+	httpProbeCertExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "http_probe_cert_expiry_timestamp_seconds",
+			Help: "Expiry time of the HTTPS certificate in unix seconds",
+		},
+		[]string{"name", "url"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(httpProbeSuccess, httpProbeStatusCode, httpProbeDuration, httpProbeCertExpiry)
+}
+
+// probeHTTPTargets 定期探测配置的目标，未配置时直接返回
+func probeHTTPTargets(ctx context.Context) {
+	cfg := config.Get()
+	if len(cfg.HTTP.Targets) == 0 {
+		return
+	}
+	interval := cfg.HTTP.Interval
+	if interval <= 0 {
+		interval = probe.DefaultInterval
+	}
+	for {
+		for _, result := range probe.ProbeAll(cfg) {
+			success := 0.0
+			if result.Success() {
+				success = 1
+			} else {
+				libs.Logger.Errorw("HTTP 探测失败", "name", result.Name, "url", result.URL, "err", result.Error, "failures", result.Failures)
+			}
+			httpProbeSuccess.WithLabelValues(result.Name, result.URL).Set(success)
+			httpProbeStatusCode.WithLabelValues(result.Name, result.URL).Set(float64(result.StatusCode))
+			httpProbeDuration.WithLabelValues(result.Name, result.URL).Set(result.Latency.Seconds())
+			if result.CertExpiry != nil {
+				httpProbeCertExpiry.WithLabelValues(result.Name, result.URL).Set(float64(result.CertExpiry.Unix()))
+			}
+		}
+		libs.Logger.Infow("HTTP 探测完成", "targets", len(cfg.HTTP.Targets))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
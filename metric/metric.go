@@ -4,6 +4,7 @@
 package metric
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -13,19 +14,61 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func StartMetric() {
-	cfg := config.Config.Metric
-	// 服务健康检查
-	go strobeHTTPStatusCode(cfg.HealthApi)
-	// rocketmq 指标
-	go setBrokerCount()
-	// 会话数统计
-	go setMessageCount()
-	http.Handle("/metrics", promhttp.Handler())
-	libs.Logger.Warnw("启动 metrics 服务", "url", fmt.Sprintf("http://%s:%s/metrics", getClientIp(), cfg.Port))
-	err := http.ListenAndServe(":"+cfg.Port, nil)
-	if err != nil {
-		libs.Logger.Fatalw("metrics 服务启动失败", "err", err)
+// StartMetric 启动采集和 metrics 服务，ctx 取消后停止采集并优雅关闭。
+// routes 用于在同一端口注册额外路由，如 serve 的健康检查
+func StartMetric(ctx context.Context, routes ...func(mux *http.ServeMux)) error {
+	cfg := config.Get().Metric
+	// 配置重新加载后重启采集器，使用新的采集目标
+	reloaded := make(chan struct{}, 1)
+	cancel := config.OnReload(func() {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	})
+	defer cancel()
+	go runCollectors(ctx, reloaded)
+	// 独立 mux，与同进程的 Web 服务互不影响
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	for _, route := range routes {
+		route(mux)
+	}
+	scheme := "http"
+	if cfg.TLS.Enable {
+		scheme = "https"
+	}
+	libs.Logger.Warnw("启动 metrics 服务", "url", fmt.Sprintf("%s://%s:%s/metrics", scheme, getClientIp(), cfg.Port), "auth", cfg.Auth.Enabled())
+	return libs.ListenAndServe(ctx, ":"+cfg.Port, libs.WithAuth(mux, cfg.Auth, "/healthz", "/readyz"), cfg.TLS)
+}
+
+// runCollectors 按当前配置启动采集器，收到重新加载通知时停止旧采集器后重新启动
+func runCollectors(ctx context.Context, reloaded <-chan struct{}) {
+	for {
+		collectCtx, cancel := context.WithCancel(ctx)
+		// 服务健康检查
+		go strobeHTTPStatusCode(collectCtx, config.Get().Metric.HealthApi)
+		// rocketmq 指标
+		go setBrokerCount(collectCtx)
+		// 会话数统计
+		go setMessageCount(collectCtx)
+		// [[http.targets]] 接口探测
+		go probeHTTPTargets(collectCtx)
+		select {
+		case <-ctx.Done():
+			cancel()
+			return
+		case <-reloaded:
+			cancel()
+			libs.Logger.Warnw("配置已更新，重启指标采集")
+			// 清除已移除目标的指标，由新的采集器重新生成
+			probeHTTPStatusCode.Reset()
+			messageCount.Reset()
+			httpProbeSuccess.Reset()
+			httpProbeStatusCode.Reset()
+			httpProbeDuration.Reset()
+			httpProbeCertExpiry.Reset()
+		}
 	}
 }
 
@@ -4,6 +4,7 @@
 package metric
 
 import (
+	"context"
 	"time"
 	"vhagar/config"
 	"vhagar/libs"
@@ -25,16 +26,20 @@ func init() {
 	prometheus.MustRegister(brokerCount)
 }
 
-func setBrokerCount() {
+func setBrokerCount(ctx context.Context) {
 	// 不再在此注册指标，避免重复注册
 	// prometheus.MustRegister(brokerCount)
-	rocket := rocketmq.NewRocketMQ(config.Config, libs.Logger)
+	rocket := rocketmq.NewRocketMQ(config.Get(), libs.Logger)
 	for {
 		rocket.Gather()
 		conut := len(rocket.BrokerMap)
 		brokerCount.Set(float64(conut))
 		libs.Logger.Infow("brokercount", "count", conut)
-		time.Sleep(60 * time.Second) // 每30秒探测一次
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(60 * time.Second): // 每60秒探测一次
+		}
 	}
 
 }
@@ -4,6 +4,7 @@
 package metric
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -33,11 +34,15 @@ func init() {
 	prometheus.MustRegister(probeHTTPStatusCode)
 }
 
-func strobeHTTPStatusCode(healthApi string) {
+func strobeHTTPStatusCode(ctx context.Context, healthApi string) {
 	// 不再在此注册指标，避免重复注册
 	// prometheus.MustRegister(probeHTTPStatusCode)
+	// 只配置了 [[http.targets]] 时不探测 Nacos 实例
+	if config.Get().Nacos.Server == "" {
+		return
+	}
 	// 获取 newNacos 服务信息
-	newNacos := nacos.NewNacos(config.Config, libs.Logger)
+	newNacos := nacos.NewNacos(config.Get(), libs.Logger)
 	err := newNacos.Init()
 	if err != nil {
 		libs.Logger.Errorw("初始化 Nacos 服务失败", "err", err)
@@ -59,7 +64,11 @@ func strobeHTTPStatusCode(healthApi string) {
 			}(instance)
 		}
 		wg.Wait()
-		time.Sleep(30 * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(30 * time.Second):
+		}
 	}
 }
 
@@ -0,0 +1,212 @@
+// Package notify @Author lanpang
+// @Date 2025/7/31 下午3:00:00
+// @Desc 未确认的严重告警按升级链逐级通知
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+)
+
+const defaultEscalationStateFile = "escalation.json"
+
+// Finding 巡检发现的一个问题，Key 在同一任务内唯一，用于跨次运行跟踪同一问题
+type Finding struct {
+	Task     string   `json:"task"`
+	Key      string   `json:"key"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// alertState 一个严重问题的持续状态
+type alertState struct {
+	Finding   Finding   `json:"finding"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Runs      int       `json:"runs"`  // 连续出现的运行次数
+	Level     int       `json:"level"` // 已通知到的升级级别数
+	Token     string    `json:"token"`
+	Acked     bool      `json:"acked"`
+	AckedBy   string    `json:"ackedBy,omitempty"`
+	AckedAt   time.Time `json:"ackedAt,omitempty"`
+}
+
+var escalationMu sync.Mutex
+
+func escalationStateFile() string {
+	if file := config.Get().Notify.Escalation.StateFile; file != "" {
+		return file
+	}
+	return defaultEscalationStateFile
+}
+
+func loadAlertStates() map[string]*alertState {
+	states := make(map[string]*alertState)
+	data, err := os.ReadFile(escalationStateFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			libs.Logger.Errorw("读取告警升级状态失败", "err", err)
+		}
+		return states
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		libs.Logger.Errorw("告警升级状态文件格式错误", "err", err)
+	}
+	return states
+}
+
+func saveAlertStates(states map[string]*alertState) {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		libs.Logger.Errorw("序列化告警升级状态失败", "err", err)
+		return
+	}
+	file := escalationStateFile()
+	if err := os.WriteFile(file+".tmp", data, 0600); err != nil {
+		libs.Logger.Errorw("写入告警升级状态失败", "err", err)
+		return
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		libs.Logger.Errorw("写入告警升级状态失败", "err", err)
+	}
+}
+
+func alertID(taskName, key string) string {
+	return taskName + "/" + key
+}
+
+// Escalate 根据本次运行的问题更新升级状态：已消失的问题清除，
+// 未确认的严重问题持续时间或连续次数达到阈值后通知下一级
+func Escalate(taskName string, findings []Finding) {
+	cfg := config.Get().Notify.Escalation
+	if !cfg.Enable || len(cfg.Levels) == 0 {
+		return
+	}
+	escalationMu.Lock()
+	defer escalationMu.Unlock()
+
+	states := loadAlertStates()
+	now := time.Now()
+	current := make(map[string]bool)
+	for _, finding := range findings {
+		if finding.Severity != SeverityCritical {
+			continue
+		}
+		id := alertID(taskName, finding.Key)
+		current[id] = true
+		state, ok := states[id]
+		if !ok {
+			state = &alertState{FirstSeen: now, Token: newToken()}
+			states[id] = state
+		}
+		state.Finding = finding
+		state.LastSeen = now
+		state.Runs++
+	}
+	// 本次未出现的问题视为已恢复
+	for id, state := range states {
+		if state.Finding.Task == taskName && !current[id] {
+			libs.Logger.Warnw("告警已恢复", "id", id)
+			delete(states, id)
+		}
+	}
+
+	for id := range current {
+		state := states[id]
+		if state.Acked || state.Level >= len(cfg.Levels) {
+			continue
+		}
+		level := cfg.Levels[state.Level]
+		overdue := level.After > 0 && now.Sub(state.FirstSeen) >= level.After
+		repeated := level.Runs > 0 && state.Runs >= level.Runs
+		if !overdue && !repeated {
+			continue
+		}
+		state.Level++
+		libs.Logger.Warnw("告警升级", "id", id, "level", state.Level)
+		sendTo(escalationMarkdown(id, state, level), taskName, level.Robotkey, time.Now())
+	}
+	saveAlertStates(states)
+}
+
+func escalationMarkdown(id string, state *alertState, level config.EscalationLevel) *WeChatMarkdown {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("# 告警升级（第 %d 级）\n", state.Level))
+	builder.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
+	builder.WriteString("**任务：**<font color='info'>" + state.Finding.Task + "</font>\n")
+	builder.WriteString("**问题：**<font color='red'>" + state.Finding.Message + "</font>\n")
+	builder.WriteString("**首次发现：**<font color='info'>" + state.FirstSeen.Format("2006-01-02 15:04:05") + "</font>\n")
+	builder.WriteString(fmt.Sprintf("**持续：**<font color='warning'>%s，连续 %d 次巡检</font>\n",
+		time.Since(state.FirstSeen).Round(time.Minute), state.Runs))
+	if base := config.Get().Notify.Escalation.AckBaseURL; base != "" {
+		ackURL := fmt.Sprintf("%s/ack?id=%s&token=%s", strings.TrimRight(base, "/"), url.QueryEscape(id), state.Token)
+		builder.WriteString(fmt.Sprintf("\n[点击确认告警，停止升级](%s)\n", ackURL))
+	}
+	for _, user := range level.Users {
+		builder.WriteString(fmt.Sprintf("<@%s>", user))
+	}
+	return &WeChatMarkdown{
+		MsgType: "markdown",
+		Markdown: &Markdown{
+			Content: builder.String(),
+		},
+	}
+}
+
+// Ack 确认告警，确认后不再升级，问题恢复后状态自动清除
+func Ack(id, token, by string) error {
+	escalationMu.Lock()
+	defer escalationMu.Unlock()
+	states := loadAlertStates()
+	state, ok := states[id]
+	if !ok {
+		return libs.NewErrorWithDetail(libs.ErrCodeNotFound, "告警不存在或已恢复", id)
+	}
+	if token == "" || token != state.Token {
+		return libs.NewError(libs.ErrCodeForbidden, "确认链接无效")
+	}
+	if !state.Acked {
+		state.Acked = true
+		state.AckedBy = by
+		state.AckedAt = time.Now()
+		saveAlertStates(states)
+		libs.Logger.Warnw("告警已确认", "id", id, "by", by)
+	}
+	return nil
+}
+
+// AckHandler 处理消息中的确认链接 GET /ack?id=xxx&token=xxx
+func AckHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	err := Ack(id, r.URL.Query().Get("token"), r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err != nil {
+		status := http.StatusInternalServerError
+		if appErr, ok := err.(*libs.AppError); ok {
+			status = appErr.GetHTTPStatus()
+		}
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintf(w, "<h3>确认失败：%s</h3>", html.EscapeString(err.Error()))
+		return
+	}
+	_, _ = fmt.Fprintf(w, "<h3>告警 %s 已确认，问题恢复前不再升级。</h3>", html.EscapeString(id))
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,61 @@
+// Package notify @Author lanpang
+// @Date 2025/8/1 上午10:00:00
+// @Desc 汇总模式下暂存任务报告，由汇总报告统一发送
+package notify
+
+import "sync"
+
+var (
+	holdMu      sync.Mutex
+	heldTasks   = map[string]bool{}
+	holding     = map[string]bool{}
+	heldReports = map[string][]string{}
+)
+
+// SetHeld 设置暂存报告的任务，Send 不再直接投递，配置重新加载后重新设置
+func SetHeld(taskNames ...string) {
+	holdMu.Lock()
+	defer holdMu.Unlock()
+	heldTasks = map[string]bool{}
+	for _, name := range taskNames {
+		heldTasks[name] = true
+	}
+}
+
+// IsHeld 任务报告是否被暂存
+func IsHeld(taskName string) bool {
+	holdMu.Lock()
+	defer holdMu.Unlock()
+	return heldTasks[taskName]
+}
+
+// BeginHold 定时运行开始时调用，参与汇总的任务本次运行的报告暂存，直到 TakeHeld 取出。
+// 手动运行和 API 运行不调用，报告直接发送
+func BeginHold(taskName string) {
+	holdMu.Lock()
+	defer holdMu.Unlock()
+	if heldTasks[taskName] {
+		holding[taskName] = true
+	}
+}
+
+// hold 暂存报告内容，任务本次运行未开始暂存时返回 false
+func hold(markdown *WeChatMarkdown, taskName string) bool {
+	holdMu.Lock()
+	defer holdMu.Unlock()
+	if !holding[taskName] {
+		return false
+	}
+	heldReports[taskName] = append(heldReports[taskName], markdown.Markdown.Content)
+	return true
+}
+
+// TakeHeld 结束暂存，取出并清空任务暂存的报告
+func TakeHeld(taskName string) []string {
+	holdMu.Lock()
+	defer holdMu.Unlock()
+	delete(holding, taskName)
+	reports := heldReports[taskName]
+	delete(heldReports, taskName)
+	return reports
+}
@@ -0,0 +1,121 @@
+// Package notify @Author lanpang
+// @Date 2025/7/30 上午10:30:00
+// @Desc 按任务、告警级别和时间窗口路由@人
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+)
+
+// Severity 告警级别
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Level 级别数值，用于比较高低，未知级别视为 info
+func (s Severity) Level() int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Mentions 返回需要@的用户：未配置规则时使用全局 userlist，配置了规则时命中规则的用户取并集，都不命中时不@人
+func Mentions(taskName string, severity Severity) []string {
+	rules := config.Get().Notify.Mention
+	if len(rules) == 0 {
+		return config.Get().Notify.Userlist
+	}
+	now := time.Now()
+	seen := make(map[string]bool)
+	var users []string
+	for _, rule := range rules {
+		if !ruleMatch(rule, taskName, severity, now) {
+			continue
+		}
+		for _, user := range rule.Users {
+			if !seen[user] {
+				seen[user] = true
+				users = append(users, user)
+			}
+		}
+	}
+	return users
+}
+
+func ruleMatch(rule config.MentionRule, taskName string, severity Severity, now time.Time) bool {
+	if rule.Task != "" && rule.Task != taskName {
+		return false
+	}
+	if rule.Severity != "" && severity.Level() < Severity(rule.Severity).Level() {
+		return false
+	}
+	if len(rule.Weekdays) > 0 && !inWeekdays(rule.Weekdays, now) {
+		return false
+	}
+	if rule.Hours != "" {
+		in, err := inHours(rule.Hours, now)
+		if err != nil {
+			libs.Logger.Errorw("@人规则时间段格式错误", "hours", rule.Hours, "err", err)
+			return false
+		}
+		return in
+	}
+	return true
+}
+
+// inWeekdays 1-7 表示周一到周日
+func inWeekdays(weekdays []int, now time.Time) bool {
+	day := int(now.Weekday())
+	if day == 0 {
+		day = 7
+	}
+	for _, d := range weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// inHours 判断是否在 "09:00-18:00" 时间段内，支持跨天的 "22:00-08:00"
+func inHours(hours string, now time.Time) (bool, error) {
+	parts := strings.SplitN(hours, "-", 2)
+	if len(parts) != 2 {
+		return false, fmt.Errorf("应为 HH:MM-HH:MM")
+	}
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return false, err
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return false, err
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end, nil
+	}
+	return minute >= start || minute < end, nil
+}
+
+// parseClock 解析 HH:MM 为当天的分钟数
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+
+	"go.uber.org/zap"
+)
+
+func TestInHours(t *testing.T) {
+	at := func(clock string) time.Time {
+		tm, _ := time.Parse("15:04", clock)
+		return tm
+	}
+	tests := []struct {
+		hours   string
+		now     string
+		want    bool
+		wantErr bool
+	}{
+		{"09:00-18:00", "09:00", true, false},
+		{"09:00-18:00", "17:59", true, false},
+		{"09:00-18:00", "18:00", false, false},
+		{"09:00-18:00", "08:59", false, false},
+		{"22:00-08:00", "23:30", true, false},
+		{"22:00-08:00", "07:59", true, false},
+		{"22:00-08:00", "08:00", false, false},
+		{"22:00-08:00", "12:00", false, false},
+		{" 09:00 - 18:00 ", "10:00", true, false},
+		{"09:00", "10:00", false, true},
+		{"9点-18点", "10:00", false, true},
+		{"09:00-25:00", "10:00", false, true},
+	}
+	for _, tt := range tests {
+		got, err := inHours(tt.hours, at(tt.now))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("inHours(%q, %s) err = %v, wantErr %v", tt.hours, tt.now, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("inHours(%q, %s) = %v, want %v", tt.hours, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	libs.Logger = zap.NewNop().Sugar()
+	// 2025-07-28 是周一
+	monday := time.Date(2025, 7, 28, 10, 0, 0, 0, time.Local)
+	sunday := time.Date(2025, 8, 3, 23, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		rule     config.MentionRule
+		task     string
+		severity Severity
+		now      time.Time
+		want     bool
+	}{
+		{"空规则匹配所有", config.MentionRule{}, "redis", SeverityInfo, monday, true},
+		{"任务不同", config.MentionRule{Task: "es"}, "redis", SeverityCritical, monday, false},
+		{"任务相同", config.MentionRule{Task: "redis"}, "redis", SeverityInfo, monday, true},
+		{"级别不足", config.MentionRule{Severity: "critical"}, "redis", SeverityWarning, monday, false},
+		{"级别更高", config.MentionRule{Severity: "warning"}, "redis", SeverityCritical, monday, true},
+		{"工作日命中", config.MentionRule{Weekdays: []int{1, 2, 3, 4, 5}}, "redis", SeverityInfo, monday, true},
+		{"周日为 7", config.MentionRule{Weekdays: []int{7}}, "redis", SeverityInfo, sunday, true},
+		{"周末未命中", config.MentionRule{Weekdays: []int{6, 7}}, "redis", SeverityInfo, monday, false},
+		{"值班时间内", config.MentionRule{Hours: "09:00-18:00"}, "redis", SeverityInfo, monday, true},
+		{"跨天值班", config.MentionRule{Hours: "22:00-08:00"}, "redis", SeverityInfo, sunday, true},
+		{"值班时间外", config.MentionRule{Hours: "22:00-08:00"}, "redis", SeverityInfo, monday, false},
+		{"时间段格式错误", config.MentionRule{Hours: "白天"}, "redis", SeverityInfo, monday, false},
+	}
+	for _, tt := range tests {
+		if got := ruleMatch(tt.rule, tt.task, tt.severity, tt.now); got != tt.want {
+			t.Errorf("%s: ruleMatch = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	libs.Logger = zap.NewNop().Sugar()
+	tests := []struct {
+		name     string
+		notify   config.Notify
+		task     string
+		severity Severity
+		want     []string
+	}{
+		{
+			name:     "未配置规则使用 userlist",
+			notify:   config.Notify{Userlist: []string{"@all"}},
+			task:     "redis",
+			severity: SeverityInfo,
+			want:     []string{"@all"},
+		},
+		{
+			name: "命中规则取并集去重",
+			notify: config.Notify{
+				Userlist: []string{"@all"},
+				Mention: []config.MentionRule{
+					{Task: "redis", Users: []string{"zhangsan", "lisi"}},
+					{Severity: "critical", Users: []string{"lisi", "wangwu"}},
+				},
+			},
+			task:     "redis",
+			severity: SeverityCritical,
+			want:     []string{"zhangsan", "lisi", "wangwu"},
+		},
+		{
+			name: "配置了规则但都不命中时不@人",
+			notify: config.Notify{
+				Userlist: []string{"@all"},
+				Mention:  []config.MentionRule{{Task: "es", Users: []string{"zhangsan"}}},
+			},
+			task:     "redis",
+			severity: SeverityCritical,
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		config.Apply(&config.CfgType{Global: config.Global{Notify: tt.notify}})
+		if got := Mentions(tt.task, tt.severity); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Mentions = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -4,6 +4,9 @@
 package notify
 
 import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
 	"time"
 	"vhagar/config"
 	"vhagar/libs"
@@ -15,22 +18,157 @@ type Notifier struct {
 
 const wechatRobotURL = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key="
 
+// 投递状态
+const (
+	DeliveryQueued = "queued"
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+	DeliveryOutbox = "outbox" // 投递失败，已写入发件箱等待补发
+)
+
+// Delivery 一条消息对一个机器人的投递记录，由投递队列异步更新
+type Delivery struct {
+	mu       sync.Mutex
+	Task     string    `json:"task"`
+	RobotKey string    `json:"robotKey"`
+	Status   string    `json:"status"`
+	ErrCode  int       `json:"errCode,omitempty"`
+	ErrMsg   string    `json:"errMsg,omitempty"`
+	Attempts int       `json:"attempts"`
+	Time     time.Time `json:"time"`
+}
+
+// Failed 投递失败（包括已进入发件箱），排队中不算失败
+func (d *Delivery) Failed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.Status == DeliveryFailed || d.Status == DeliveryOutbox
+}
+
+// MarshalJSON 加锁读取，避免与投递队列并发写冲突
+func (d *Delivery) MarshalJSON() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return json.Marshal(&struct {
+		Task     string    `json:"task"`
+		RobotKey string    `json:"robotKey"`
+		Status   string    `json:"status"`
+		ErrCode  int       `json:"errCode,omitempty"`
+		ErrMsg   string    `json:"errMsg,omitempty"`
+		Attempts int       `json:"attempts"`
+		Time     time.Time `json:"time"`
+	}{d.Task, d.RobotKey, d.Status, d.ErrCode, d.ErrMsg, d.Attempts, d.Time})
+}
+
+var (
+	deliveryMu sync.Mutex
+	deliveries = map[string][]*Delivery{}
+	// spread 定时调度时开启，报告在 [notify] window 内错峰发送
+	spread atomic.Bool
+)
+
+// EnableSpread 开启错峰发送，由定时调度启动时调用
+func EnableSpread() {
+	spread.Store(true)
+}
+
+// Send 将报告放入投递队列后立即返回，不阻塞任务。
+// 开启错峰时每条报告在窗口内单独取随机发送时间，避免大量部署同时推送
 func Send(markdown *WeChatMarkdown, taskName string) {
-	libs.Logger.Infow("任务等待时间", "duration", config.Config.Duration)
-	time.Sleep(config.Config.Duration)
-	robotkey := getRobotkey(taskName)
-	//fmt.Println("robotkey", robotkey)
-	for _, robotkey := range robotkey {
-		err := sendWecom(markdown, robotkey, config.Config.ProxyURL)
-		if err != nil {
-			libs.Logger.Errorw("发送失败", "err", err)
+	// 汇总模式下报告暂存，由汇总报告统一发送
+	if hold(markdown, taskName) {
+		libs.Logger.Infow("报告已暂存，等待汇总", "task", taskName)
+		return
+	}
+	due := time.Now()
+	if spread.Load() {
+		due = due.Add(config.GetRandomDuration(config.Get().Notify.Window))
+	}
+	sendTo(markdown, taskName, getRobotkey(taskName), due)
+}
+
+// sendTo 按指定机器人列表入队，先放入发件箱中积压的消息，保证补发的消息先于新消息发送
+func sendTo(markdown *WeChatMarkdown, taskName string, robotkeys []string, due time.Time) {
+	ReplayOutbox()
+	libs.Logger.Infow("报告进入投递队列", "task", taskName, "due", due.Format("15:04:05"))
+	cfg := config.Get()
+	for _, robotkey := range robotkeys {
+		delivery := &Delivery{
+			Task:     taskName,
+			RobotKey: maskKey(robotkey),
+			Status:   DeliveryQueued,
+			Time:     time.Now(),
+		}
+		recordDelivery(delivery)
+		queue.enqueue(&job{
+			markdown:   markdown,
+			robotKey:   robotkey,
+			due:        due,
+			delivery:   delivery,
+			proxyURL:   cfg.ProxyURL,
+			maxRetries: cfg.Notify.MaxRetries,
+		})
+	}
+}
+
+// deliver 发送到单个机器人并更新投递记录，失败时按错误类型决定是否写入发件箱
+func deliver(j *job) {
+	delivery := j.delivery
+	attempts, err := sendWecomWithRetry(j.markdown, j.robotKey, j.proxyURL, j.maxRetries)
+	delivery.mu.Lock()
+	defer delivery.mu.Unlock()
+	delivery.Attempts = attempts
+	delivery.Time = time.Now()
+	if err == nil {
+		delivery.Status = DeliverySent
+		if j.outboxFile != "" {
+			libs.Logger.Warnw("补发成功", "task", delivery.Task, "robotkey", delivery.RobotKey)
+			finishReplay(j.outboxFile, true)
+		}
+		return
+	}
+	delivery.Status = DeliveryFailed
+	delivery.ErrMsg = err.Error()
+	if wecomErr, ok := err.(*WecomError); ok {
+		delivery.ErrCode = wecomErr.ErrCode
+		delivery.ErrMsg = wecomErr.ErrMsg
+	}
+	libs.Logger.Errorw("发送失败", "task", delivery.Task, "robotkey", delivery.RobotKey, "attempts", attempts, "err", err)
+	if j.outboxFile != "" {
+		// 网络仍不可用时留在发件箱，其他错误无法通过重试恢复，丢弃
+		if isRetryable(err) {
+			delivery.Status = DeliveryOutbox
+		}
+		finishReplay(j.outboxFile, !isRetryable(err))
+		return
+	}
+	if isRetryable(err) {
+		if saveErr := saveOutbox(j.markdown, j.robotKey, delivery.Task, err); saveErr != nil {
+			libs.Logger.Errorw("写入发件箱失败", "task", delivery.Task, "err", saveErr)
+		} else {
+			delivery.Status = DeliveryOutbox
 		}
 	}
 }
 
+func recordDelivery(delivery *Delivery) {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+	deliveries[delivery.Task] = append(deliveries[delivery.Task], delivery)
+}
+
+// TakeDeliveries 取出并清空任务的投递记录，由任务运行结束时汇总
+func TakeDeliveries(taskName string) []*Delivery {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+	list := deliveries[taskName]
+	delete(deliveries, taskName)
+	return list
+}
+
 func getRobotkey(taskName string) []string {
-	if notifier, ok := config.Config.Notify.Notifier[taskName]; ok {
+	if notifier, ok := config.Get().Notify.Notifier[taskName]; ok {
 		return notifier.Robotkey
 	}
-	return config.Config.Notify.Robotkey
+	return config.Get().Notify.Robotkey
 }
@@ -0,0 +1,135 @@
+// Package notify @Author lanpang
+// @Date 2025/7/28 上午10:20:00
+// @Desc 本地发件箱，断网期间未送达的报告落盘，恢复后补发
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+)
+
+const defaultOutboxDir = "outbox"
+
+var (
+	replayMu  sync.Mutex
+	replaying = map[string]bool{} // 已放回投递队列、尚未发送完的发件箱文件
+)
+
+// outboxItem 落盘的待补发消息
+type outboxItem struct {
+	Task      string          `json:"task"`
+	RobotKey  string          `json:"robotKey"`
+	Markdown  *WeChatMarkdown `json:"markdown"`
+	CreatedAt time.Time       `json:"createdAt"`
+	LastError string          `json:"lastError"`
+}
+
+func outboxDir() string {
+	if dir := config.Get().Notify.OutboxDir; dir != "" {
+		return dir
+	}
+	return defaultOutboxDir
+}
+
+// saveOutbox 将投递失败的消息写入发件箱
+func saveOutbox(markdown *WeChatMarkdown, robotKey, taskName string, sendErr error) error {
+	dir := outboxDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	item := outboxItem{
+		Task:      taskName,
+		RobotKey:  robotKey,
+		Markdown:  markdown,
+		CreatedAt: time.Now(),
+		LastError: sendErr.Error(),
+	}
+	data, err := json.Marshal(&item)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.json", item.CreatedAt.UnixNano(), taskName)
+	tmp := filepath.Join(dir, "."+name)
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, name))
+}
+
+// ReplayOutbox 将发件箱中的消息放回各自机器人的投递队列，到期时间为原始落盘时间，
+// 因此先于新消息发送，并和新消息一样遵守机器人限流。已在队列中的消息不会重复放入
+func ReplayOutbox() {
+	replayMu.Lock()
+	defer replayMu.Unlock()
+
+	dir := outboxDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			libs.Logger.Errorw("读取发件箱失败", "dir", dir, "err", err)
+		}
+		return
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		files = append(files, entry.Name())
+	}
+	sort.Strings(files)
+
+	cfg := config.Get()
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		if replaying[path] {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			libs.Logger.Errorw("读取发件箱消息失败", "file", path, "err", err)
+			continue
+		}
+		var item outboxItem
+		if err := json.Unmarshal(data, &item); err != nil || item.Markdown == nil || item.Markdown.Markdown == nil {
+			libs.Logger.Errorw("发件箱消息格式错误，已丢弃", "file", path, "err", err)
+			_ = os.Remove(path)
+			continue
+		}
+		markdown := &WeChatMarkdown{
+			MsgType: item.Markdown.MsgType,
+			Markdown: &Markdown{
+				Content: fmt.Sprintf("> <font color='comment'>补发消息，原始时间：%s</font>\n%s",
+					item.CreatedAt.Format("2006-01-02 15:04:05"), item.Markdown.Markdown.Content),
+			},
+		}
+		replaying[path] = true
+		queue.enqueue(&job{
+			markdown:   markdown,
+			robotKey:   item.RobotKey,
+			due:        item.CreatedAt,
+			delivery:   &Delivery{Task: item.Task, RobotKey: maskKey(item.RobotKey), Status: DeliveryQueued, Time: time.Now()},
+			proxyURL:   cfg.ProxyURL,
+			maxRetries: 1, // 已经重试过，网络仍不可用时留在发件箱等待下次补发
+			outboxFile: path,
+		})
+	}
+}
+
+// finishReplay 补发结束，remove 为 true 时删除发件箱文件，否则等待下次补发
+func finishReplay(path string, remove bool) {
+	replayMu.Lock()
+	defer replayMu.Unlock()
+	delete(replaying, path)
+	if remove {
+		_ = os.Remove(path)
+	}
+}
@@ -0,0 +1,187 @@
+// Package notify @Author lanpang
+// @Date 2025/7/29 下午2:10:00
+// @Desc 非阻塞投递队列：按机器人分队列，在发送窗口内错峰并遵守机器人限流
+package notify
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+)
+
+const defaultRateLimit = 20 // 企微机器人限制每分钟 20 条
+
+// errShutdown 进程退出时仍未发送的消息写入发件箱的原因
+var errShutdown = errors.New("进程退出时尚未发送")
+
+// job 一条待投递的消息。代理和重试次数在入队时取自当前配置，
+// 多环境巡检切换配置后，已入队的报告仍按所属环境的配置发送
+type job struct {
+	markdown   *WeChatMarkdown
+	robotKey   string
+	due        time.Time
+	delivery   *Delivery
+	proxyURL   string
+	maxRetries int
+	outboxFile string // 从发件箱补发的消息对应的文件
+}
+
+// robotQueue 单个机器人的待发送消息，signal 通知 worker 有新消息
+type robotQueue struct {
+	jobs   []*job
+	signal chan struct{}
+}
+
+// pop 取出最早到期且已到期的消息，没有到期的消息时返回距最早到期的等待时间，队列为空时为 0
+func (q *robotQueue) pop(now time.Time) (*job, time.Duration) {
+	if len(q.jobs) == 0 {
+		return nil, 0
+	}
+	next := 0
+	for i, j := range q.jobs {
+		if j.due.Before(q.jobs[next].due) {
+			next = i
+		}
+	}
+	j := q.jobs[next]
+	if wait := j.due.Sub(now); wait > 0 {
+		return nil, wait
+	}
+	q.jobs = append(q.jobs[:next], q.jobs[next+1:]...)
+	return j, 0
+}
+
+// dispatcher 每个机器人一个队列和 worker，互不阻塞
+type dispatcher struct {
+	mu     sync.Mutex
+	queues map[string]*robotQueue
+	wg     sync.WaitGroup
+	closed bool
+	done   chan struct{}
+}
+
+var queue = &dispatcher{queues: map[string]*robotQueue{}, done: make(chan struct{})}
+
+// enqueue 投递任务入队，立即返回。队列已关闭时直接写入发件箱
+func (d *dispatcher) enqueue(j *job) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		toOutbox(j, errShutdown)
+		return
+	}
+	q, ok := d.queues[j.robotKey]
+	if !ok {
+		q = &robotQueue{signal: make(chan struct{}, 1)}
+		d.queues[j.robotKey] = q
+		go d.worker(q)
+	}
+	q.jobs = append(q.jobs, j)
+	d.wg.Add(1)
+	d.mu.Unlock()
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// worker 按到期时间顺序发送，同一机器人两次发送之间至少间隔 60s/rateLimit
+func (d *dispatcher) worker(q *robotQueue) {
+	var last time.Time
+	for {
+		d.mu.Lock()
+		if d.closed {
+			d.mu.Unlock()
+			return
+		}
+		j, wait := q.pop(time.Now())
+		d.mu.Unlock()
+		if j == nil {
+			var timer *time.Timer
+			var timeout <-chan time.Time
+			if wait > 0 {
+				timer = time.NewTimer(wait)
+				timeout = timer.C
+			}
+			select {
+			case <-q.signal:
+			case <-timeout:
+			case <-d.done:
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			continue
+		}
+		if wait := time.Until(last.Add(sendInterval())); wait > 0 {
+			time.Sleep(wait)
+		}
+		deliver(j)
+		last = time.Now()
+		d.wg.Done()
+	}
+}
+
+// sendInterval 单个机器人的最小发送间隔
+func sendInterval() time.Duration {
+	limit := config.Get().Notify.RateLimit
+	if limit <= 0 {
+		limit = defaultRateLimit
+	}
+	return time.Minute / time.Duration(limit)
+}
+
+// toOutbox 将未发送的消息写入发件箱，下次启动或网络恢复后补发
+func toOutbox(j *job, reason error) {
+	delivery := j.delivery
+	delivery.mu.Lock()
+	defer delivery.mu.Unlock()
+	delivery.Time = time.Now()
+	// 补发的消息仍在发件箱中，不需要重复写入
+	if j.outboxFile != "" {
+		delivery.Status = DeliveryOutbox
+		return
+	}
+	if err := saveOutbox(j.markdown, j.robotKey, delivery.Task, reason); err != nil {
+		libs.Logger.Errorw("写入发件箱失败", "task", delivery.Task, "err", err)
+		delivery.Status = DeliveryFailed
+		delivery.ErrMsg = err.Error()
+		return
+	}
+	delivery.Status = DeliveryOutbox
+	delivery.ErrMsg = reason.Error()
+}
+
+// Wait 阻塞直到队列中的消息全部投递完成，一次性命令退出前调用
+func Wait() {
+	queue.wg.Wait()
+}
+
+// Flush 停止投递队列，仍在等待错峰或限流的消息写入发件箱，等待正在发送的消息完成后返回。
+// 常驻进程退出前调用，避免丢失尚未到发送时间的报告
+func Flush() {
+	d := queue
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	close(d.done)
+	var pending []*job
+	for _, q := range d.queues {
+		pending = append(pending, q.jobs...)
+		q.jobs = nil
+	}
+	d.mu.Unlock()
+	if len(pending) > 0 {
+		libs.Logger.Warnw("投递队列中尚未发送的报告写入发件箱", "count", len(pending))
+	}
+	for _, j := range pending {
+		toOutbox(j, errShutdown)
+		d.wg.Done()
+	}
+	d.wg.Wait()
+}
@@ -6,12 +6,26 @@ package notify
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 	"vhagar/libs"
 )
 
+// 企微机器人接口错误码
+const (
+	wecomErrBusy      = -1    // 系统繁忙
+	wecomErrRateLimit = 45009 // 接口调用超过限制
+)
+
+// 投递重试参数
+const (
+	defaultMaxRetries = 3
+	retryBackoff      = 5 * time.Second
+)
+
 type WeChatMarkdown struct {
 	MsgType  string    `json:"msgtype"`
 	Markdown *Markdown `json:"markdown"`
@@ -21,6 +35,62 @@ type Markdown struct {
 	Content string `json:"content"`
 }
 
+// wecomResponse 企微机器人接口返回体
+type wecomResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// WecomError 企微机器人接口返回的业务错误或 HTTP 错误
+type WecomError struct {
+	StatusCode int
+	ErrCode    int
+	ErrMsg     string
+}
+
+func (e *WecomError) Error() string {
+	if e.StatusCode != http.StatusOK {
+		return fmt.Sprintf("企微机器人 HTTP 状态异常: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("企微机器人返回错误: errcode=%d errmsg=%s", e.ErrCode, e.ErrMsg)
+}
+
+// Retryable 限流、系统繁忙以及服务端错误可以重试，其余错误（如 key 无效）重试无意义
+func (e *WecomError) Retryable() bool {
+	if e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return e.ErrCode == wecomErrRateLimit || e.ErrCode == wecomErrBusy
+}
+
+// isRetryable 网络错误和可重试的企微错误返回 true
+func isRetryable(err error) bool {
+	if wecomErr, ok := err.(*WecomError); ok {
+		return wecomErr.Retryable()
+	}
+	return err != nil
+}
+
+// sendWecomWithRetry 带退避的重试发送，返回实际尝试次数
+func sendWecomWithRetry(markdown *WeChatMarkdown, robotKey, proxyURL string, maxRetries int) (int, error) {
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	var err error
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		err = sendWecom(markdown, robotKey, proxyURL)
+		if err == nil || !isRetryable(err) {
+			return attempt, err
+		}
+		if attempt < maxRetries {
+			backoff := retryBackoff * time.Duration(1<<(attempt-1))
+			libs.Logger.Warnw("推送企微机器人失败，等待重试", "robotkey", maskKey(robotKey), "attempt", attempt, "backoff", backoff, "err", err)
+			time.Sleep(backoff)
+		}
+	}
+	return maxRetries, err
+}
+
 func sendWecom(markdown *WeChatMarkdown, robotKey, proxyURL string) error {
 	jsonStr, _ := json.Marshal(markdown)
 	robotURL := wechatRobotURL + robotKey
@@ -31,7 +101,7 @@ func sendWecom(markdown *WeChatMarkdown, robotKey, proxyURL string) error {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
+	client := &http.Client{Timeout: 10 * time.Second}
 
 	if proxyURL != "" {
 		proxy, err := url.Parse(proxyURL)
@@ -39,6 +109,7 @@ func sendWecom(markdown *WeChatMarkdown, robotKey, proxyURL string) error {
 			return err
 		}
 		client = &http.Client{
+			Timeout: 10 * time.Second,
 			Transport: &http.Transport{
 				Proxy: http.ProxyURL(proxy),
 			},
@@ -56,7 +127,29 @@ func sendWecom(markdown *WeChatMarkdown, robotKey, proxyURL string) error {
 			libs.Logger.Errorw("Failed info", "err", err)
 		}
 	}(resp.Body)
-	libs.Logger.Warnw("推送企微机器人 response Status", "status", resp.Status)
-	//log.Print("response Headers:", resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		return &WecomError{StatusCode: resp.StatusCode}
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var result wecomResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析企微机器人响应失败: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return &WecomError{StatusCode: resp.StatusCode, ErrCode: result.ErrCode, ErrMsg: result.ErrMsg}
+	}
+	libs.Logger.Warnw("推送企微机器人成功", "robotkey", maskKey(robotKey))
 	return nil
 }
+
+// maskKey 日志和结果中只保留机器人 key 的首尾
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "****" + key[len(key)-4:]
+}
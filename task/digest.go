@@ -0,0 +1,183 @@
+// Package task @Author lanpang
+// @Date 2025/8/1 上午10:30:00
+// @Desc 汇总报告：窗口内完成的定时任务合并为一条机器人消息
+package task
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"vhagar/chat"
+	"vhagar/config"
+	"vhagar/libs"
+	"vhagar/notify"
+)
+
+const (
+	digestTaskName      = "digest"
+	defaultDigestWindow = 30 * time.Minute
+	// 企微 markdown 消息上限 4096 字节，留出余量
+	maxMarkdownBytes = 4000
+)
+
+var headingPattern = regexp.MustCompile(`(?m)^#+ `)
+
+type digest struct {
+	mu      sync.Mutex
+	results []*Result
+	timer   *time.Timer
+}
+
+var currentDigest = &digest{}
+
+// AddToDigest 加入汇总，窗口内第一个结果开始计时，窗口结束后发送
+func AddToDigest(result *Result) {
+	currentDigest.mu.Lock()
+	defer currentDigest.mu.Unlock()
+	currentDigest.results = append(currentDigest.results, result)
+	if currentDigest.timer == nil {
+		window := config.Get().Digest.Window
+		if window <= 0 {
+			window = defaultDigestWindow
+		}
+		libs.Logger.Warnw("开始收集汇总报告", "window", window)
+		currentDigest.timer = time.AfterFunc(window, currentDigest.flush)
+	}
+}
+
+func (d *digest) flush() {
+	d.mu.Lock()
+	results := d.results
+	d.results = nil
+	d.timer = nil
+	d.mu.Unlock()
+	if len(results) == 0 {
+		return
+	}
+	for _, markdown := range digestMarkdown(results) {
+		notify.Send(markdown, digestTaskName)
+	}
+}
+
+// digestMarkdown 组装汇总报告：整体健康横幅、可选 AI 总结、每个任务一节，超长时拆分为多条
+func digestMarkdown(results []*Result) []*notify.WeChatMarkdown {
+	var head strings.Builder
+	head.WriteString("# 巡检汇总报告\n")
+	head.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
+	head.WriteString("**巡检时间：**<font color='info'>" + time.Now().Format("2006-01-02 15:04") + "</font>\n")
+	head.WriteString(healthBanner(results))
+
+	var sections []string
+	var plain strings.Builder
+	for _, result := range results {
+		section := digestSection(result)
+		sections = append(sections, section)
+		plain.WriteString(section)
+	}
+
+	if config.Get().Digest.AI && config.Get().AI.Enable && config.Get().AI.Provider != "" {
+		summary, err := chat.Summarize(context.Background(), plain.String())
+		if err != nil {
+			libs.Logger.Errorw("汇总报告 AI 总结失败", "err", err)
+		} else {
+			head.WriteString("**AI 总结：**\n" + summary + "\n")
+		}
+	}
+	head.WriteString("==================\n")
+
+	var markdownList []*notify.WeChatMarkdown
+	builder := strings.Builder{}
+	builder.WriteString(truncate(head.String(), maxMarkdownBytes))
+	for _, section := range sections {
+		section = truncate(section, maxMarkdownBytes)
+		if builder.Len()+len(section) > maxMarkdownBytes {
+			markdownList = append(markdownList, newMarkdown(builder.String()))
+			builder.Reset()
+			builder.WriteString("# 巡检汇总报告（续）\n")
+		}
+		builder.WriteString(section)
+	}
+	markdownList = append(markdownList, newMarkdown(builder.String()))
+	return markdownList
+}
+
+// healthBanner 整体健康状态，按最高告警级别着色
+func healthBanner(results []*Result) string {
+	var failed, critical, warning, ok int
+	for _, result := range results {
+		switch {
+		case result.Error != "":
+			failed++
+		case result.Severity == notify.SeverityCritical:
+			critical++
+		case result.Severity == notify.SeverityWarning:
+			warning++
+		default:
+			ok++
+		}
+	}
+	status, color := "健康", "info"
+	if warning > 0 {
+		status, color = "存在一般问题", "warning"
+	}
+	if critical > 0 || failed > 0 {
+		status, color = "存在严重问题", "red"
+	}
+	return fmt.Sprintf("**整体状态：**<font color='%s'>%s</font>（正常 %d，一般 %d，严重 %d，执行失败 %d）\n",
+		color, status, ok, warning, critical, failed)
+}
+
+// digestSection 单个任务一节：状态、问题列表和原始报告内容（标题降级）
+func digestSection(result *Result) string {
+	var builder strings.Builder
+	status, color := "正常", "info"
+	switch {
+	case result.Error != "":
+		status, color = "执行失败", "red"
+	case result.Severity == notify.SeverityCritical:
+		status, color = "严重", "red"
+	case result.Severity == notify.SeverityWarning:
+		status, color = "一般", "warning"
+	}
+	builder.WriteString(fmt.Sprintf("## %s <font color='%s'>%s</font>\n", result.Task, color, status))
+	if result.Error != "" {
+		builder.WriteString("> " + result.Error + "\n")
+	}
+	for _, finding := range result.Findings {
+		builder.WriteString(fmt.Sprintf("> [%s] %s\n", finding.Severity, finding.Message))
+	}
+	for _, report := range result.Reports {
+		builder.WriteString(headingPattern.ReplaceAllString(report, "#### "))
+		if !strings.HasSuffix(report, "\n") {
+			builder.WriteString("\n")
+		}
+	}
+	builder.WriteString("==================\n")
+	return builder.String()
+}
+
+func newMarkdown(content string) *notify.WeChatMarkdown {
+	return &notify.WeChatMarkdown{
+		MsgType: "markdown",
+		Markdown: &notify.Markdown{
+			Content: content,
+		},
+	}
+}
+
+// truncate 按字节截断，保证不截断 UTF-8 字符
+func truncate(content string, limit int) string {
+	if len(content) <= limit {
+		return content
+	}
+	suffix := "\n...（内容过长已截断）\n"
+	cut := limit - len(suffix)
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut] + suffix
+}
@@ -0,0 +1,261 @@
+// Package domain @Author lanpang
+// @Date 2025/8/29 上午10:00:00
+// @Desc 域名解析检查：A/AAAA/CNAME、解析耗时、解析器之间的一致性和固定 IP/CIDR
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+	"vhagar/notify"
+	"vhagar/task"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	defaultDNSTimeout = 3 * time.Second
+	systemResolver    = "system"
+)
+
+// DNSResult 一个解析器的解析结果
+type DNSResult struct {
+	Resolver string        `json:"resolver"`
+	CNAME    string        `json:"cname,omitempty"`
+	A        []string      `json:"a,omitempty"`
+	AAAA     []string      `json:"aaaa,omitempty"`
+	Latency  time.Duration `json:"latency"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// addrs A 和 AAAA 记录
+func (r DNSResult) addrs() []string {
+	return append(append([]string(nil), r.A...), r.AAAA...)
+}
+
+// DNSInfo 一个域名在所有解析器上的结果
+type DNSInfo struct {
+	Results      []DNSResult `json:"results"`
+	Failed       bool        `json:"failed"`                 // 所有解析器都解析失败
+	Inconsistent bool        `json:"inconsistent,omitempty"` // 解析器返回的地址没有交集
+	Unexpected   []string    `json:"unexpected,omitempty"`   // 不在固定 IP/CIDR 中的地址
+}
+
+// resolver 解析器名称和对应的 net.Resolver
+type resolver struct {
+	name     string
+	resolver *net.Resolver
+}
+
+// newResolvers 按配置创建解析器，未配置时使用系统解析
+func newResolvers(servers []string) []resolver {
+	if len(servers) == 0 {
+		return []resolver{{name: systemResolver, resolver: net.DefaultResolver}}
+	}
+	var resolvers []resolver
+	for _, server := range servers {
+		address := server
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			address = net.JoinHostPort(server, "53")
+		}
+		resolvers = append(resolvers, resolver{
+			name: server,
+			resolver: &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, network, address)
+				},
+			},
+		})
+	}
+	return resolvers
+}
+
+// resolve 使用一个解析器查询 CNAME 和 A/AAAA 记录
+func (r resolver) resolve(host string, timeout time.Duration) DNSResult {
+	result := DNSResult{Resolver: r.name}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	addrs, err := r.resolver.LookupIPAddr(ctx, host)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = dnsError(err)
+		return result
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			result.A = append(result.A, addr.IP.String())
+		} else {
+			result.AAAA = append(result.AAAA, addr.IP.String())
+		}
+	}
+	slices.Sort(result.A)
+	slices.Sort(result.AAAA)
+	if cname, err := r.resolver.LookupCNAME(ctx, host); err == nil {
+		cname = strings.TrimSuffix(cname, ".")
+		if !strings.EqualFold(cname, host) {
+			result.CNAME = cname
+		}
+	}
+	return result
+}
+
+// dnsError 区分域名不存在、超时和其他解析错误
+func dnsError(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return "域名不存在 (NXDOMAIN)"
+		case dnsErr.IsTimeout:
+			return "解析超时"
+		}
+		return dnsErr.Err
+	}
+	return err.Error()
+}
+
+// parsePin 解析列表文件中固定的 IP 或 CIDR
+func parsePin(value string) (netip.Prefix, bool) {
+	if prefix, err := netip.ParsePrefix(value); err == nil {
+		return prefix.Masked(), true
+	}
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+	return netip.Prefix{}, false
+}
+
+// pinned 地址是否在固定的 IP/CIDR 中
+func pinned(addr string, pins []string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	for _, pin := range pins {
+		if prefix, ok := parsePin(pin); ok && prefix.Contains(ip.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// inspectDNS 在所有解析器上解析域名，比较结果并检查固定地址
+func inspectDNS(host string, pins []string, resolvers []resolver, timeout time.Duration) *DNSInfo {
+	info := &DNSInfo{Failed: true}
+	var sets [][]string
+	for _, r := range resolvers {
+		result := r.resolve(host, timeout)
+		info.Results = append(info.Results, result)
+		if result.Error != "" {
+			continue
+		}
+		info.Failed = false
+		sets = append(sets, result.addrs())
+		if len(pins) == 0 {
+			continue
+		}
+		for _, addr := range result.addrs() {
+			if !pinned(addr, pins) && !slices.Contains(info.Unexpected, addr) {
+				info.Unexpected = append(info.Unexpected, addr)
+			}
+		}
+	}
+	// 任意两个解析器的结果没有相同地址视为不一致，CDN 域名在不同解析器上的部分地址不同属于正常
+	for i := 0; i < len(sets) && !info.Inconsistent; i++ {
+		for j := i + 1; j < len(sets); j++ {
+			if !slices.ContainsFunc(sets[i], func(addr string) bool { return slices.Contains(sets[j], addr) }) {
+				info.Inconsistent = true
+				break
+			}
+		}
+	}
+	return info
+}
+
+// dnsFindings 解析问题，全部解析失败且不通的域名在连通性中报告
+func (d *Domainer) dnsFindings() []notify.Finding {
+	var findings []notify.Finding
+	maxLatency := d.Config.Domain.DNS.MaxLatency
+	alive := map[string]bool{}
+	for _, domain := range d.Domains {
+		alive[domain.Name] = alive[domain.Name] || domain.IsAlive
+	}
+	seen := map[string]bool{}
+	for _, domain := range d.Domains {
+		info := domain.DNS
+		if info == nil || seen[domain.Name] {
+			continue
+		}
+		seen[domain.Name] = true
+		var failed, slow []string
+		for _, result := range info.Results {
+			if result.Error != "" {
+				failed = append(failed, result.Resolver+": "+result.Error)
+			} else if maxLatency > 0 && result.Latency > maxLatency {
+				slow = append(slow, fmt.Sprintf("%s: %s", result.Resolver, result.Latency.Round(time.Millisecond)))
+			}
+		}
+		// 未配置解析器时使用系统解析，通过代理访问的域名本地解析失败属于正常
+		if info.Failed && alive[domain.Name] && len(d.Config.Domain.DNS.Resolvers) > 0 {
+			findings = append(findings, notify.Finding{Key: "dns_failed:" + domain.Name, Severity: notify.SeverityWarning, Message: fmt.Sprintf("配置的解析器均解析失败，系统解析可访问: %s: %s", domain.Name, strings.Join(failed, "; "))})
+		}
+		if !info.Failed && len(failed) > 0 {
+			findings = append(findings, notify.Finding{Key: "dns_partial:" + domain.Name, Severity: notify.SeverityWarning, Message: fmt.Sprintf("部分解析器解析失败: %s: %s", domain.Name, strings.Join(failed, "; "))})
+		}
+		if len(slow) > 0 {
+			findings = append(findings, notify.Finding{Key: "dns_latency:" + domain.Name, Severity: notify.SeverityWarning, Message: fmt.Sprintf("解析耗时超过 %s: %s: %s", maxLatency, domain.Name, strings.Join(slow, "; "))})
+		}
+		if info.Inconsistent {
+			var parts []string
+			for _, result := range info.Results {
+				if result.Error == "" {
+					parts = append(parts, result.Resolver+" → "+strings.Join(result.addrs(), ","))
+				}
+			}
+			findings = append(findings, notify.Finding{Key: "dns_inconsistent:" + domain.Name, Severity: notify.SeverityWarning, Message: fmt.Sprintf("解析器结果不一致: %s: %s", domain.Name, strings.Join(parts, "; "))})
+		}
+		if len(info.Unexpected) > 0 {
+			findings = append(findings, notify.Finding{Key: "dns_unexpected:" + domain.Name, Severity: notify.SeverityCritical, Message: fmt.Sprintf("解析到非预期地址: %s: %s，预期 %s", domain.Name, strings.Join(info.Unexpected, ","), strings.Join(domain.Pins, ","))})
+		}
+	}
+	return findings
+}
+
+// renderDNS 解析结果表格
+func (d *Domainer) renderDNS() {
+	table := tablewriter.NewWriter(task.GetOutputWriter())
+	table.SetHeader([]string{"域名", "解析器", "CNAME", "A", "AAAA", "耗时", "错误"})
+	rows := 0
+	seen := map[string]bool{}
+	for _, domain := range d.Domains {
+		if domain.DNS == nil || seen[domain.Name] {
+			continue
+		}
+		seen[domain.Name] = true
+		for _, result := range domain.DNS.Results {
+			rows++
+			table.Append([]string{
+				domain.Name,
+				result.Resolver,
+				result.CNAME,
+				strings.Join(result.A, "\n"),
+				strings.Join(result.AAAA, "\n"),
+				result.Latency.Round(time.Millisecond).String(),
+				result.Error,
+			})
+		}
+	}
+	if rows == 0 {
+		return
+	}
+	table.SetCaption(true, "域名解析检查")
+	table.Render()
+}
@@ -0,0 +1,196 @@
+package domain
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"vhagar/config"
+)
+
+func TestParsePin(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"10.0.0.1", "10.0.0.1/32", true},
+		{"10.0.0.0/8", "10.0.0.0/8", true},
+		{"10.1.2.3/16", "10.1.0.0/16", true},
+		{"2001:db8::1", "2001:db8::1/128", true},
+		{"2001:db8::/32", "2001:db8::/32", true},
+		{"example.com", "", false},
+		{"10.0.0.0/33", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePin(tt.value)
+		if ok != tt.ok || (ok && got.String() != tt.want) {
+			t.Errorf("parsePin(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPinned(t *testing.T) {
+	pins := []string{"10.0.0.1", "192.168.0.0/16", "2001:db8::/32", "invalid"}
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.0.0.1", true},
+		{"10.0.0.2", false},
+		{"192.168.10.20", true},
+		{"::ffff:192.168.1.1", true},
+		{"2001:db8::abcd", true},
+		{"2001:db9::1", false},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		if got := pinned(tt.addr, pins); got != tt.want {
+			t.Errorf("pinned(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+	if pinned("10.0.0.1", nil) {
+		t.Error("没有固定地址时不应命中")
+	}
+}
+
+// fakeDNS 启动本地 UDP 解析服务，按 records 应答 A/AAAA，未配置的域名返回 NXDOMAIN，返回服务地址
+func fakeDNS(t *testing.T, records map[string][]string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply := dnsReply(buf[:n], records); reply != nil {
+				conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// dnsReply 构造应答：复制请求的 ID 和问题，回答使用指向问题中域名的压缩指针
+func dnsReply(query []byte, records map[string][]string) []byte {
+	var labels []string
+	i := 12
+	for i < len(query) && query[i] != 0 {
+		size := int(query[i])
+		if i+1+size > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[i+1:i+1+size]))
+		i += size + 1
+	}
+	end := i + 5
+	if end > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[i+1 : i+3])
+	addrs, found := records[strings.ToLower(strings.Join(labels, "."))]
+
+	var answers [][]byte
+	for _, value := range addrs {
+		addr := netip.MustParseAddr(value)
+		if (qtype == 1 && addr.Is4()) || (qtype == 28 && addr.Is6()) {
+			answers = append(answers, addr.AsSlice())
+		}
+	}
+	reply := make([]byte, 12, 512)
+	copy(reply, query[:2])
+	reply[2], reply[3] = 0x81, 0x80 // QR、RD、RA
+	if !found {
+		reply[3] |= 3 // NXDOMAIN
+	}
+	binary.BigEndian.PutUint16(reply[4:], 1)
+	binary.BigEndian.PutUint16(reply[6:], uint16(len(answers)))
+	reply = append(reply, query[12:end]...)
+	for _, data := range answers {
+		reply = append(reply, 0xc0, 0x0c)
+		reply = binary.BigEndian.AppendUint16(reply, qtype)
+		reply = binary.BigEndian.AppendUint16(reply, 1)
+		reply = binary.BigEndian.AppendUint32(reply, 60)
+		reply = binary.BigEndian.AppendUint16(reply, uint16(len(data)))
+		reply = append(reply, data...)
+	}
+	return reply
+}
+
+func TestInspectDNS(t *testing.T) {
+	const host = "app.example.test"
+	primary := fakeDNS(t, map[string][]string{host: {"10.0.0.1", "10.0.0.2"}})
+	overlap := fakeDNS(t, map[string][]string{host: {"10.0.0.2", "10.0.0.3"}})
+	hijacked := fakeDNS(t, map[string][]string{host: {"1.2.3.4", "2001:db8::1"}})
+	empty := fakeDNS(t, map[string][]string{})
+
+	tests := []struct {
+		name         string
+		servers      []string
+		pins         []string
+		failed       bool
+		inconsistent bool
+		unexpected   []string
+	}{
+		{"单个解析器", []string{primary}, nil, false, false, nil},
+		{"部分地址相同视为一致", []string{primary, overlap}, nil, false, false, nil},
+		{"地址没有交集", []string{primary, hijacked}, nil, false, true, nil},
+		{"部分解析器失败", []string{primary, empty}, nil, false, false, nil},
+		{"全部解析失败", []string{empty}, nil, true, false, nil},
+		{"固定地址", []string{primary, overlap}, []string{"10.0.0.0/31"}, false, false, []string{"10.0.0.2", "10.0.0.3"}},
+		{"非预期地址", []string{primary, hijacked}, []string{"10.0.0.1", "10.0.0.2"}, false, true, []string{"1.2.3.4", "2001:db8::1"}},
+	}
+	for _, tt := range tests {
+		info := inspectDNS(host, tt.pins, newResolvers(tt.servers), time.Second)
+		if info.Failed != tt.failed || info.Inconsistent != tt.inconsistent || !reflect.DeepEqual(info.Unexpected, tt.unexpected) {
+			t.Errorf("%s: failed=%v inconsistent=%v unexpected=%v, want %v %v %v (%+v)",
+				tt.name, info.Failed, info.Inconsistent, info.Unexpected, tt.failed, tt.inconsistent, tt.unexpected, info.Results)
+		}
+		if len(info.Results) != len(tt.servers) {
+			t.Errorf("%s: 结果数 %d，want %d", tt.name, len(info.Results), len(tt.servers))
+		}
+	}
+
+	info := inspectDNS(host, nil, newResolvers([]string{empty}), time.Second)
+	if got := info.Results[0].Error; got != "域名不存在 (NXDOMAIN)" {
+		t.Errorf("NXDOMAIN 错误为 %q", got)
+	}
+}
+
+func TestDNSFindings(t *testing.T) {
+	failed := &DNSInfo{Failed: true, Results: []DNSResult{{Resolver: "10.0.0.53", Error: "解析超时"}}}
+	tests := []struct {
+		name      string
+		resolvers []string
+		alive     bool
+		want      bool
+	}{
+		{"配置的解析器失败但可访问", []string{"10.0.0.53"}, true, true},
+		{"配置的解析器失败且不通", []string{"10.0.0.53"}, false, false},
+		{"未配置解析器", nil, true, false},
+	}
+	for _, tt := range tests {
+		cfg := &config.CfgType{}
+		cfg.Domain.DNS.Resolvers = tt.resolvers
+		d := &Domainer{Config: cfg, Domains: []*Domain{{Name: "app.example.test", Port: 443, IsAlive: tt.alive, DNS: failed}}}
+		got := false
+		for _, finding := range d.dnsFindings() {
+			if finding.Key == "dns_failed:app.example.test" {
+				got = true
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: dns_failed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
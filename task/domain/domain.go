@@ -5,8 +5,8 @@ package domain
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"net/url"
@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 	"vhagar/config"
 	"vhagar/libs"
@@ -26,8 +27,8 @@ import (
 var isalert = false
 
 func init() {
-	task.Add(taskName, func() task.Tasker {
-		return NewDomainer(config.Config, libs.Logger)
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewDomainer(cfg, libs.Logger)
 	})
 }
 
@@ -42,7 +43,7 @@ func (d *Domainer) Check() {
 
 // TableRender 表格方式展示结果
 func (d *Domainer) TableRender() {
-	tabletitle := []string{"域名", "端口", "连通状态"}
+	tabletitle := []string{"域名", "端口", "连通状态", "说明"}
 	table := tablewriter.NewWriter(task.GetOutputWriter())
 	table.SetHeader(tabletitle)
 
@@ -51,20 +52,30 @@ func (d *Domainer) TableRender() {
 		if domain.IsAlive {
 			status = "正常"
 		}
-		tabledata := []string{domain.Name, strconv.Itoa(domain.Port), status}
+		tabledata := []string{domain.Name, strconv.Itoa(domain.Port), status, domain.Reason}
 		table.Append(tabledata)
 	}
 
 	caption := fmt.Sprintf("总共检测 %d 个域名，%d 个正常，%d 个不通", d.TotalCount, d.AliveCount, d.FailedCount)
 	table.SetCaption(true, caption)
 	table.Render()
+
+	d.renderDNS()
+	d.renderTLS()
+	if findings := d.checkFindings(); len(findings) > 0 {
+		out := task.GetOutputWriter()
+		fmt.Fprintln(out, "警告:")
+		for _, finding := range findings {
+			fmt.Fprintf(out, "- [%s] %s\n", finding.Severity, finding.Message)
+		}
+	}
 }
 
 // ReportRobot 机器人方式发送报告
 func (d *Domainer) ReportRobot() {
-	// 发送巡检报告
+	// 发送巡检报告，有不通的域名、解析或证书问题时发送
 	isalert = d.FailedCount > 0
-	if isalert {
+	if isalert || len(d.checkFindings()) > 0 {
 		headString := headString()
 		markdown := domainMarkdown(headString, d)
 		notify.Send(markdown, taskName)
@@ -75,7 +86,9 @@ func (d *Domainer) ReportRobot() {
 func (d *Domainer) Gather() {
 	fileName := d.Config.DomainListName
 	if _, err := os.Stat(fileName); os.IsNotExist(err) {
-		log.Fatalf("配置文件不存在: %s", fileName)
+		// 常驻服务中由 API 触发，不能退出进程
+		d.Logger.Errorw("域名列表文件不存在", "file", fileName)
+		return
 	}
 	filePath := filepath.Join(".", fileName)
 	domains, err := readDomainListFile(filePath)
@@ -89,12 +102,40 @@ func (d *Domainer) Gather() {
 	d.AliveCount = 0
 	d.FailedCount = 0
 
-	// 创建一个map来跟踪每个域名的连通状态
-	domainStatusMap := make(map[string]bool)
+	if !d.Config.Domain.DNS.Disable {
+		d.gatherDNS(domains)
+	}
 
 	for _, domain := range domains {
-		isAlive := testConnection(domain.Name, domain.Port)
-		domain.IsAlive = isAlive
+		// 始终测试连接，解析结果只用于标注失败阶段：只有系统解析能解析的内网域名不会误报
+		if err := testConnection(domain.Name, domain.Port); err != nil {
+			domain.Reason = connReason(err)
+			resolvers := len(d.Config.Domain.DNS.Resolvers) > 0
+			switch {
+			case domain.DNS == nil || !resolvers:
+				// 未配置解析器时只有系统解析，连接失败的原因已包含解析阶段
+			case domain.DNS.Failed && domain.Reason != "DNS 解析失败":
+				// 配置的解析器都解析失败，系统解析成功但连接失败
+				domain.Reason += "（配置的解析器解析失败）"
+			case !domain.DNS.Failed && domain.Reason == "DNS 解析失败":
+				// 配置的解析器正常，连接使用的系统解析失败
+				domain.Reason = "DNS 解析失败（系统解析）"
+			}
+		} else {
+			domain.IsAlive = true
+		}
+		d.Domains = append(d.Domains, domain)
+	}
+
+	// 握手失败的 443 端口视为不通
+	if !d.Config.Domain.TLS.Disable {
+		d.gatherTLS()
+	}
+
+	// 创建一个map来跟踪每个域名的连通状态
+	domainStatusMap := make(map[string]bool)
+	for _, domain := range d.Domains {
+		isAlive := domain.IsAlive
 
 		// 更新域名状态映射
 		// 如果域名已经在映射中且为true，保持true
@@ -104,8 +145,6 @@ func (d *Domainer) Gather() {
 		} else {
 			domainStatusMap[domain.Name] = isAlive
 		}
-
-		d.Domains = append(d.Domains, domain)
 	}
 
 	// 根据域名状态映射更新计数
@@ -123,6 +162,112 @@ func (d *Domainer) Gather() {
 	d.Logger.Info("域名连通性检查完成")
 }
 
+// Findings 实现 task.Finder，所有端口都不通的域名为严重问题，并附带失败阶段
+func (d *Domainer) Findings() []notify.Finding {
+	alive := make(map[string]bool)
+	ports := make(map[string][]string)
+	reasons := make(map[string]string)
+	var names []string
+	for _, domain := range d.Domains {
+		if _, ok := alive[domain.Name]; !ok {
+			names = append(names, domain.Name)
+		}
+		alive[domain.Name] = alive[domain.Name] || domain.IsAlive
+		ports[domain.Name] = append(ports[domain.Name], strconv.Itoa(domain.Port))
+		if reasons[domain.Name] == "" {
+			reasons[domain.Name] = domain.Reason
+		}
+	}
+	var findings []notify.Finding
+	for _, name := range names {
+		if alive[name] {
+			continue
+		}
+		findings = append(findings, notify.Finding{
+			Key:      "domain:" + name,
+			Severity: notify.SeverityCritical,
+			Message:  fmt.Sprintf("域名不通（%s）: %s:%s", reasons[name], name, strings.Join(ports[name], ",")),
+		})
+	}
+	return append(findings, d.checkFindings()...)
+}
+
+// checkFindings 解析和证书检查发现的问题
+func (d *Domainer) checkFindings() []notify.Finding {
+	return append(d.dnsFindings(), d.tlsFindings()...)
+}
+
+// gatherDNS 按域名解析一次，结果由同一域名的各端口共用，IP 不解析
+func (d *Domainer) gatherDNS(domains []*Domain) {
+	cfg := d.Config.Domain.DNS
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultDNSTimeout
+	}
+	resolvers := newResolvers(cfg.Resolvers)
+	inspected := map[string]*DNSInfo{}
+	for _, domain := range domains {
+		if net.ParseIP(domain.Name) != nil {
+			continue
+		}
+		info, ok := inspected[domain.Name]
+		if !ok {
+			info = inspectDNS(domain.Name, domain.Pins, resolvers, timeout)
+			inspected[domain.Name] = info
+		}
+		domain.DNS = info
+	}
+	d.Logger.Infow("域名解析检查完成", "count", len(inspected), "resolvers", len(resolvers))
+}
+
+// connReason 连接失败的阶段
+func connReason(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return "DNS 解析失败"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "TCP 拒绝连接"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "TCP 连接超时"
+	}
+	return "连接失败: " + err.Error()
+}
+
+// gatherTLS 检查连通的 443 端口证书，握手失败时标记为不通，配置了 proxyurl 时通过代理连接
+func (d *Domainer) gatherTLS() {
+	dialer := &tlsDialer{timeout: d.Config.Domain.TLS.Timeout}
+	if dialer.timeout <= 0 {
+		dialer.timeout = defaultTLSTimeout
+	}
+	if d.Config.ProxyURL != "" {
+		proxy, err := url.Parse(d.Config.ProxyURL)
+		if err != nil {
+			d.Logger.Errorw("代理地址无效，跳过证书检查", "proxy", d.Config.ProxyURL, "err", err)
+			return
+		}
+		dialer.proxy = proxy
+	}
+	inspected := map[string]*TLSInfo{}
+	for _, domain := range d.Domains {
+		if domain.Port != 443 || !domain.IsAlive {
+			continue
+		}
+		info, ok := inspected[domain.Name]
+		if !ok {
+			info = dialer.inspectTLS(domain.Name, domain.Port)
+			inspected[domain.Name] = info
+		}
+		domain.TLS = info
+		if info.Error != "" {
+			domain.IsAlive = false
+			domain.Reason = "TLS 握手失败"
+		}
+	}
+	d.Logger.Infow("证书检查完成", "count", len(inspected))
+}
+
 // readDomainListFile 读取域名列表文件
 func readDomainListFile(filePath string) ([]*Domain, error) {
 	file, err := os.Open(filePath)
@@ -143,11 +288,16 @@ func readDomainListFile(filePath string) ([]*Domain, error) {
 		parts := strings.Fields(line)
 		domainName := parts[0]
 
-		// 处理多个端口的情况
+		// 处理多个端口的情况，IP 或 CIDR 为固定的解析地址
 		ports := []int{443} // 默认端口
+		var pins []string
 		if len(parts) > 1 {
 			ports = make([]int, 0)
 			for _, portStr := range parts[1:] {
+				if _, ok := parsePin(portStr); ok {
+					pins = append(pins, portStr)
+					continue
+				}
 				port, err := strconv.Atoi(portStr)
 				if err != nil {
 					libs.Logger.Errorf("Invalid port for domain %s: %s", domainName, portStr)
@@ -155,6 +305,10 @@ func readDomainListFile(filePath string) ([]*Domain, error) {
 				}
 				ports = append(ports, port)
 			}
+			// 只固定了地址时使用默认端口
+			if len(ports) == 0 && len(pins) > 0 {
+				ports = []int{443}
+			}
 		}
 
 		// 为每个端口创建一个域名记录
@@ -162,6 +316,7 @@ func readDomainListFile(filePath string) ([]*Domain, error) {
 			domain := &Domain{
 				Name: domainName,
 				Port: port,
+				Pins: pins,
 			}
 			domains = append(domains, domain)
 		}
@@ -174,22 +329,23 @@ func readDomainListFile(filePath string) ([]*Domain, error) {
 	return domains, nil
 }
 
-// testConnection 测试域名连通性
-func testConnection(domain string, port int) bool {
-	address := fmt.Sprintf("%s:%d", domain, port)
+// testConnection 测试域名连通性，返回最后一次尝试的错误，用于区分失败阶段
+func testConnection(domain string, port int) error {
+	address := net.JoinHostPort(domain, strconv.Itoa(port))
 	maxRetries := 3
 	retryDelay := 1 * time.Second
+	var lastErr error
 
 	// 如果有代理配置，使用代理连接
-	if config.Config.ProxyURL != "" {
+	if config.Get().ProxyURL != "" {
 		dialer := &net.Dialer{
 			Timeout:   5 * time.Second,
 			KeepAlive: 30 * time.Second,
 		}
-		proxyUrl, err := url.Parse(config.Config.ProxyURL)
+		proxyUrl, err := url.Parse(config.Get().ProxyURL)
 		if err != nil {
 			libs.Logger.Errorf("Invalid proxy URL: %s", err)
-			return false
+			return err
 		}
 		transport := &http.Transport{
 			Proxy:                 http.ProxyURL(proxyUrl),
@@ -213,14 +369,15 @@ func testConnection(domain string, port int) bool {
 				if resp != nil {
 					resp.Body.Close()
 				}
-				return true
+				return nil
 			}
+			lastErr = err
 			libs.Logger.Errorf("Proxy connection attempt %d failed for %s: %v", i+1, address, err)
 			if i < maxRetries-1 {
 				time.Sleep(retryDelay)
 			}
 		}
-		return false
+		return lastErr
 	}
 
 	// 没有代理配置，直接连接
@@ -233,14 +390,15 @@ func testConnection(domain string, port int) bool {
 					libs.Logger.Errorf("Failed to close connection: %s", err)
 				}
 			}(conn)
-			return true
+			return nil
 		}
+		lastErr = err
 		libs.Logger.Errorf("Direct connection attempt %d failed for %s: %v", i+1, address, err)
 		if i < maxRetries-1 {
 			time.Sleep(retryDelay)
 		}
 	}
-	return false
+	return lastErr
 }
 
 // domainMarkdown 生成Markdown格式的报告
@@ -265,19 +423,36 @@ func domainMarkdown(headString string, d *Domainer) *notify.WeChatMarkdown {
 			if !domain.IsAlive {
 				// 如果这个域名还没有显示过，则显示它
 				if !shownDomains[domain.Name] {
-					builder.WriteString(fmt.Sprintf("> %s:%d\n", domain.Name, domain.Port))
+					builder.WriteString(fmt.Sprintf("> %s:%d（%s）\n", domain.Name, domain.Port, domain.Reason))
 					shownDomains[domain.Name] = true
 				} else {
 					// 如果已经显示过这个域名，只显示端口
-					builder.WriteString(fmt.Sprintf(">   └─ 端口:%d\n", domain.Port))
+					builder.WriteString(fmt.Sprintf(">   └─ 端口:%d（%s）\n", domain.Port, domain.Reason))
 				}
 			}
 		}
 		builder.WriteString("==================\n")
 	}
 
+	// 解析和证书问题
+	severity := notify.SeverityInfo
+	if findings := d.checkFindings(); len(findings) > 0 {
+		builder.WriteString("**解析与证书检查：**\n")
+		for _, finding := range findings {
+			builder.WriteString(fmt.Sprintf("> <font color='%s'>%s</font>\n", getColorByStatus(finding.Severity != notify.SeverityInfo), finding.Message))
+			if finding.Severity.Level() > severity.Level() {
+				severity = finding.Severity
+			}
+		}
+		builder.WriteString("==================\n")
+	}
+
 	if isalert {
-		builder.WriteString("\n<font color='red'>**注意！域名连通性检测异常！**</font>" + task.CallUser(config.Config.Notify.Userlist))
+		builder.WriteString("\n<font color='red'>**注意！域名连通性检测异常！**</font>" + task.CallUser(notify.Mentions(taskName, notify.SeverityCritical)))
+	} else if severity == notify.SeverityCritical {
+		builder.WriteString("\n<font color='red'>**注意！域名解析或证书异常！**</font>" + task.CallUser(notify.Mentions(taskName, severity)))
+	} else if severity == notify.SeverityWarning {
+		builder.WriteString("\n<font color='warning'>**注意！域名解析或证书存在风险！**</font>" + task.CallUser(notify.Mentions(taskName, severity)))
 	}
 
 	markdown := &notify.WeChatMarkdown{
@@ -295,7 +470,7 @@ func headString() string {
 	var builder strings.Builder
 	// 组装巡检内容
 	builder.WriteString("# 域名连通性检测" + "\n")
-	builder.WriteString("**项目名称：**<font color='info'>" + config.Config.ProjectName + "</font>\n")
+	builder.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
 	builder.WriteString("**巡检时间：**<font color='info'>" + time.Now().Format("2006-01-02 15:04:05") + "</font>\n")
 	builder.WriteString("**巡检内容：**\n")
 
@@ -13,19 +13,23 @@ const taskName = "domain"
 
 // Domain 结构体，用于存储域名连通性检测结果
 type Domain struct {
-	Name    string `json:"name"`    // 域名
-	Port    int    `json:"port"`    // 端口
-	IsAlive bool   `json:"isAlive"` // 是否连通
+	Name    string   `json:"name"`             // 域名
+	Port    int      `json:"port"`             // 端口
+	IsAlive bool     `json:"isAlive"`          // 是否连通
+	Reason  string   `json:"reason,omitempty"` // 失败阶段：DNS 解析失败、TCP 拒绝连接、TCP 连接超时、TLS 握手失败
+	Pins    []string `json:"pins,omitempty"`   // 列表文件中固定的 IP/CIDR
+	DNS     *DNSInfo `json:"dns,omitempty"`    // 解析检查结果，同一域名的各端口共用
+	TLS     *TLSInfo `json:"tls,omitempty"`    // 443 端口的证书检查结果
 }
 
 // Domainer 域名检测任务结构体
 type Domainer struct {
-	Config      *config.CfgType
-	Logger      *zap.SugaredLogger
-	Domains     []*Domain // 域名列表
-	TotalCount  int       // 总域名数
-	AliveCount  int       // 连通域名数
-	FailedCount int       // 不通域名数
+	Config      *config.CfgType    `json:"-"`
+	Logger      *zap.SugaredLogger `json:"-"`
+	Domains     []*Domain          // 域名列表
+	TotalCount  int                // 总域名数
+	AliveCount  int                // 连通域名数
+	FailedCount int                // 不通域名数
 }
 
 func NewDomainer(cfg *config.CfgType, logger *zap.SugaredLogger) *Domainer {
@@ -0,0 +1,289 @@
+// Package domain @Author lanpang
+// @Date 2025/8/28 上午10:00:00
+// @Desc 443 端口的证书检查：证书链、到期天数、域名匹配、颁发者和弱协议
+package domain
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"vhagar/notify"
+	"vhagar/task"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+const defaultTLSTimeout = 5 * time.Second
+
+// 默认的证书到期提醒窗口（天）
+var defaultWindows = []int{30, 14, 7}
+
+// 探测服务端是否仍支持的弱协议版本
+var weakVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11}
+
+// TLSInfo 证书检查结果
+type TLSInfo struct {
+	Subject       string    `json:"subject"`
+	DNSNames      []string  `json:"dnsNames,omitempty"`
+	Issuer        string    `json:"issuer"`
+	NotAfter      time.Time `json:"notAfter"`
+	DaysLeft      int       `json:"daysLeft"`
+	Version       string    `json:"version"`                 // 协商的协议版本
+	ChainValid    bool      `json:"chainValid"`              // 证书链可由系统根证书验证
+	ChainError    string    `json:"chainError,omitempty"`    // 证书链验证失败的原因
+	HostnameMatch bool      `json:"hostnameMatch"`           // 证书包含该域名
+	WeakProtocols []string  `json:"weakProtocols,omitempty"` // 服务端仍接受的 TLS 1.0/1.1
+	Error         string    `json:"error,omitempty"`         // 握手失败
+}
+
+// tlsDialer 直连或通过 proxyurl 的 HTTP CONNECT 建立 TCP 连接
+type tlsDialer struct {
+	proxy   *url.URL
+	timeout time.Duration
+}
+
+func (d *tlsDialer) dial(ctx context.Context, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: d.timeout}
+	if d.proxy == nil {
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", d.proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(d.timeout))
+	req := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: address}, Host: address, Header: http.Header{}}
+	if user := d.proxy.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+		req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+		req.Header.Del("Authorization")
+	}
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("代理 CONNECT 失败: %s", resp.Status)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// handshake 完成一次 TLS 握手，返回连接状态
+func (d *tlsDialer) handshake(host string, port int, config *tls.Config) (tls.ConnectionState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	conn, err := d.dial(ctx, net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+	client := tls.Client(conn, config)
+	if err := client.HandshakeContext(ctx); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	return client.ConnectionState(), nil
+}
+
+// inspectTLS 不校验证书完成握手后再分别验证证书链和域名，确保证书有问题时仍能取到到期时间和颁发者
+func (d *tlsDialer) inspectTLS(host string, port int) *TLSInfo {
+	info := &TLSInfo{}
+	state, err := d.handshake(host, port, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	if len(state.PeerCertificates) == 0 {
+		info.Error = "服务端未返回证书"
+		return info
+	}
+	leaf := state.PeerCertificates[0]
+	info.Subject = leaf.Subject.CommonName
+	info.DNSNames = leaf.DNSNames
+	info.Issuer = issuerName(leaf)
+	info.NotAfter = leaf.NotAfter
+	info.DaysLeft = int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24))
+	info.Version = tls.VersionName(state.Version)
+	info.HostnameMatch = leaf.VerifyHostname(host) == nil
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates}); err != nil {
+		info.ChainError = err.Error()
+	} else {
+		info.ChainValid = true
+	}
+
+	for _, version := range weakVersions {
+		if d.acceptsVersion(host, port, version) {
+			info.WeakProtocols = append(info.WeakProtocols, tls.VersionName(version))
+		}
+	}
+	return info
+}
+
+// acceptsVersion 只允许指定版本握手，成功说明服务端支持该版本
+func (d *tlsDialer) acceptsVersion(host string, port int, version uint16) bool {
+	var suites []uint16
+	for _, suite := range tls.CipherSuites() {
+		suites = append(suites, suite.ID)
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		suites = append(suites, suite.ID)
+	}
+	_, err := d.handshake(host, port, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		MinVersion:         version,
+		MaxVersion:         version,
+		CipherSuites:       suites,
+	})
+	return err == nil
+}
+
+func issuerName(cert *x509.Certificate) string {
+	if len(cert.Issuer.Organization) > 0 {
+		if cert.Issuer.CommonName != "" {
+			return cert.Issuer.Organization[0] + " / " + cert.Issuer.CommonName
+		}
+		return cert.Issuer.Organization[0]
+	}
+	return cert.Issuer.CommonName
+}
+
+// certNames 证书包含的域名，没有 SAN 时为 CN
+func certNames(info *TLSInfo) string {
+	if len(info.DNSNames) == 0 {
+		return info.Subject + "（无 SAN）"
+	}
+	return strings.Join(info.DNSNames, ", ")
+}
+
+// windows 从大到小排列的提醒窗口
+func (d *Domainer) windows() []int {
+	windows := append([]int(nil), d.Config.Domain.TLS.Windows...)
+	if len(windows) == 0 {
+		windows = append(windows, defaultWindows...)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(windows)))
+	return windows
+}
+
+// expirySeverity 证书剩余天数所在的窗口，最小窗口和已过期为严重，次小窗口为一般，其余为提示
+func expirySeverity(daysLeft int, windows []int) (notify.Severity, int, bool) {
+	if daysLeft < 0 {
+		return notify.SeverityCritical, 0, true
+	}
+	for i := len(windows) - 1; i >= 0; i-- {
+		if daysLeft > windows[i] {
+			continue
+		}
+		switch i {
+		case len(windows) - 1:
+			return notify.SeverityCritical, windows[i], true
+		case len(windows) - 2:
+			return notify.SeverityWarning, windows[i], true
+		}
+		return notify.SeverityInfo, windows[i], true
+	}
+	return "", 0, false
+}
+
+// tlsFindings 证书问题，同一域名只检查一次
+func (d *Domainer) tlsFindings() []notify.Finding {
+	var findings []notify.Finding
+	windows := d.windows()
+	seen := map[string]bool{}
+	for _, domain := range d.Domains {
+		info := domain.TLS
+		if info == nil || seen[domain.Name] {
+			continue
+		}
+		seen[domain.Name] = true
+		// 握手失败在连通性中报告
+		if info.Error != "" {
+			continue
+		}
+		if severity, window, ok := expirySeverity(info.DaysLeft, windows); ok {
+			message := fmt.Sprintf("证书 %d 天内到期: %s 剩余 %d 天（%s）", window, domain.Name, info.DaysLeft, info.NotAfter.Format("2006-01-02"))
+			if info.DaysLeft < 0 {
+				message = fmt.Sprintf("证书已过期: %s（%s）", domain.Name, info.NotAfter.Format("2006-01-02"))
+			}
+			findings = append(findings, notify.Finding{Key: "cert_expiry:" + domain.Name, Severity: severity, Message: message})
+		}
+		if !info.HostnameMatch {
+			findings = append(findings, notify.Finding{Key: "cert_hostname:" + domain.Name, Severity: notify.SeverityCritical, Message: fmt.Sprintf("证书与域名不匹配: %s 的证书域名为 %s", domain.Name, certNames(info))})
+		}
+		if !info.ChainValid && info.DaysLeft >= 0 {
+			findings = append(findings, notify.Finding{Key: "cert_chain:" + domain.Name, Severity: notify.SeverityCritical, Message: fmt.Sprintf("证书链验证失败: %s: %s", domain.Name, info.ChainError)})
+		}
+		if len(info.WeakProtocols) > 0 {
+			findings = append(findings, notify.Finding{Key: "tls_weak:" + domain.Name, Severity: notify.SeverityWarning, Message: fmt.Sprintf("支持弱协议: %s: %s", domain.Name, strings.Join(info.WeakProtocols, ", "))})
+		}
+	}
+	return findings
+}
+
+// renderTLS 证书检查表格
+func (d *Domainer) renderTLS() {
+	table := tablewriter.NewWriter(task.GetOutputWriter())
+	table.SetHeader([]string{"域名", "颁发者", "到期时间", "剩余天数", "协议", "证书链", "域名匹配", "弱协议", "错误"})
+	rows := 0
+	seen := map[string]bool{}
+	for _, domain := range d.Domains {
+		info := domain.TLS
+		if info == nil || seen[domain.Name] {
+			continue
+		}
+		seen[domain.Name] = true
+		rows++
+		if info.Error != "" {
+			table.Append([]string{domain.Name, "-", "-", "-", "-", "-", "-", "-", info.Error})
+			continue
+		}
+		chain := "有效"
+		if !info.ChainValid {
+			chain = "无效"
+		}
+		match := "是"
+		if !info.HostnameMatch {
+			match = "否"
+		}
+		table.Append([]string{
+			domain.Name,
+			info.Issuer,
+			info.NotAfter.Format("2006-01-02"),
+			strconv.Itoa(info.DaysLeft),
+			info.Version,
+			chain,
+			match,
+			strings.Join(info.WeakProtocols, ", "),
+			info.ChainError,
+		})
+	}
+	if rows == 0 {
+		return
+	}
+	table.SetCaption(true, "443 端口证书检查")
+	table.Render()
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"vhagar/config"
+	"vhagar/notify"
+)
+
+func TestExpirySeverity(t *testing.T) {
+	tests := []struct {
+		daysLeft int
+		windows  []int
+		severity notify.Severity
+		window   int
+		ok       bool
+	}{
+		{-1, []int{30, 14, 7}, notify.SeverityCritical, 0, true},
+		{0, []int{30, 14, 7}, notify.SeverityCritical, 7, true},
+		{7, []int{30, 14, 7}, notify.SeverityCritical, 7, true},
+		{8, []int{30, 14, 7}, notify.SeverityWarning, 14, true},
+		{14, []int{30, 14, 7}, notify.SeverityWarning, 14, true},
+		{15, []int{30, 14, 7}, notify.SeverityInfo, 30, true},
+		{30, []int{30, 14, 7}, notify.SeverityInfo, 30, true},
+		{31, []int{30, 14, 7}, "", 0, false},
+		{60, []int{90, 60, 30, 14, 7}, notify.SeverityInfo, 60, true},
+		{20, []int{30}, notify.SeverityCritical, 30, true},
+		{40, []int{30}, "", 0, false},
+	}
+	for _, tt := range tests {
+		severity, window, ok := expirySeverity(tt.daysLeft, tt.windows)
+		if severity != tt.severity || window != tt.window || ok != tt.ok {
+			t.Errorf("expirySeverity(%d, %v) = %s, %d, %v, want %s, %d, %v",
+				tt.daysLeft, tt.windows, severity, window, ok, tt.severity, tt.window, tt.ok)
+		}
+	}
+}
+
+func TestWindows(t *testing.T) {
+	tests := []struct {
+		configured []int
+		want       []int
+	}{
+		{nil, []int{30, 14, 7}},
+		{[]int{7, 60, 30}, []int{60, 30, 7}},
+	}
+	for _, tt := range tests {
+		cfg := &config.CfgType{}
+		cfg.Domain.TLS.Windows = tt.configured
+		d := &Domainer{Config: cfg}
+		if got := d.windows(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("windows(%v) = %v, want %v", tt.configured, got, tt.want)
+		}
+	}
+	// 排序不影响默认窗口
+	if !reflect.DeepEqual(defaultWindows, []int{30, 14, 7}) {
+		t.Errorf("defaultWindows 被修改: %v", defaultWindows)
+	}
+}
@@ -0,0 +1,547 @@
+// Package doris @Author lanpang
+// @Date 2025/8/21 上午10:00:00
+// @Desc 集群层检查：FE/BE 节点、副本健康、导入任务和 Compaction Score
+package doris
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"vhagar/notify"
+	"vhagar/task"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// 检查阈值
+const (
+	queryTimeout        = 10 * time.Second // 单条 SHOW 语句和单个 BE /metrics 请求的超时
+	dbLoadTimeout       = 20 * time.Second // 单个库导入任务检查的超时
+	heartbeatStale      = 5 * time.Minute
+	diskWarnPercent     = 80
+	diskCriticalPercent = 90 // storage_flood_stage_usage_percent 默认值，超过后拒绝导入
+	compactionWarn      = 100
+	compactionCritical  = 500
+	loadWindow          = 24 * time.Hour // 统计最近一天失败的导入
+	loadStuck           = 2 * time.Hour  // 未完成超过该时长视为卡住
+	loadLimit           = 200            // 每个库最多检查的导入任务数
+	timeLayout          = "2006-01-02 15:04:05"
+)
+
+// 不检查导入任务的系统库
+var systemDBs = map[string]bool{"information_schema": true, "mysql": true, "__internal_schema": true}
+
+type Frontend struct {
+	Name          string
+	Host          string
+	Role          string
+	IsMaster      bool
+	Alive         bool
+	Join          bool
+	LastHeartbeat string
+	Version       string
+	ErrMsg        string `json:",omitempty"`
+}
+
+type Backend struct {
+	ID              string
+	Host            string
+	HTTPPort        string
+	Alive           bool
+	Decommissioned  bool
+	LastHeartbeat   string
+	TabletNum       int64
+	DataUsed        string
+	Total           string
+	UsedPct         float64
+	MaxDiskUsedPct  float64
+	Version         string
+	ErrMsg          string `json:",omitempty"`
+	CompactionScore int64  // BE /metrics 中的最大 Compaction Score，未获取到时为 -1
+}
+
+// TabletHealth 一个库的副本健康，Problems 为非零的异常分类，如 ReplicaMissingNum
+type TabletHealth struct {
+	DB        string
+	TabletNum int64
+	Healthy   int64
+	Problems  map[string]int64
+}
+
+// LoadJob 失败或卡住的导入任务
+type LoadJob struct {
+	DB         string
+	Kind       string // load、routine load
+	Name       string
+	State      string
+	CreateTime string
+	Stuck      bool
+	Message    string
+}
+
+// queryRows 执行 SHOW 语句，各版本返回的列不同，按列名读取
+func queryRows(ctx context.Context, conn interface {
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}, query string) ([]map[string]string, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := map[string]string{}
+		for i, column := range columns {
+			row[column] = values[i].String
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// field 按列名取值，兼容旧版本的列名，如 1.x 的 IP 在 2.x 中为 Host
+func field(row map[string]string, names ...string) string {
+	for _, name := range names {
+		if value, ok := row[name]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func parsePercent(value string) float64 {
+	percent, _ := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "%")), 64)
+	return percent
+}
+
+// show 在单独的超时内执行一条 SHOW 语句，避免前面的步骤耗尽后面步骤的时间
+func (doris *Doris) show(query string) ([]map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	return queryRows(ctx, doris.MysqlClient, query)
+}
+
+// gatherCluster 通过 FE 的 SHOW 语句采集集群状态，单项失败只记录日志
+func (doris *Doris) gatherCluster() {
+	if rows, err := doris.show("SHOW FRONTENDS"); err != nil {
+		doris.Logger.Errorw("SHOW FRONTENDS 失败", "err", err)
+	} else {
+		for _, row := range rows {
+			doris.Frontends = append(doris.Frontends, &Frontend{
+				Name:          row["Name"],
+				Host:          field(row, "Host", "IP"),
+				Role:          row["Role"],
+				IsMaster:      row["IsMaster"] == "true",
+				Alive:         row["Alive"] == "true",
+				Join:          row["Join"] == "true",
+				LastHeartbeat: row["LastHeartbeat"],
+				Version:       row["Version"],
+				ErrMsg:        row["ErrMsg"],
+			})
+		}
+	}
+
+	if rows, err := doris.show("SHOW BACKENDS"); err != nil {
+		doris.Logger.Errorw("SHOW BACKENDS 失败", "err", err)
+	} else {
+		for _, row := range rows {
+			backend := &Backend{
+				ID:              row["BackendId"],
+				Host:            field(row, "Host", "IP"),
+				HTTPPort:        row["HttpPort"],
+				Alive:           row["Alive"] == "true",
+				Decommissioned:  row["SystemDecommissioned"] == "true",
+				LastHeartbeat:   row["LastHeartbeat"],
+				DataUsed:        row["DataUsedCapacity"],
+				Total:           row["TotalCapacity"],
+				UsedPct:         parsePercent(row["UsedPct"]),
+				MaxDiskUsedPct:  parsePercent(row["MaxDiskUsedPct"]),
+				Version:         row["Version"],
+				ErrMsg:          row["ErrMsg"],
+				CompactionScore: -1,
+			}
+			backend.TabletNum, _ = strconv.ParseInt(row["TabletNum"], 10, 64)
+			if backend.Alive {
+				backend.CompactionScore = compactionScore(backend)
+			}
+			doris.Backends = append(doris.Backends, backend)
+		}
+	}
+
+	if rows, err := doris.show("SHOW PROC '/cluster_health/tablet_health'"); err != nil {
+		doris.Logger.Errorw("查询副本健康状态失败", "err", err)
+	} else {
+		for _, row := range rows {
+			// 最后一行为合计
+			if row["DbId"] == "Total" {
+				continue
+			}
+			health := TabletHealth{DB: row["DbName"], Problems: map[string]int64{}}
+			health.TabletNum, _ = strconv.ParseInt(row["TabletNum"], 10, 64)
+			health.Healthy, _ = strconv.ParseInt(row["HealthyNum"], 10, 64)
+			if health.Healthy >= health.TabletNum {
+				continue
+			}
+			for column, value := range row {
+				switch column {
+				case "DbId", "DbName", "TabletNum", "HealthyNum":
+					continue
+				}
+				if count, _ := strconv.ParseInt(value, 10, 64); count > 0 {
+					health.Problems[column] = count
+				}
+			}
+			doris.UnhealthyTablets = append(doris.UnhealthyTablets, health)
+		}
+	}
+
+	doris.gatherLoads()
+}
+
+// gatherLoads 各库最近失败和卡住的导入任务、异常的 Routine Load，每个库单独计时
+func (doris *Doris) gatherLoads() {
+	rows, err := doris.show("SHOW DATABASES")
+	if err != nil {
+		doris.Logger.Errorw("SHOW DATABASES 失败", "err", err)
+		return
+	}
+	var incomplete []string
+	for _, row := range rows {
+		name := field(row, "Database")
+		if name == "" || systemDBs[name] {
+			continue
+		}
+		if !doris.gatherDBLoads(name) {
+			incomplete = append(incomplete, name)
+		}
+	}
+	if len(incomplete) > 0 {
+		doris.Logger.Warnw("部分库的导入任务未检查完整", "count", len(incomplete), "dbs", strings.Join(incomplete, ","))
+	}
+	sort.SliceStable(doris.LoadJobs, func(i, j int) bool { return doris.LoadJobs[i].CreateTime > doris.LoadJobs[j].CreateTime })
+}
+
+// gatherDBLoads 检查一个库的导入任务，出错或超时时返回 false
+func (doris *Doris) gatherDBLoads(name string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), dbLoadTimeout)
+	defer cancel()
+	// SHOW ROUTINE LOAD 只查询当前库，使用单独的连接切换库
+	conn, err := doris.MysqlClient.Conn(ctx)
+	if err != nil {
+		doris.Logger.Errorw("获取 Doris 连接失败", "db", name, "err", err)
+		return false
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "USE `"+name+"`"); err != nil {
+		doris.Logger.Errorw("切换数据库失败", "db", name, "err", err)
+		return false
+	}
+
+	now := time.Now()
+	complete := true
+	loads, err := queryRows(ctx, conn, fmt.Sprintf("SHOW LOAD ORDER BY CreateTime DESC LIMIT %d", loadLimit))
+	if err != nil {
+		doris.Logger.Errorw("SHOW LOAD 失败", "db", name, "err", err)
+		complete = false
+	}
+	for _, load := range loads {
+		created, _ := time.ParseInLocation(timeLayout, load["CreateTime"], time.Local)
+		job := LoadJob{DB: name, Kind: "load", Name: load["Label"], State: load["State"], CreateTime: load["CreateTime"], Message: load["ErrorMsg"]}
+		switch job.State {
+		case "CANCELLED":
+			if now.Sub(created) > loadWindow {
+				continue
+			}
+		case "PENDING", "ETL", "LOADING":
+			if now.Sub(created) < loadStuck {
+				continue
+			}
+			job.Stuck = true
+			job.Message = "进度 " + load["Progress"]
+		default:
+			continue
+		}
+		doris.LoadJobs = append(doris.LoadJobs, job)
+	}
+
+	routines, err := queryRows(ctx, conn, "SHOW ALL ROUTINE LOAD")
+	if err != nil {
+		doris.Logger.Errorw("SHOW ROUTINE LOAD 失败", "db", name, "err", err)
+		complete = false
+	}
+	for _, routine := range routines {
+		job := LoadJob{DB: name, Kind: "routine load", Name: routine["Name"], State: routine["State"], CreateTime: routine["CreateTime"], Message: routine["ReasonOfStateChanged"]}
+		switch job.State {
+		case "PAUSED":
+		case "CANCELLED":
+			ended, _ := time.ParseInLocation(timeLayout, routine["EndTime"], time.Local)
+			if now.Sub(ended) > loadWindow {
+				continue
+			}
+		default:
+			continue
+		}
+		doris.LoadJobs = append(doris.LoadJobs, job)
+	}
+	if ctx.Err() != nil {
+		doris.Logger.Warnw("检查导入任务超时，结果不完整", "db", name, "timeout", dbLoadTimeout)
+		complete = false
+	}
+	return complete
+}
+
+// compactionScore 读取 BE /metrics 中最大的 Compaction Score
+func compactionScore(backend *Backend) int64 {
+	if backend.HTTPPort == "" {
+		return -1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+net.JoinHostPort(backend.Host, backend.HTTPPort)+"/metrics", nil)
+	if err != nil {
+		return -1
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return -1
+	}
+	defer resp.Body.Close()
+	score := int64(-1)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// doris_be_tablet_base_max_compaction_score、doris_be_tablet_cumulative_max_compaction_score
+		if strings.HasPrefix(line, "#") || !strings.Contains(line, "max_compaction_score") {
+			continue
+		}
+		fields := strings.Fields(line)
+		value, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err == nil && int64(value) > score {
+			score = int64(value)
+		}
+	}
+	return score
+}
+
+// heartbeatAge 距上次心跳的时长，无法解析时为 0
+func heartbeatAge(heartbeat string) time.Duration {
+	last, err := time.ParseInLocation(timeLayout, heartbeat, time.Local)
+	if err != nil {
+		return 0
+	}
+	return time.Since(last)
+}
+
+// versions FE、BE 的版本，版本不一致时有多个
+func (doris *Doris) versions() []string {
+	seen := map[string]bool{}
+	for _, fe := range doris.Frontends {
+		if fe.Version != "" {
+			seen[fe.Version] = true
+		}
+	}
+	for _, be := range doris.Backends {
+		if be.Version != "" {
+			seen[be.Version] = true
+		}
+	}
+	var versions []string
+	for version := range seen {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// clusterFindings FE/BE 节点、磁盘、副本、导入任务和 Compaction 的问题
+func (doris *Doris) clusterFindings() []notify.Finding {
+	var findings []notify.Finding
+	add := func(severity notify.Severity, key, message string) {
+		findings = append(findings, notify.Finding{Key: key, Severity: severity, Message: message})
+	}
+
+	for _, fe := range doris.Frontends {
+		switch {
+		case !fe.Alive:
+			add(notify.SeverityCritical, "fe_dead:"+fe.Host, fmt.Sprintf("FE %s（%s）不可用: %s", fe.Host, fe.Role, fe.ErrMsg))
+		case !fe.Join:
+			add(notify.SeverityWarning, "fe_join:"+fe.Host, fmt.Sprintf("FE %s（%s）未加入集群", fe.Host, fe.Role))
+		case heartbeatAge(fe.LastHeartbeat) > heartbeatStale:
+			add(notify.SeverityWarning, "fe_heartbeat:"+fe.Host, fmt.Sprintf("FE %s 最近心跳 %s", fe.Host, fe.LastHeartbeat))
+		}
+	}
+	for _, be := range doris.Backends {
+		switch {
+		case !be.Alive:
+			add(notify.SeverityCritical, "be_dead:"+be.Host, fmt.Sprintf("BE %s 不可用，最近心跳 %s: %s", be.Host, be.LastHeartbeat, be.ErrMsg))
+		case heartbeatAge(be.LastHeartbeat) > heartbeatStale:
+			add(notify.SeverityWarning, "be_heartbeat:"+be.Host, fmt.Sprintf("BE %s 最近心跳 %s", be.Host, be.LastHeartbeat))
+		}
+		if be.Decommissioned {
+			add(notify.SeverityInfo, "be_decommission:"+be.Host, fmt.Sprintf("BE %s 正在下线", be.Host))
+		}
+		switch disk := be.MaxDiskUsedPct; {
+		case disk >= diskCriticalPercent:
+			add(notify.SeverityCritical, "be_disk:"+be.Host, fmt.Sprintf("BE %s 磁盘使用率 %.2f%%，导入将被拒绝", be.Host, disk))
+		case disk >= diskWarnPercent:
+			add(notify.SeverityWarning, "be_disk:"+be.Host, fmt.Sprintf("BE %s 磁盘使用率 %.2f%%", be.Host, disk))
+		}
+		switch score := be.CompactionScore; {
+		case score >= compactionCritical:
+			add(notify.SeverityCritical, "compaction:"+be.Host, fmt.Sprintf("BE %s Compaction Score %d，版本堆积可能导致导入失败（-235）", be.Host, score))
+		case score >= compactionWarn:
+			add(notify.SeverityWarning, "compaction:"+be.Host, fmt.Sprintf("BE %s Compaction Score %d，Compaction 跟不上导入", be.Host, score))
+		}
+	}
+	if versions := doris.versions(); len(versions) > 1 {
+		add(notify.SeverityWarning, "version_mismatch", "FE/BE 版本不一致: "+strings.Join(versions, ", "))
+	}
+
+	for _, health := range doris.UnhealthyTablets {
+		severity := notify.SeverityWarning
+		if health.Problems["UnrecoverableNum"] > 0 {
+			severity = notify.SeverityCritical
+		}
+		add(severity, "tablet:"+health.DB, fmt.Sprintf("库 %s 有 %d 个异常副本: %s", health.DB, health.TabletNum-health.Healthy, problemSummary(health.Problems)))
+	}
+
+	for _, job := range doris.LoadJobs {
+		status := "失败"
+		if job.Stuck {
+			status = "卡住"
+		} else if job.State == "PAUSED" {
+			status = "暂停"
+		}
+		add(notify.SeverityWarning, "load:"+job.DB+":"+job.Name, fmt.Sprintf("%s 导入 %s.%s %s（%s）: %s", job.Kind, job.DB, job.Name, status, job.State, job.Message))
+	}
+	return findings
+}
+
+// problemSummary 异常副本分类，按数量排序
+func problemSummary(problems map[string]int64) string {
+	var names []string
+	for name := range problems {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return problems[names[i]] > problems[names[j]] })
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, problems[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// renderCluster FE、BE、异常副本和导入任务表格
+func (doris *Doris) renderCluster() {
+	out := task.GetOutputWriter()
+	if len(doris.Frontends) > 0 {
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"FE", "角色", "Master", "存活", "已加入", "最近心跳", "版本", "错误"})
+		for _, fe := range doris.Frontends {
+			table.Append([]string{fe.Host, fe.Role, strconv.FormatBool(fe.IsMaster), strconv.FormatBool(fe.Alive), strconv.FormatBool(fe.Join), fe.LastHeartbeat, fe.Version, fe.ErrMsg})
+		}
+		table.Render()
+	}
+	if len(doris.Backends) > 0 {
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"BE", "存活", "最近心跳", "Tablet 数", "数据量", "总容量", "使用率(%)", "最大磁盘使用率(%)", "Compaction Score", "版本", "错误"})
+		for _, be := range doris.Backends {
+			score := "-"
+			if be.CompactionScore >= 0 {
+				score = strconv.FormatInt(be.CompactionScore, 10)
+			}
+			alive := strconv.FormatBool(be.Alive)
+			if be.Decommissioned {
+				alive += "（下线中）"
+			}
+			table.Append([]string{
+				be.Host,
+				alive,
+				be.LastHeartbeat,
+				strconv.FormatInt(be.TabletNum, 10),
+				be.DataUsed,
+				be.Total,
+				strconv.FormatFloat(be.UsedPct, 'f', 2, 64),
+				strconv.FormatFloat(be.MaxDiskUsedPct, 'f', 2, 64),
+				score,
+				be.Version,
+				be.ErrMsg,
+			})
+		}
+		table.Render()
+	}
+	if len(doris.UnhealthyTablets) > 0 {
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"库", "Tablet 数", "健康数", "异常分类"})
+		for _, health := range doris.UnhealthyTablets {
+			table.Append([]string{health.DB, strconv.FormatInt(health.TabletNum, 10), strconv.FormatInt(health.Healthy, 10), problemSummary(health.Problems)})
+		}
+		table.SetCaption(true, "SHOW PROC '/cluster_health/tablet_health'")
+		table.Render()
+	}
+	if len(doris.LoadJobs) > 0 {
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"库", "类型", "名称", "状态", "创建时间", "说明"})
+		for _, job := range doris.LoadJobs {
+			state := job.State
+			if job.Stuck {
+				state += "（卡住）"
+			}
+			table.Append([]string{job.DB, job.Kind, job.Name, state, job.CreateTime, job.Message})
+		}
+		table.SetCaption(true, "最近一天失败、卡住或暂停的导入任务")
+		table.Render()
+	}
+}
+
+// reportCluster 机器人消息中的集群概况
+func (doris *Doris) reportCluster(builder *strings.Builder) {
+	aliveFE := 0
+	for _, fe := range doris.Frontends {
+		if fe.Alive {
+			aliveFE++
+		}
+	}
+	var unhealthy int64
+	for _, health := range doris.UnhealthyTablets {
+		unhealthy += health.TabletNum - health.Healthy
+	}
+	maxDisk, maxScore := 0.0, int64(-1)
+	for _, be := range doris.Backends {
+		if be.MaxDiskUsedPct > maxDisk {
+			maxDisk = be.MaxDiskUsedPct
+		}
+		if be.CompactionScore > maxScore {
+			maxScore = be.CompactionScore
+		}
+	}
+	builder.WriteString(fmt.Sprintf("**FE 存活：**<font color='info'>%d/%d</font>\n", aliveFE, len(doris.Frontends)))
+	builder.WriteString(fmt.Sprintf("**BE 最大磁盘使用率：**<font color='info'>%.2f%%</font>\n", maxDisk))
+	if maxScore >= 0 {
+		builder.WriteString(fmt.Sprintf("**最大 Compaction Score：**<font color='info'>%d</font>\n", maxScore))
+	}
+	builder.WriteString(fmt.Sprintf("**异常副本数：**<font color='info'>%d</font>\n", unhealthy))
+	builder.WriteString(fmt.Sprintf("**异常导入任务：**<font color='info'>%d</font>\n", len(doris.LoadJobs)))
+	if versions := doris.versions(); len(versions) > 0 {
+		builder.WriteString("**版本：**<font color='info'>" + strings.Join(versions, ", ") + "</font>\n")
+	}
+}
@@ -21,7 +21,7 @@ import (
 //var doriser *Doris
 
 //func Work() config.Tasker {
-//	cfg := config.Config
+//	cfg := config.Get()
 //	doris := newDoris(cfg)
 //	// 初始化数据
 //	doris.Gather()
@@ -29,8 +29,8 @@ import (
 //}
 
 func init() {
-	task.Add(taskName, func() task.Tasker {
-		return NewDoris(config.Config, libs.Logger)
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewDoris(cfg, libs.Logger)
 	})
 }
 
@@ -59,6 +59,16 @@ func (doris *Doris) TableRender() {
 	for _, jobName := range doris.FailedJobs {
 		fmt.Println("JobName: ", jobName)
 	}
+
+	doris.renderCluster()
+
+	if findings := doris.Findings(); len(findings) > 0 {
+		out := task.GetOutputWriter()
+		fmt.Fprintln(out, "警告:")
+		for _, finding := range findings {
+			fmt.Fprintf(out, "- [%s] %s\n", finding.Severity, finding.Message)
+		}
+	}
 }
 
 func (doris *Doris) Gather() {
@@ -94,6 +104,17 @@ func (doris *Doris) Gather() {
 	doris.CustomerGroupCount = customerGroupCount
 	// 检查 BE 节点健康
 	getBENum(doris)
+	// FE/BE 节点、副本健康和导入任务
+	doris.gatherCluster()
+	// /api/health 不可用时按 SHOW BACKENDS 统计
+	if doris.TotalBackendNum == 0 && len(doris.Backends) > 0 {
+		doris.TotalBackendNum = len(doris.Backends)
+		for _, backend := range doris.Backends {
+			if backend.Alive {
+				doris.OnlineBackendNum++
+			}
+		}
+	}
 }
 
 func (doris *Doris) ReportRobot() {
@@ -102,10 +123,11 @@ func (doris *Doris) ReportRobot() {
 	failedJobCount := len(doris.FailedJobs)
 	// 组装巡检内容
 	builder.WriteString("# Doris 巡检 \n")
-	builder.WriteString("**项目名称：**<font color='info'>" + config.Config.ProjectName + "</font>\n")
+	builder.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
 	builder.WriteString("**巡检时间：**<font color='info'>" + time.Now().Format("2006-01-02") + "</font>\n")
 	builder.WriteString("**BE节点总数：**<font color='info'>" + strconv.Itoa(doris.TotalBackendNum) + "</font>\n")
 	builder.WriteString("**在线节点数：**<font color='info'>" + strconv.Itoa(doris.OnlineBackendNum) + "</font>\n")
+	doris.reportCluster(&builder)
 
 	builder.WriteString("==================\n")
 
@@ -120,6 +142,32 @@ func (doris *Doris) ReportRobot() {
 	builder.WriteString("**使用分析表：**<font color='info'>" + strconv.Itoa(doris.UseAnalyseCount) + "</font>\n")
 	builder.WriteString("**客户群统计表：**<font color='info'>" + strconv.Itoa(doris.CustomerGroupCount) + "</font>\n")
 
+	// 集群层的问题
+	findings := doris.clusterFindings()
+	if len(findings) > 0 {
+		builder.WriteString("\n警告:\n")
+		for _, finding := range findings {
+			builder.WriteString(fmt.Sprintf("- %s\n", finding.Message))
+		}
+	}
+	severity := notify.SeverityInfo
+	for _, finding := range findings {
+		if finding.Severity.Level() > severity.Level() {
+			severity = finding.Severity
+		}
+	}
+
+	// BE 节点离线为严重告警，Job 失败为一般告警
+	if doris.OnlineBackendNum < doris.TotalBackendNum {
+		builder.WriteString("\n<font color='red'>**注意！Doris BE 节点离线！**</font>" + task.CallUser(notify.Mentions(taskName, notify.SeverityCritical)))
+	} else if severity == notify.SeverityCritical {
+		builder.WriteString("\n<font color='red'>**注意！Doris 集群异常！**</font>" + task.CallUser(notify.Mentions(taskName, notify.SeverityCritical)))
+	} else if failedJobCount > 0 {
+		builder.WriteString("\n<font color='warning'>**注意！Doris Job 执行失败！**</font>" + task.CallUser(notify.Mentions(taskName, notify.SeverityWarning)))
+	} else if severity == notify.SeverityWarning {
+		builder.WriteString("\n<font color='warning'>**注意！Doris 集群存在告警！**</font>" + task.CallUser(notify.Mentions(taskName, notify.SeverityWarning)))
+	}
+
 	markdown := &notify.WeChatMarkdown{
 		MsgType: "markdown",
 		Markdown: &notify.Markdown{
@@ -130,6 +178,24 @@ func (doris *Doris) ReportRobot() {
 	notify.Send(markdown, taskName)
 }
 
+// Findings 实现 task.Finder，BE 离线为严重问题，Job 失败为一般问题
+func (doris *Doris) Findings() []notify.Finding {
+	var findings []notify.Finding
+	if doris.TotalBackendNum == 0 {
+		findings = append(findings, notify.Finding{Key: "be_unknown", Severity: notify.SeverityCritical, Message: "无法获取 Doris BE 节点状态"})
+	} else if doris.OnlineBackendNum < doris.TotalBackendNum {
+		findings = append(findings, notify.Finding{
+			Key:      "be_offline",
+			Severity: notify.SeverityCritical,
+			Message:  fmt.Sprintf("Doris BE 节点离线: %d/%d 在线", doris.OnlineBackendNum, doris.TotalBackendNum),
+		})
+	}
+	for _, jobName := range doris.FailedJobs {
+		findings = append(findings, notify.Finding{Key: "job:" + jobName, Severity: notify.SeverityWarning, Message: "Doris Job 执行失败: " + jobName})
+	}
+	return append(findings, doris.clusterFindings()...)
+}
+
 // 查询失败的job
 func selectFailedJob(queryTime string, db *sql.DB) []string {
 	// 定义查询语句
@@ -13,16 +13,20 @@ import (
 const taskName = "doris"
 
 type Doris struct {
-	Config *config.CfgType
-	Logger *zap.SugaredLogger
-	config.DorisCfg
-	MysqlClient        *sql.DB
+	Config             *config.CfgType    `json:"-"`
+	Logger             *zap.SugaredLogger `json:"-"`
+	config.DorisCfg    `json:"-"`
+	MysqlClient        *sql.DB `json:"-"`
 	FailedJobs         []string
 	StaffCount         int
 	UseAnalyseCount    int
 	CustomerGroupCount int
 	OnlineBackendNum   int
 	TotalBackendNum    int
+	Frontends          []*Frontend
+	Backends           []*Backend
+	UnhealthyTablets   []TabletHealth `json:",omitempty"`
+	LoadJobs           []LoadJob      `json:",omitempty"` // 最近失败、卡住或暂停的导入任务
 }
 
 type dorisResponse struct {
@@ -0,0 +1,325 @@
+// Package es @Author lanpang
+// @Date 2025/8/20 上午10:00:00
+// @Desc 磁盘水位和容量预测：读取集群实际的磁盘水位配置，根据历史巡检记录预测节点到达水位的天数和会话索引的增长
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"vhagar/notify"
+	"vhagar/task"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	watermarkPrefix = "cluster.routing.allocation.disk."
+	// ES 默认水位
+	defaultLowWatermark   = "85%"
+	defaultHighWatermark  = "90%"
+	defaultFloodWatermark = "95%"
+	// 预测使用的历史范围和最少数据点
+	forecastWindow    = 30 * 24 * time.Hour
+	forecastMinPoints = 3
+	forecastMinSpan   = 24 * time.Hour
+	// 预测告警阈值（天）
+	highWarnDays  = 14
+	floodWarnDays = 7
+	// 会话索引，每个企业一个
+	conversationPrefix = "conversation_"
+	conversationTopN   = 10
+)
+
+// Watermarks 集群磁盘水位配置，值为百分比、比例或最少剩余空间，如 85%、0.85、50gb
+type Watermarks struct {
+	Enabled bool
+	Low     string
+	High    string
+	Flood   string
+}
+
+// DiskForecast 按历史磁盘使用率线性拟合的预测，未增长时天数为 -1
+type DiskForecast struct {
+	Points      int
+	Growth      float64 // 每天增长的百分点
+	DaysToHigh  float64
+	DaysToFlood float64
+}
+
+// IndexGrowth 会话索引的大小和增长
+type IndexGrowth struct {
+	Index          string
+	Primaries      int
+	Docs           int64
+	StoreSize      int64
+	PriStoreSize   int64
+	DailyGrowth    int64   // 主分片每天增长的字节数，历史不足时为 0
+	DaysToMaxShard float64 `json:",omitempty"` // 预计主分片超过 maxShardSize 的天数
+}
+
+// watermarks 读取磁盘水位，优先级 transient > persistent > defaults
+func (es *ES) watermarks(ctx context.Context) *Watermarks {
+	var settings struct {
+		Persistent map[string]interface{} `json:"persistent"`
+		Transient  map[string]interface{} `json:"transient"`
+		Defaults   map[string]interface{} `json:"defaults"`
+	}
+	params := url.Values{"include_defaults": {"true"}, "flat_settings": {"true"}}
+	if err := es.get(ctx, "GET", "/_cluster/settings", params, nil, &settings); err != nil {
+		es.Logger.Errorw("获取磁盘水位配置失败，使用默认水位", "err", err)
+	}
+	setting := func(name, fallback string) string {
+		for _, values := range []map[string]interface{}{settings.Transient, settings.Persistent, settings.Defaults} {
+			if value, ok := values[watermarkPrefix+name]; ok {
+				return fmt.Sprint(value)
+			}
+		}
+		return fallback
+	}
+	return &Watermarks{
+		Enabled: setting("threshold_enabled", "true") == "true",
+		Low:     setting("watermark.low", defaultLowWatermark),
+		High:    setting("watermark.high", defaultHighWatermark),
+		Flood:   setting("watermark.flood_stage", defaultFloodWatermark),
+	}
+}
+
+// usedPercent 水位换算为节点磁盘使用率，剩余空间形式的水位与节点磁盘大小有关
+func usedPercent(watermark string, total int64) float64 {
+	value := strings.ToLower(strings.TrimSpace(watermark))
+	if strings.HasSuffix(value, "%") {
+		percent, _ := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		return percent
+	}
+	if ratio, err := strconv.ParseFloat(value, 64); err == nil && ratio <= 1 {
+		return ratio * 100
+	}
+	free := parseByteSize(value)
+	if total <= 0 || free <= 0 {
+		return 0
+	}
+	return float64(total-free) / float64(total) * 100
+}
+
+// parseByteSize 解析 ES 的字节单位，如 500mb、50gb
+func parseByteSize(value string) int64 {
+	units := []struct {
+		suffix string
+		size   float64
+	}{{"pb", 1 << 50}, {"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}, {"b", 1}}
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			number, err := strconv.ParseFloat(strings.TrimSuffix(value, unit.suffix), 64)
+			if err != nil {
+				return 0
+			}
+			return int64(number * unit.size)
+		}
+	}
+	return 0
+}
+
+// esHistory 历史巡检记录中预测需要的字段
+type esHistory struct {
+	NodeList []struct {
+		Name      string
+		DiskUsage float64
+	}
+	Conversations []IndexGrowth
+}
+
+// point 一次巡检的采样值，时间以天为单位
+type point struct {
+	day   float64
+	value float64
+}
+
+// forecast 结合同一环境的历史巡检记录预测磁盘水位和会话索引增长
+func (es *ES) forecast() {
+	now := time.Now()
+	disks := map[string][]point{}
+	sizes := map[string][]point{}
+	for _, run := range task.Runs.List(taskName, now.Add(-forecastWindow)) {
+		if run.Status != task.StatusDone || len(run.Data) == 0 || run.Profile != es.Config.Profile {
+			continue
+		}
+		var history esHistory
+		if err := json.Unmarshal(run.Data, &history); err != nil {
+			continue
+		}
+		day := run.StartTime.Sub(now).Hours() / 24
+		for _, node := range history.NodeList {
+			disks[node.Name] = append(disks[node.Name], point{day, node.DiskUsage})
+		}
+		for _, index := range history.Conversations {
+			sizes[index.Index] = append(sizes[index.Index], point{day, float64(index.PriStoreSize)})
+		}
+	}
+
+	for _, node := range es.NodeList {
+		points := append(disks[node.Name], point{0, node.DiskUsage})
+		slope, ok := fitSlope(points)
+		if !ok {
+			continue
+		}
+		node.Forecast = &DiskForecast{
+			Points:      len(points),
+			Growth:      slope,
+			DaysToHigh:  daysTo(node.DiskUsage, node.HighWatermark, slope),
+			DaysToFlood: daysTo(node.DiskUsage, node.FloodWatermark, slope),
+		}
+	}
+
+	maxShard := float64(es.Config.ES.Index.MaxShardSize)
+	if maxShard <= 0 {
+		maxShard = defaultMaxShardSize
+	}
+	for i := range es.Conversations {
+		index := &es.Conversations[i]
+		slope, ok := fitSlope(append(sizes[index.Index], point{0, float64(index.PriStoreSize)}))
+		if !ok || slope <= 0 {
+			continue
+		}
+		index.DailyGrowth = int64(slope)
+		if index.Primaries > 0 {
+			index.DaysToMaxShard = daysTo(float64(index.PriStoreSize)/float64(index.Primaries), maxShard*gb, slope/float64(index.Primaries))
+		}
+	}
+	sort.Slice(es.Conversations, func(i, j int) bool {
+		a, b := es.Conversations[i], es.Conversations[j]
+		if a.DailyGrowth != b.DailyGrowth {
+			return a.DailyGrowth > b.DailyGrowth
+		}
+		return a.PriStoreSize > b.PriStoreSize
+	})
+}
+
+// fitSlope 最小二乘拟合每天的增长量，数据点过少或时间跨度不足一天时不预测
+func fitSlope(points []point) (float64, bool) {
+	if len(points) < forecastMinPoints {
+		return 0, false
+	}
+	minDay, maxDay := points[0].day, points[0].day
+	var sumX, sumY float64
+	for _, p := range points {
+		sumX += p.day
+		sumY += p.value
+		minDay = math.Min(minDay, p.day)
+		maxDay = math.Max(maxDay, p.day)
+	}
+	if (maxDay-minDay)*24 < forecastMinSpan.Hours() {
+		return 0, false
+	}
+	n := float64(len(points))
+	meanX, meanY := sumX/n, sumY/n
+	var cov, variance float64
+	for _, p := range points {
+		cov += (p.day - meanX) * (p.value - meanY)
+		variance += (p.day - meanX) * (p.day - meanX)
+	}
+	if variance == 0 {
+		return 0, false
+	}
+	return cov / variance, true
+}
+
+// daysTo 按当前增长速度到达阈值的天数，已超过为 0，不增长为 -1
+func daysTo(current, threshold, slope float64) float64 {
+	switch {
+	case threshold <= 0:
+		return -1
+	case current >= threshold:
+		return 0
+	case slope <= 0:
+		return -1
+	}
+	return (threshold - current) / slope
+}
+
+func formatDays(days float64) string {
+	switch {
+	case days < 0:
+		return "不增长"
+	case days == 0:
+		return "已达到"
+	}
+	return fmt.Sprintf("%.1f 天", days)
+}
+
+// diskFindings 相对集群实际水位的磁盘告警和到达水位的预测
+func (es *ES) diskFindings() []notify.Finding {
+	var findings []notify.Finding
+	add := func(severity notify.Severity, key, message string) {
+		findings = append(findings, notify.Finding{Key: key, Severity: severity, Message: message})
+	}
+	for _, node := range es.NodeList {
+		switch {
+		case es.Watermark == nil || !es.Watermark.Enabled:
+			// 未启用磁盘水位时按固定阈值检查
+			if node.DiskUsage > 80 {
+				add(notify.SeverityWarning, "node_disk:"+node.Name, fmt.Sprintf("节点 %s 磁盘使用率高: %.2f%%", node.Name, node.DiskUsage))
+			}
+		case node.FloodWatermark > 0 && node.DiskUsage >= node.FloodWatermark:
+			add(notify.SeverityCritical, "node_disk:"+node.Name, fmt.Sprintf("节点 %s 磁盘使用率 %.2f%% 超过 flood_stage 水位 %s，索引将被设为只读", node.Name, node.DiskUsage, es.Watermark.Flood))
+		case node.HighWatermark > 0 && node.DiskUsage >= node.HighWatermark:
+			add(notify.SeverityCritical, "node_disk:"+node.Name, fmt.Sprintf("节点 %s 磁盘使用率 %.2f%% 超过 high 水位 %s，分片将迁出该节点", node.Name, node.DiskUsage, es.Watermark.High))
+		case node.LowWatermark > 0 && node.DiskUsage >= node.LowWatermark:
+			add(notify.SeverityWarning, "node_disk:"+node.Name, fmt.Sprintf("节点 %s 磁盘使用率 %.2f%% 超过 low 水位 %s，不再分配新分片", node.Name, node.DiskUsage, es.Watermark.Low))
+		}
+
+		forecast := node.Forecast
+		if forecast == nil {
+			continue
+		}
+		switch {
+		case forecast.DaysToFlood > 0 && forecast.DaysToFlood <= floodWarnDays:
+			add(notify.SeverityCritical, "disk_forecast:"+node.Name, fmt.Sprintf("节点 %s 磁盘每天增长 %.2f%%，预计 %s后达到 flood_stage 水位", node.Name, forecast.Growth, formatDays(forecast.DaysToFlood)))
+		case forecast.DaysToHigh > 0 && forecast.DaysToHigh <= highWarnDays:
+			add(notify.SeverityWarning, "disk_forecast:"+node.Name, fmt.Sprintf("节点 %s 磁盘每天增长 %.2f%%，预计 %s后达到 high 水位", node.Name, forecast.Growth, formatDays(forecast.DaysToHigh)))
+		}
+	}
+
+	for _, index := range es.Conversations {
+		if index.DaysToMaxShard > 0 && index.DaysToMaxShard <= highWarnDays {
+			add(notify.SeverityWarning, "conversation_growth:"+index.Index, fmt.Sprintf("会话索引 %s 每天增长 %s，预计 %s后主分片超过上限", index.Index, formatBytes(index.DailyGrowth), formatDays(index.DaysToMaxShard)))
+		}
+	}
+	return findings
+}
+
+// renderConversations 增长最快的会话索引
+func (es *ES) renderConversations() {
+	if len(es.Conversations) == 0 {
+		return
+	}
+	table := tablewriter.NewWriter(task.GetOutputWriter())
+	table.SetHeader([]string{"索引", "主分片", "文档数", "总大小", "主分片大小", "日增长", "分片超限"})
+	for i, index := range es.Conversations {
+		if i == conversationTopN {
+			break
+		}
+		growth, days := "-", "-"
+		if index.DailyGrowth > 0 {
+			growth = formatBytes(index.DailyGrowth)
+			days = formatDays(index.DaysToMaxShard)
+		}
+		table.Append([]string{
+			index.Index,
+			strconv.Itoa(index.Primaries),
+			strconv.FormatInt(index.Docs, 10),
+			formatBytes(index.StoreSize),
+			formatBytes(index.PriStoreSize),
+			growth,
+			days,
+		})
+	}
+	table.SetCaption(true, fmt.Sprintf("会话索引 %d 个，按日增长排序，增长根据最近 30 天的巡检记录计算", len(es.Conversations)))
+	table.Render()
+}
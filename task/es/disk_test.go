@@ -0,0 +1,91 @@
+package es
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUsedPercent(t *testing.T) {
+	const total = 100 * gb
+	tests := []struct {
+		watermark string
+		total     int64
+		want      float64
+	}{
+		{"85%", total, 85},
+		{" 90.5% ", total, 90.5},
+		{"0.95", total, 95},
+		{"1", total, 100},
+		{"10gb", total, 90},
+		{"500MB", 1 * gb, 51.171875},
+		{"10gb", 0, 0},
+		{"abc", total, 0},
+	}
+	for _, tt := range tests {
+		if got := usedPercent(tt.watermark, tt.total); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("usedPercent(%q, %d) = %v, want %v", tt.watermark, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"100b", 100},
+		{"1kb", 1024},
+		{"1.5mb", 1536 * 1024},
+		{"50gb", 50 * gb},
+		{"2tb", 2 << 40},
+		{"1pb", 1 << 50},
+		{"gb", 0},
+		{"100", 0},
+	}
+	for _, tt := range tests {
+		if got := parseByteSize(tt.value); got != tt.want {
+			t.Errorf("parseByteSize(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFitSlope(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []point
+		want   float64
+		ok     bool
+	}{
+		{"数据点不足", []point{{-2, 50}, {0, 52}}, 0, false},
+		{"时间跨度不足一天", []point{{-0.5, 50}, {-0.2, 51}, {0, 52}}, 0, false},
+		{"线性增长", []point{{-3, 50}, {-2, 52}, {-1, 54}, {0, 56}}, 2, true},
+		{"下降", []point{{-2, 60}, {-1, 55}, {0, 50}}, -5, true},
+		{"有波动", []point{{-2, 50}, {-1, 53}, {0, 54}}, 2, true},
+		{"不增长", []point{{-2, 50}, {-1, 50}, {0, 50}}, 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := fitSlope(tt.points)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: fitSlope = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDaysTo(t *testing.T) {
+	tests := []struct {
+		current, threshold, slope float64
+		want                      float64
+	}{
+		{80, 90, 2, 5},
+		{90, 90, 2, 0},
+		{95, 90, -1, 0},
+		{80, 90, 0, -1},
+		{80, 90, -1, -1},
+		{80, 0, 2, -1},
+	}
+	for _, tt := range tests {
+		if got := daysTo(tt.current, tt.threshold, tt.slope); got != tt.want {
+			t.Errorf("daysTo(%v, %v, %v) = %v, want %v", tt.current, tt.threshold, tt.slope, got, tt.want)
+		}
+	}
+}
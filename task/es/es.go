@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,13 +19,13 @@ import (
 const taskName = "es"
 
 func init() {
-	task.Add(taskName, func() task.Tasker {
-		return NewES(config.Config, libs.Logger)
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewES(cfg, libs.Logger)
 	})
 }
 
 func (es *ES) Gather() {
-	esClient, err := libs.NewESClient(config.Config.ES)
+	esClient, err := libs.NewESClient(config.Get().ES.ESConfig)
 	if err != nil {
 		es.Logger.Errorw("Failed info", "err", err)
 		return
@@ -35,6 +36,8 @@ func (es *ES) Gather() {
 		}
 	}()
 	es.ESClient = esClient
+	es.Distribution = esClient.Distribution
+	es.Version = esClient.Version
 	es.getESInfo()
 }
 
@@ -69,16 +72,14 @@ func (es *ES) getESInfo() {
 	totalJVMHeapMax := float64(clusterStats.Nodes.JVM.Mem.HeapMaxInBytes)
 	es.ClusterJVMUsage = (totalJVMHeapUsed / totalJVMHeapMax) * 100
 
-	// 获取未分配分片数
-	clusterHealth, err := es.ESClient.ClusterHealth().Do(context.Background())
-	if err != nil {
-		es.Logger.Errorf("获取集群健康状态失败: %s", err)
-	} else {
-		es.UnassignedShards = clusterHealth.UnassignedShards
-	}
+	// 未分配分片数
+	es.UnassignedShards = health.UnassignedShards
+	es.DataNodes = health.NumberOfDataNodes
 
-	// 获取总数据大小
+	// 获取总数据大小、索引数和分片数
 	es.TotalDataSize = clusterStats.Indices.Store.SizeInBytes
+	es.IndexCount = clusterStats.Indices.Count
+	es.Shards = clusterStats.Indices.Shards.Total
 
 	// 获取节点统计信息
 	stats, err := es.ESClient.NodesStats().Do(context.Background())
@@ -95,25 +96,47 @@ func (es *ES) getESInfo() {
 			JVMUsage:    float64(node.JVM.Mem.HeapUsedInBytes) / float64(node.JVM.Mem.HeapMaxInBytes) * 100,
 			DiskUsage:   float64(node.FS.Total.TotalInBytes-node.FS.Total.AvailableInBytes) / float64(node.FS.Total.TotalInBytes) * 100,
 			LoadAverage: node.OS.CPU.LoadAverage["5m"],
-			IndexCount:  clusterStats.Indices.Count,
-			Shards:      clusterStats.Indices.Shards.Total,
 			DataSize:    node.Indices.Store.SizeInBytes,
+			DiskTotal:   node.FS.Total.TotalInBytes,
+			DiskFree:    node.FS.Total.AvailableInBytes,
 		}
 		es.NodeList = append(es.NodeList, nodeInfo)
 	}
+	sort.Slice(es.NodeList, func(i, j int) bool { return es.NodeList[i].Name < es.NodeList[j].Name })
+
+	// 磁盘水位
+	es.Watermark = es.watermarks(context.Background())
+	for _, node := range es.NodeList {
+		node.LowWatermark = usedPercent(es.Watermark.Low, node.DiskTotal)
+		node.HighWatermark = usedPercent(es.Watermark.High, node.DiskTotal)
+		node.FloodWatermark = usedPercent(es.Watermark.Flood, node.DiskTotal)
+	}
+
+	// 索引级检查
+	es.checkIndices(context.Background(), es.DataNodes)
+	// 根据历史记录预测磁盘和会话索引增长
+	es.forecast()
 }
 
 func (es *ES) TableRender() {
 	table := tablewriter.NewWriter(task.GetOutputWriter())
-	table.SetHeader([]string{"节点名称", "IP地址", "5分钟负载", "JVM堆内存使用(%)", "磁盘使用(%)", "数据大小"})
+	table.SetHeader([]string{"节点名称", "IP地址", "5分钟负载", "JVM堆内存使用(%)", "磁盘使用(%)", "水位 low/high/flood(%)", "达到 high", "达到 flood", "数据大小"})
 
 	for _, node := range es.NodeList {
+		toHigh, toFlood := "-", "-"
+		if node.Forecast != nil {
+			toHigh = formatDays(node.Forecast.DaysToHigh)
+			toFlood = formatDays(node.Forecast.DaysToFlood)
+		}
 		row := []string{
 			node.Name,
 			node.IP,
 			strconv.FormatFloat(node.LoadAverage, 'f', 2, 64),
 			strconv.FormatFloat(node.JVMUsage, 'f', 2, 64),
 			strconv.FormatFloat(node.DiskUsage, 'f', 2, 64),
+			fmt.Sprintf("%.1f/%.1f/%.1f", node.LowWatermark, node.HighWatermark, node.FloodWatermark),
+			toHigh,
+			toFlood,
 			formatBytes(node.DataSize),
 		}
 		table.Append(row)
@@ -122,25 +145,42 @@ func (es *ES) TableRender() {
 	table.SetColumnSeparator("|")
 	table.SetCenterSeparator("+")
 
-	caption := fmt.Sprintf("ES 集群状态: %s. 索引数: %d, 分片数: %d, 集群JVM使用率: %.2f%%, 未分配分片: %d, 总数据大小: %s",
-		es.Status, es.NodeList[0].IndexCount, es.NodeList[0].Shards,
+	caption := fmt.Sprintf("%s %s 集群状态: %s. 索引数: %d, 分片数: %d, 集群JVM使用率: %.2f%%, 未分配分片: %d, 总数据大小: %s",
+		es.Distribution, es.Version, es.Status, es.IndexCount, es.Shards,
 		es.ClusterJVMUsage, es.UnassignedShards, formatBytes(es.TotalDataSize))
 	table.SetCaption(true, caption)
 	table.Render()
+
+	es.renderIndices()
+	es.renderConversations()
+
+	if findings := es.Findings(); len(findings) > 0 {
+		out := task.GetOutputWriter()
+		fmt.Fprintln(out, "警告:")
+		for _, finding := range findings {
+			fmt.Fprintf(out, "- [%s] %s\n", finding.Severity, finding.Message)
+		}
+	}
 }
 
 func (es *ES) ReportRobot() {
 	var builder strings.Builder
 	builder.WriteString("# ES 巡检 \n")
-	builder.WriteString("**项目名称：**<font color='info'>" + config.Config.ProjectName + "</font>\n")
+	builder.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
 	builder.WriteString("**巡检时间：**<font color='info'>" + time.Now().Format("2006-01-02") + "</font>\n")
 	builder.WriteString("**集群状态：<font color='info'>" + es.Status + "</font>**\n")
+	builder.WriteString("**版本：**<font color='info'>" + es.Distribution + " " + es.Version + "</font>\n")
 
-	builder.WriteString(fmt.Sprintf("**索引数：**<font color='info'>%d</font>\n", es.NodeList[0].IndexCount))
-	builder.WriteString(fmt.Sprintf("**分片数：**<font color='info'>%d</font>\n", es.NodeList[0].Shards))
+	builder.WriteString(fmt.Sprintf("**索引数：**<font color='info'>%d</font>\n", es.IndexCount))
+	builder.WriteString(fmt.Sprintf("**分片数：**<font color='info'>%d</font>\n", es.Shards))
 	builder.WriteString(fmt.Sprintf("**集群JVM使用率：**<font color='info'>%.2f%%</font>\n", es.ClusterJVMUsage))
 	builder.WriteString(fmt.Sprintf("**未分配分片：**<font color='info'>%d</font>\n", es.UnassignedShards))
 	builder.WriteString(fmt.Sprintf("**总数据大小：**<font color='info'>%s</font>\n", formatBytes(es.TotalDataSize)))
+	if check := es.Indices; check != nil {
+		builder.WriteString(fmt.Sprintf("**异常索引：**<font color='info'>%d</font>\n", len(check.UnhealthyIndices)))
+		builder.WriteString(fmt.Sprintf("**只读索引：**<font color='info'>%d</font>\n", len(check.ReadOnlyIndices)))
+		builder.WriteString(fmt.Sprintf("**过大分片：**<font color='info'>%d</font>\n", len(check.LargeShards)))
+	}
 
 	for _, node := range es.NodeList {
 		builder.WriteString("==================\n")
@@ -149,10 +189,31 @@ func (es *ES) ReportRobot() {
 		builder.WriteString(fmt.Sprintf("**5分钟负载：**<font color='info'> %.2f </font>\n", node.LoadAverage))
 		builder.WriteString(fmt.Sprintf("**JVM堆内存使用：**<font color='info'> %.2f%% </font>\n", node.JVMUsage))
 		builder.WriteString(fmt.Sprintf("**磁盘使用：**<font color='info'> %.2f%% </font>\n", node.DiskUsage))
+		builder.WriteString(fmt.Sprintf("**磁盘水位：**<font color='info'> low %.1f%% / high %.1f%% / flood %.1f%% </font>\n", node.LowWatermark, node.HighWatermark, node.FloodWatermark))
+		if forecast := node.Forecast; forecast != nil {
+			builder.WriteString(fmt.Sprintf("**磁盘日增长：**<font color='info'> %.2f%% </font>\n", forecast.Growth))
+			builder.WriteString(fmt.Sprintf("**预计达到 high：**<font color='warning'> %s </font>\n", formatDays(forecast.DaysToHigh)))
+			builder.WriteString(fmt.Sprintf("**预计达到 flood：**<font color='warning'> %s </font>\n", formatDays(forecast.DaysToFlood)))
+		}
 		builder.WriteString(fmt.Sprintf("**数据大小：**<font color='info'> %s </font>\n", formatBytes(node.DataSize)))
 		builder.WriteString("\n")
 	}
 
+	// 增长最快的会话索引
+	var growing []IndexGrowth
+	for _, index := range es.Conversations {
+		if index.DailyGrowth > 0 && len(growing) < 5 {
+			growing = append(growing, index)
+		}
+	}
+	if len(growing) > 0 {
+		builder.WriteString("## 会话索引增长\n")
+		for _, index := range growing {
+			builder.WriteString(fmt.Sprintf("> %s 主分片 %s，日增长 <font color='warning'>%s</font>\n", index.Index, formatBytes(index.PriStoreSize), formatBytes(index.DailyGrowth)))
+		}
+		builder.WriteString("\n")
+	}
+
 	// 添加警告信息
 	warnings := es.generateWarnings()
 	if len(warnings) > 0 {
@@ -173,33 +234,60 @@ func (es *ES) ReportRobot() {
 
 }
 
-func (es *ES) generateWarnings() []string {
-	var warnings []string
+// Findings 实现 task.Finder，集群 red、节点资源异常等问题
+func (es *ES) Findings() []notify.Finding {
+	var findings []notify.Finding
+	add := func(severity notify.Severity, key, message string) {
+		findings = append(findings, notify.Finding{Key: key, Severity: severity, Message: message})
+	}
+
+	if es.Status == "" {
+		add(notify.SeverityCritical, "cluster_unreachable", "无法获取 ES 集群状态")
+		return findings
+	}
 
 	for _, node := range es.NodeList {
 		if node.JVMUsage > 80 {
-			warnings = append(warnings, fmt.Sprintf("节点 %s JVM堆内存使用率高: %.2f%%", node.Name, node.JVMUsage))
-		}
-		if node.DiskUsage > 80 {
-			warnings = append(warnings, fmt.Sprintf("节点 %s 磁盘使用率高: %.2f%%", node.Name, node.DiskUsage))
+			add(notify.SeverityWarning, "node_jvm:"+node.Name, fmt.Sprintf("节点 %s JVM堆内存使用率高: %.2f%%", node.Name, node.JVMUsage))
 		}
 		if node.LoadAverage > float64(runtime.NumCPU()) {
-			warnings = append(warnings, fmt.Sprintf("节点 %s 5分钟负载高: %.2f", node.Name, node.LoadAverage))
+			add(notify.SeverityWarning, "node_load:"+node.Name, fmt.Sprintf("节点 %s 5分钟负载高: %.2f", node.Name, node.LoadAverage))
 		}
 	}
 
-	if strings.ToLower(es.Status) != "green" {
-		warnings = append(warnings, fmt.Sprintf("集群状态不佳: %s", es.Status))
+	switch strings.ToLower(es.Status) {
+	case "green":
+	case "red":
+		add(notify.SeverityCritical, "cluster_status", fmt.Sprintf("集群状态不佳: %s", es.Status))
+	default:
+		add(notify.SeverityWarning, "cluster_status", fmt.Sprintf("集群状态不佳: %s", es.Status))
 	}
 
 	if es.ClusterJVMUsage > 75 {
-		warnings = append(warnings, fmt.Sprintf("集群JVM堆内存使用率高: %.2f%%", es.ClusterJVMUsage))
+		add(notify.SeverityWarning, "cluster_jvm", fmt.Sprintf("集群JVM堆内存使用率高: %.2f%%", es.ClusterJVMUsage))
 	}
 
 	if es.UnassignedShards > 0 {
-		warnings = append(warnings, fmt.Sprintf("存在未分配分片: %d", es.UnassignedShards))
+		add(notify.SeverityWarning, "unassigned_shards", fmt.Sprintf("存在未分配分片: %d", es.UnassignedShards))
 	}
 
+	findings = append(findings, es.diskFindings()...)
+
+	if es.ShardsPerNode > maxShardsPerNode*80/100 {
+		add(notify.SeverityWarning, "shards_per_node", fmt.Sprintf("每个数据节点平均 %d 个分片，接近 cluster.max_shards_per_node 默认上限 %d", es.ShardsPerNode, maxShardsPerNode))
+	}
+	if es.Indices != nil {
+		findings = append(findings, es.Indices.findings(es.Config.ES.Index.Production)...)
+	}
+
+	return findings
+}
+
+func (es *ES) generateWarnings() []string {
+	var warnings []string
+	for _, finding := range es.Findings() {
+		warnings = append(warnings, finding.Message)
+	}
 	return warnings
 }
 
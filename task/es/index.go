@@ -0,0 +1,455 @@
+// Package es @Author lanpang
+// @Date 2025/8/19 上午10:00:00
+// @Desc 索引级检查：索引健康、分片大小、副本、只读块、ILM/ISM 错误和未分配分片原因
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"vhagar/notify"
+	"vhagar/task"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olivere/elastic/v7"
+)
+
+const (
+	gb = 1024 * 1024 * 1024
+	// 默认检查阈值
+	defaultMaxShardSize   = 50
+	defaultSmallShardSize = 1
+	defaultExplain        = 10
+	// cluster.max_shards_per_node 默认值，超过 80% 告警
+	maxShardsPerNode = 1000
+)
+
+// IndexInfo 异常索引
+type IndexInfo struct {
+	Name         string
+	Health       string
+	Primaries    int
+	Replicas     int
+	Docs         int64
+	StoreSize    int64
+	PriStoreSize int64
+}
+
+// ShardInfo 分片，大小单位为字节
+type ShardInfo struct {
+	Index   string
+	Shard   int
+	Primary bool
+	State   string
+	Store   int64
+	Node    string `json:",omitempty"`
+}
+
+// LifecycleError ILM（ES）或 ISM（OpenSearch）执行失败的索引
+type LifecycleError struct {
+	Index  string
+	Policy string
+	Step   string
+	Reason string
+}
+
+// Allocation 未分配分片及 _cluster/allocation/explain 给出的原因
+type Allocation struct {
+	Index       string
+	Shard       int
+	Primary     bool
+	Reason      string // unassigned_info.reason，如 NODE_LEFT
+	Explanation string
+}
+
+// IndexCheck 索引检查结果，只保留有问题的索引
+type IndexCheck struct {
+	UnhealthyIndices  []IndexInfo      `json:",omitempty"` // red、yellow 索引
+	LargeShards       []ShardInfo      `json:",omitempty"`
+	SmallShardIndices []IndexInfo      `json:",omitempty"` // 主分片过多、平均分片过小
+	NoReplicaIndices  []string         `json:",omitempty"`
+	ReadOnlyIndices   []string         `json:",omitempty"` // index.blocks.read_only_allow_delete，通常由磁盘洪水水位触发
+	LifecycleErrors   []LifecycleError `json:",omitempty"`
+	Unassigned        []Allocation     `json:",omitempty"`
+	Lifecycle         string           `json:",omitempty"` // ilm、ism，两者都不可用时为空
+}
+
+// catIndex _cat/indices?format=json&bytes=b 的一行，关闭的索引数值为 null
+type catIndex struct {
+	Health       string `json:"health"`
+	Status       string `json:"status"`
+	Index        string `json:"index"`
+	Pri          string `json:"pri"`
+	Rep          string `json:"rep"`
+	DocsCount    string `json:"docs.count"`
+	StoreSize    string `json:"store.size"`
+	PriStoreSize string `json:"pri.store.size"`
+}
+
+type catShard struct {
+	Index  string `json:"index"`
+	Shard  string `json:"shard"`
+	Prirep string `json:"prirep"`
+	State  string `json:"state"`
+	Store  string `json:"store"`
+	Node   string `json:"node"`
+}
+
+// get 调用 REST 接口并解析 JSON。使用通用请求而不是各 API 的封装，兼容 ES 各版本和 OpenSearch
+func (es *ES) get(ctx context.Context, method, path string, params url.Values, body interface{}, result interface{}) error {
+	resp, err := es.ESClient.PerformRequest(ctx, elastic.PerformRequestOptions{
+		Method: method,
+		Path:   path,
+		Params: params,
+		Body:   body,
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(resp.Body, result)
+}
+
+// checkIndices 索引级检查，单项失败只记录日志
+func (es *ES) checkIndices(ctx context.Context, dataNodes int) {
+	cfg := es.Config.ES.Index
+	if cfg.MaxShardSize <= 0 {
+		cfg.MaxShardSize = defaultMaxShardSize
+	}
+	if cfg.SmallShardSize <= 0 {
+		cfg.SmallShardSize = defaultSmallShardSize
+	}
+	if cfg.Explain <= 0 {
+		cfg.Explain = defaultExplain
+	}
+	check := &IndexCheck{}
+	es.Indices = check
+	catParams := url.Values{"format": {"json"}, "bytes": {"b"}}
+
+	var indices []catIndex
+	if err := es.get(ctx, "GET", "/_cat/indices", catParams, nil, &indices); err != nil {
+		es.Logger.Errorw("获取索引列表失败", "err", err)
+	}
+	for _, row := range indices {
+		if row.Status == "close" {
+			continue
+		}
+		index := IndexInfo{Name: row.Index, Health: row.Health}
+		index.Primaries, _ = strconv.Atoi(row.Pri)
+		index.Replicas, _ = strconv.Atoi(row.Rep)
+		index.Docs, _ = strconv.ParseInt(row.DocsCount, 10, 64)
+		index.StoreSize, _ = strconv.ParseInt(row.StoreSize, 10, 64)
+		index.PriStoreSize, _ = strconv.ParseInt(row.PriStoreSize, 10, 64)
+		if strings.HasPrefix(index.Name, conversationPrefix) {
+			es.Conversations = append(es.Conversations, IndexGrowth{
+				Index:        index.Name,
+				Primaries:    index.Primaries,
+				Docs:         index.Docs,
+				StoreSize:    index.StoreSize,
+				PriStoreSize: index.PriStoreSize,
+			})
+		}
+		if index.Health == "red" || index.Health == "yellow" {
+			check.UnhealthyIndices = append(check.UnhealthyIndices, index)
+		}
+		// 系统索引由 ES 自行管理副本和分片
+		if strings.HasPrefix(index.Name, ".") {
+			continue
+		}
+		if index.Replicas == 0 {
+			check.NoReplicaIndices = append(check.NoReplicaIndices, index.Name)
+		}
+		if index.Primaries > 1 && index.PriStoreSize/int64(index.Primaries) < cfg.SmallShardSize*gb {
+			check.SmallShardIndices = append(check.SmallShardIndices, index)
+		}
+	}
+	sort.Slice(check.UnhealthyIndices, func(i, j int) bool {
+		a, b := check.UnhealthyIndices[i], check.UnhealthyIndices[j]
+		if a.Health != b.Health {
+			return a.Health == "red"
+		}
+		return a.Name < b.Name
+	})
+	sort.Strings(check.NoReplicaIndices)
+
+	var shards []catShard
+	if err := es.get(ctx, "GET", "/_cat/shards", catParams, nil, &shards); err != nil {
+		es.Logger.Errorw("获取分片列表失败", "err", err)
+	}
+	var unassigned []ShardInfo
+	for _, row := range shards {
+		shard := ShardInfo{Index: row.Index, Primary: row.Prirep == "p", State: row.State, Node: row.Node}
+		shard.Shard, _ = strconv.Atoi(row.Shard)
+		shard.Store, _ = strconv.ParseInt(row.Store, 10, 64)
+		if shard.Primary && shard.Store > cfg.MaxShardSize*gb {
+			check.LargeShards = append(check.LargeShards, shard)
+		}
+		if shard.State == "UNASSIGNED" {
+			unassigned = append(unassigned, shard)
+		}
+	}
+	sort.Slice(check.LargeShards, func(i, j int) bool { return check.LargeShards[i].Store > check.LargeShards[j].Store })
+	if dataNodes > 0 {
+		es.ShardsPerNode = len(shards) / dataNodes
+	}
+
+	check.ReadOnlyIndices = es.readOnlyIndices(ctx)
+	check.Lifecycle, check.LifecycleErrors = es.lifecycleErrors(ctx)
+	check.Unassigned = es.explainUnassigned(ctx, unassigned, cfg.Explain)
+}
+
+// readOnlyIndices 设置了 read_only_allow_delete 的索引
+func (es *ES) readOnlyIndices(ctx context.Context) []string {
+	var settings map[string]struct {
+		Settings map[string]string `json:"settings"`
+	}
+	params := url.Values{"flat_settings": {"true"}}
+	if err := es.get(ctx, "GET", "/_all/_settings/index.blocks.read_only_allow_delete", params, nil, &settings); err != nil {
+		es.Logger.Errorw("获取索引只读设置失败", "err", err)
+		return nil
+	}
+	var names []string
+	for name, index := range settings {
+		if index.Settings["index.blocks.read_only_allow_delete"] == "true" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lifecycleErrors ES 查询 ILM，OpenSearch 查询 ISM，未启用时跳过
+func (es *ES) lifecycleErrors(ctx context.Context) (string, []LifecycleError) {
+	var errs []LifecycleError
+	var ilm struct {
+		Indices map[string]struct {
+			Policy     string `json:"policy"`
+			Step       string `json:"step"`
+			FailedStep string `json:"failed_step"`
+			StepInfo   struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"step_info"`
+		} `json:"indices"`
+	}
+	if !es.ESClient.OpenSearch() {
+		if err := es.get(ctx, "GET", "/*/_ilm/explain", nil, nil, &ilm); err != nil {
+			es.Logger.Debugw("ILM 不可用，跳过索引生命周期检查", "err", err)
+			return "", nil
+		}
+		for name, index := range ilm.Indices {
+			if index.Step != "ERROR" {
+				continue
+			}
+			errs = append(errs, LifecycleError{Index: name, Policy: index.Policy, Step: index.FailedStep, Reason: strings.TrimSpace(index.StepInfo.Type + " " + index.StepInfo.Reason)})
+		}
+		sortLifecycleErrors(errs)
+		return "ilm", errs
+	}
+
+	var ism map[string]json.RawMessage
+	if err := es.get(ctx, "GET", "/_plugins/_ism/explain/*", nil, nil, &ism); err != nil {
+		es.Logger.Debugw("ISM 不可用，跳过索引生命周期检查", "err", err)
+		return "", nil
+	}
+	for name, raw := range ism {
+		var index struct {
+			PolicyID string `json:"policy_id"`
+			Action   struct {
+				Name   string `json:"name"`
+				Failed bool   `json:"failed"`
+			} `json:"action"`
+			Info struct {
+				Message string `json:"message"`
+			} `json:"info"`
+		}
+		// total_managed_indices 等非索引字段解析失败，跳过
+		if json.Unmarshal(raw, &index) != nil || !index.Action.Failed {
+			continue
+		}
+		errs = append(errs, LifecycleError{Index: name, Policy: index.PolicyID, Step: index.Action.Name, Reason: index.Info.Message})
+	}
+	sortLifecycleErrors(errs)
+	return "ism", errs
+}
+
+func sortLifecycleErrors(errs []LifecycleError) {
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Index < errs[j].Index })
+}
+
+// explainUnassigned 逐个解释未分配分片，主分片优先，最多 limit 个
+func (es *ES) explainUnassigned(ctx context.Context, shards []ShardInfo, limit int) []Allocation {
+	sort.SliceStable(shards, func(i, j int) bool { return shards[i].Primary && !shards[j].Primary })
+	var allocations []Allocation
+	seen := map[string]bool{}
+	for _, shard := range shards {
+		if len(allocations) >= limit {
+			break
+		}
+		// 同一分片的多个副本原因相同，只解释一次
+		id := fmt.Sprintf("%s/%d/%t", shard.Index, shard.Shard, shard.Primary)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		allocation := Allocation{Index: shard.Index, Shard: shard.Shard, Primary: shard.Primary}
+		var explain struct {
+			UnassignedInfo struct {
+				Reason  string `json:"reason"`
+				Details string `json:"details"`
+			} `json:"unassigned_info"`
+			AllocateExplanation     string `json:"allocate_explanation"`
+			NodeAllocationDecisions []struct {
+				NodeName string `json:"node_name"`
+				Deciders []struct {
+					Decider     string `json:"decider"`
+					Decision    string `json:"decision"`
+					Explanation string `json:"explanation"`
+				} `json:"deciders"`
+			} `json:"node_allocation_decisions"`
+		}
+		body := map[string]interface{}{"index": shard.Index, "shard": shard.Shard, "primary": shard.Primary}
+		if err := es.get(ctx, "POST", "/_cluster/allocation/explain", nil, body, &explain); err != nil {
+			allocation.Explanation = "获取分配说明失败: " + err.Error()
+			allocations = append(allocations, allocation)
+			continue
+		}
+		allocation.Reason = explain.UnassignedInfo.Reason
+		parts := []string{explain.AllocateExplanation}
+		if explain.UnassignedInfo.Details != "" {
+			parts = append(parts, explain.UnassignedInfo.Details)
+		}
+		// 各节点拒绝分配的原因去重
+		reasons := map[string]bool{}
+		for _, node := range explain.NodeAllocationDecisions {
+			for _, decider := range node.Deciders {
+				if decider.Decision != "NO" || reasons[decider.Explanation] {
+					continue
+				}
+				reasons[decider.Explanation] = true
+				parts = append(parts, fmt.Sprintf("[%s] %s", decider.Decider, decider.Explanation))
+			}
+		}
+		allocation.Explanation = strings.Join(parts, "；")
+		allocations = append(allocations, allocation)
+	}
+	return allocations
+}
+
+// renderIndices 异常索引、大分片和未分配分片表格
+func (es *ES) renderIndices() {
+	check := es.Indices
+	if check == nil {
+		return
+	}
+	out := task.GetOutputWriter()
+	var rows [][]string
+	for _, index := range check.UnhealthyIndices {
+		rows = append(rows, []string{index.Name, "状态 " + index.Health, fmt.Sprintf("主分片 %d, 副本 %d", index.Primaries, index.Replicas)})
+	}
+	for _, shard := range check.LargeShards {
+		rows = append(rows, []string{shard.Index, "分片过大", fmt.Sprintf("分片 %d 大小 %s, 节点 %s", shard.Shard, formatBytes(shard.Store), shard.Node)})
+	}
+	for _, index := range check.SmallShardIndices {
+		rows = append(rows, []string{index.Name, "分片过多", fmt.Sprintf("主分片 %d, 主分片共 %s", index.Primaries, formatBytes(index.PriStoreSize))})
+	}
+	for _, name := range check.ReadOnlyIndices {
+		rows = append(rows, []string{name, "只读", "index.blocks.read_only_allow_delete"})
+	}
+	for _, err := range check.LifecycleErrors {
+		rows = append(rows, []string{err.Index, strings.ToUpper(check.Lifecycle) + " 错误", fmt.Sprintf("策略 %s 步骤 %s: %s", err.Policy, err.Step, err.Reason)})
+	}
+	if len(check.NoReplicaIndices) > 0 {
+		rows = append(rows, []string{indexNames(check.NoReplicaIndices), "无副本", fmt.Sprintf("%d 个索引副本数为 0", len(check.NoReplicaIndices))})
+	}
+	if len(rows) > 0 {
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"索引", "问题", "详情"})
+		table.AppendBulk(rows)
+		table.SetCaption(true, "索引检查")
+		table.Render()
+	}
+
+	if len(check.Unassigned) > 0 {
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"索引", "分片", "类型", "原因", "分配说明"})
+		for _, allocation := range check.Unassigned {
+			table.Append([]string{allocation.Index, strconv.Itoa(allocation.Shard), shardType(allocation.Primary), allocation.Reason, allocation.Explanation})
+		}
+		table.SetCaption(true, fmt.Sprintf("未分配分片 %d 个，以上为 _cluster/allocation/explain 的说明", es.UnassignedShards))
+		table.Render()
+	}
+}
+
+// findings 索引检查的问题，同类索引合并为一条
+func (check *IndexCheck) findings(production bool) []notify.Finding {
+	var findings []notify.Finding
+	add := func(severity notify.Severity, key, message string) {
+		findings = append(findings, notify.Finding{Key: key, Severity: severity, Message: message})
+	}
+
+	var red, yellow []string
+	for _, index := range check.UnhealthyIndices {
+		if index.Health == "red" {
+			red = append(red, index.Name)
+		} else {
+			yellow = append(yellow, index.Name)
+		}
+	}
+	if len(red) > 0 {
+		add(notify.SeverityCritical, "index_red", "索引状态 red: "+indexNames(red))
+	}
+	if len(yellow) > 0 {
+		add(notify.SeverityWarning, "index_yellow", "索引状态 yellow: "+indexNames(yellow))
+	}
+	if len(check.ReadOnlyIndices) > 0 {
+		add(notify.SeverityCritical, "index_read_only", "索引被设置为只读（read_only_allow_delete），写入将失败: "+indexNames(check.ReadOnlyIndices))
+	}
+	for _, shard := range check.LargeShards {
+		add(notify.SeverityWarning, "large_shard:"+shard.Index, fmt.Sprintf("索引 %s 分片 %d 过大: %s", shard.Index, shard.Shard, formatBytes(shard.Store)))
+	}
+	if len(check.SmallShardIndices) > 0 {
+		var names []string
+		for _, index := range check.SmallShardIndices {
+			names = append(names, index.Name)
+		}
+		add(notify.SeverityInfo, "small_shards", "主分片数过多、平均分片过小，建议减少主分片: "+indexNames(names))
+	}
+	if len(check.NoReplicaIndices) > 0 {
+		severity := notify.SeverityInfo
+		if production {
+			severity = notify.SeverityWarning
+		}
+		add(severity, "no_replica", "索引副本数为 0，节点故障将丢失数据: "+indexNames(check.NoReplicaIndices))
+	}
+	for _, err := range check.LifecycleErrors {
+		add(notify.SeverityWarning, "lifecycle:"+err.Index, fmt.Sprintf("索引 %s 生命周期策略 %s 在 %s 步骤失败: %s", err.Index, err.Policy, err.Step, err.Reason))
+	}
+	for _, allocation := range check.Unassigned {
+		severity := notify.SeverityWarning
+		if allocation.Primary {
+			severity = notify.SeverityCritical
+		}
+		add(severity, fmt.Sprintf("unassigned:%s:%d", allocation.Index, allocation.Shard),
+			fmt.Sprintf("索引 %s 分片 %d（%s）未分配 %s: %s", allocation.Index, allocation.Shard, shardType(allocation.Primary), allocation.Reason, allocation.Explanation))
+	}
+	return findings
+}
+
+func shardType(primary bool) string {
+	if primary {
+		return "主分片"
+	}
+	return "副本"
+}
+
+// indexNames 索引名列表，超过 5 个时省略
+func indexNames(names []string) string {
+	if len(names) > 5 {
+		return fmt.Sprintf("%s 等 %d 个", strings.Join(names[:5], ", "), len(names))
+	}
+	return strings.Join(names, ", ")
+}
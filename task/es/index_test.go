@@ -0,0 +1,73 @@
+package es
+
+import (
+	"testing"
+	"vhagar/notify"
+)
+
+func TestIndexCheckFindings(t *testing.T) {
+	check := &IndexCheck{
+		UnhealthyIndices: []IndexInfo{{Name: "a", Health: "red"}, {Name: "b", Health: "yellow"}, {Name: "c", Health: "red"}},
+		ReadOnlyIndices:  []string{"d"},
+		LargeShards:      []ShardInfo{{Index: "e", Shard: 1, Store: 60 * gb}},
+		NoReplicaIndices: []string{"f"},
+		LifecycleErrors:  []LifecycleError{{Index: "g", Policy: "hot-warm", Step: "rollover", Reason: "alias missing"}},
+		Unassigned: []Allocation{
+			{Index: "h", Shard: 0, Primary: true, Reason: "NODE_LEFT"},
+			{Index: "h", Shard: 1, Reason: "NODE_LEFT"},
+		},
+	}
+	tests := []struct {
+		production bool
+		want       map[string]notify.Severity
+	}{
+		{false, map[string]notify.Severity{
+			"index_red":       notify.SeverityCritical,
+			"index_yellow":    notify.SeverityWarning,
+			"index_read_only": notify.SeverityCritical,
+			"large_shard:e":   notify.SeverityWarning,
+			"no_replica":      notify.SeverityInfo,
+			"lifecycle:g":     notify.SeverityWarning,
+			"unassigned:h:0":  notify.SeverityCritical,
+			"unassigned:h:1":  notify.SeverityWarning,
+		}},
+		// 生产环境没有副本升级为 warning
+		{true, map[string]notify.Severity{"no_replica": notify.SeverityWarning}},
+	}
+	for _, tt := range tests {
+		got := map[string]notify.Finding{}
+		for _, finding := range check.findings(tt.production) {
+			got[finding.Key] = finding
+		}
+		for key, severity := range tt.want {
+			finding, ok := got[key]
+			if !ok {
+				t.Errorf("production=%v: 缺少 %s", tt.production, key)
+				continue
+			}
+			if finding.Severity != severity {
+				t.Errorf("production=%v: %s severity = %s, want %s", tt.production, key, finding.Severity, severity)
+			}
+		}
+		if red := got["index_red"].Message; red != "索引状态 red: a, c" {
+			t.Errorf("index_red message = %q", red)
+		}
+	}
+}
+
+func TestIndexNames(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"a"}, "a"},
+		{[]string{"a", "b", "c", "d", "e"}, "a, b, c, d, e"},
+		{[]string{"a", "b", "c", "d", "e", "f", "g"}, "a, b, c, d, e 等 7 个"},
+	}
+	for _, tt := range tests {
+		if got := indexNames(tt.names); got != tt.want {
+			t.Errorf("indexNames(%v) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
@@ -5,21 +5,31 @@ package es
 
 import (
 	"vhagar/config"
+	"vhagar/libs"
 
-	"github.com/olivere/elastic/v7"
 	"go.uber.org/zap"
 )
 
 type ES struct {
-	Config   *config.CfgType
-	Logger   *zap.SugaredLogger
-	ESClient *elastic.Client
+	Config   *config.CfgType    `json:"-"`
+	Logger   *zap.SugaredLogger `json:"-"`
+	ESClient *libs.ESClient     `json:"-"`
 	NodeList []*NodeInfo
 	Status   string
+	// 发行版和版本，elasticsearch 或 opensearch
+	Distribution string
+	Version      string
 	// 新增字段
 	ClusterJVMUsage  float64
 	UnassignedShards int
 	TotalDataSize    int64
+	IndexCount       int
+	Shards           int
+	DataNodes        int
+	ShardsPerNode    int         // 平均每个数据节点的分片数
+	Indices          *IndexCheck `json:",omitempty"`
+	Watermark        *Watermarks `json:",omitempty"`
+	Conversations    []IndexGrowth
 }
 
 func NewES(cfg *config.CfgType, logger *zap.SugaredLogger) *ES {
@@ -35,7 +45,12 @@ type NodeInfo struct {
 	JVMUsage    float64
 	DiskUsage   float64
 	LoadAverage float64
-	IndexCount  int
-	Shards      int
 	DataSize    int64
+	DiskTotal   int64
+	DiskFree    int64
+	// 集群水位换算成该节点的磁盘使用率
+	LowWatermark   float64
+	HighWatermark  float64
+	FloodWatermark float64
+	Forecast       *DiskForecast `json:",omitempty"`
 }
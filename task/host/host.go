@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"vhagar/config"
 	"vhagar/libs"
+	"vhagar/notify"
 	"vhagar/task"
 
 	"github.com/olekukonko/tablewriter"
@@ -49,8 +50,8 @@ func (s *Server) TableRender() {
 }
 
 func init() {
-	task.Add(taskName, func() task.Tasker {
-		return NewServer(config.Config, libs.Logger)
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewServer(cfg, libs.Logger)
 	})
 }
 
@@ -61,7 +62,7 @@ func (s *Server) ReportRobot() {
 
 func (s *Server) Check() {
 	//task.EchoPrompt("开始巡检服务器状态")
-	if config.Config.Report {
+	if s.Config.Report {
 		// 发送机器人
 		s.ReportRobot()
 		return
@@ -106,6 +107,26 @@ func ipSort(hosts map[string]*Host) []string {
 	return keys
 }
 
+// Findings 实现 task.Finder，资源使用超过阈值的服务器
+func (s *Server) Findings() []notify.Finding {
+	var findings []notify.Finding
+	for _, ident := range ipSort(s.Hosts) {
+		host := s.Hosts[ident]
+		if !isAlarm(host) {
+			continue
+		}
+		findings = append(findings, notify.Finding{
+			Key:      "host:" + ident,
+			Severity: notify.SeverityWarning,
+			Message: fmt.Sprintf("服务器 %s 资源异常: cpu %s, mem %s, 系统盘 %s, 数据盘 %s, 时间偏移 %s", ident,
+				formatToPercentage(host.cpuUsageActive), formatToPercentage(host.MemUsedPercent),
+				formatToPercentage(host.rootDiskUsedPercent), formatToPercentage(host.dataDiskUsedPercent),
+				formatToTime(host.ntpOffsetMs)),
+		})
+	}
+	return findings
+}
+
 func isAlarm(host *Host) bool {
 	if host.cpuUsageActive > 90 {
 		return true
@@ -12,8 +12,8 @@ import (
 const taskName = "host"
 
 type Server struct {
-	Config *config.CfgType
-	Logger *zap.SugaredLogger
+	Config *config.CfgType    `json:"-"`
+	Logger *zap.SugaredLogger `json:"-"`
 	VmUrl  string
 	Hosts  map[string]*Host
 }
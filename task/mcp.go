@@ -3,6 +3,7 @@ package task
 import (
 	"context"
 	"log"
+	"net/http"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -17,14 +18,26 @@ func SayHi(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParam
 	}, nil
 }
 
-func TaskMCP(ctx context.Context) {
+func newMCPServer() *mcp.Server {
 	// Create a server with a single tool.
 	server := mcp.NewServer(&mcp.Implementation{Name: "greeter", Version: "v1.0.0"}, nil)
-
 	mcp.AddTool(server, &mcp.Tool{Name: "greet", Description: "say hi"}, SayHi)
+	return server
+}
+
+func TaskMCP(ctx context.Context) {
+	server := newMCPServer()
 	// Run the server over stdin/stdout, until the client disconnects
 	log.Println("Starting MCP server, waiting for client to connect...")
 	if err := server.Run(ctx, mcp.NewStdioTransport()); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// MCPHandler 通过 Streamable HTTP 提供 MCP 服务，由 serve 挂载到 Web 服务
+func MCPHandler() http.Handler {
+	server := newMCPServer()
+	return mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+		return server
+	}, nil)
+}
@@ -25,8 +25,8 @@ var isalert = false
 var ispush = false
 
 func init() {
-	task.Add(taskName, func() task.Tasker {
-		return NewTenanter(config.Config, libs.Logger)
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewTenanter(cfg, libs.Logger)
 	})
 }
 
@@ -91,7 +91,7 @@ func (tenant *Tenanter) ReportWshoto() {
 func (tenant *Tenanter) Gather() {
 	ispush = false
 	// 创建ESClient，PGClienter
-	esClient, err := libs.NewESClient(tenant.Config.ES)
+	esClient, err := libs.NewESClient(tenant.Config.ES.ESConfig)
 	if err != nil {
 		ispush = true
 		libs.Logger.Errorw("Failed info", "err", err)
@@ -134,6 +134,24 @@ func (tenant *Tenanter) Gather() {
 	libs.Logger.Info("检查成功")
 }
 
+// Findings 实现 task.Finder，当天和昨天都没有拉取到会话为严重问题
+func (tenant *Tenanter) Findings() []notify.Finding {
+	var findings []notify.Finding
+	for _, corp := range tenant.Corp {
+		if corp.Convenabled && corp.MessageNum <= 0 && corp.YesterdayMessageNum <= 0 {
+			findings = append(findings, notify.Finding{
+				Key:      "corp:" + corp.Corpid,
+				Severity: notify.SeverityCritical,
+				Message:  fmt.Sprintf("企业 %s 未拉取到会话", corp.CorpName),
+			})
+		}
+	}
+	if tenant.NasDir != "" && !tenant.DirIsExis {
+		findings = append(findings, notify.Finding{Key: "nas_dir", Severity: notify.SeverityWarning, Message: "当天会话存档目录未创建"})
+	}
+	return findings
+}
+
 func (tenant *Tenanter) getTenantData(corp *config.Corp) {
 	// 当前时间
 	dateNow := time.Now()
@@ -163,7 +181,7 @@ func tenantMarkdown(headString string, Corp []*config.Corp) *notify.WeChatMarkdo
 		}
 	}
 	if isalert {
-		builder.WriteString("\n<font color='red'>**注意！巡检结果异常！**</font>" + task.CallUser(config.Config.Notify.Userlist))
+		builder.WriteString("\n<font color='red'>**注意！巡检结果异常！**</font>" + task.CallUser(notify.Mentions(taskName, notify.SeverityCritical)))
 	}
 	markdown := &notify.WeChatMarkdown{
 		MsgType: "markdown",
@@ -191,7 +209,7 @@ func headCorpString() string {
 	var builder strings.Builder
 	// 组装巡检内容
 	builder.WriteString("# 会话数巡检 \n")
-	builder.WriteString("**项目名称：**<font color='info'>" + config.Config.ProjectName + "</font>\n")
+	builder.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
 	builder.WriteString("**巡检时间：**<font color='info'>" + time.Now().Format("2006-01-02") + "</font>\n")
 	builder.WriteString("**巡检内容：**\n")
 
@@ -245,7 +263,7 @@ func (tenant *Tenanter) SetCorpName(corpid string) {
 }
 
 // 会话数
-func countMessageNum(client *elastic.Client, corpid string, startTime, endTime int64) (int64, error) {
+func countMessageNum(client *libs.ESClient, corpid string, startTime, endTime int64) (int64, error) {
 	// Define the query
 	query := elastic.NewBoolQuery().
 		Must(elastic.NewRangeQuery("msgtime").
@@ -289,7 +307,7 @@ func queryOrgCorpId(conn *pgx.Conn, corpid string) (string, error) {
 	return orgCorpId, nil
 }
 
-func CurrentMessageNum(client *elastic.Client, corpid string, dateNow time.Time) int64 {
+func CurrentMessageNum(client *libs.ESClient, corpid string, dateNow time.Time) int64 {
 	// 统计当前的会话数
 	startTime := task.GetZeroTime(dateNow).UnixNano() / 1e6
 	endTime := dateNow.UnixNano() / 1e6
@@ -7,20 +7,19 @@ import (
 	"vhagar/config"
 	"vhagar/libs"
 
-	"github.com/olivere/elastic/v7"
 	"go.uber.org/zap"
 )
 
 const taskName = "message"
 
 type Tenanter struct {
-	Config    *config.CfgType
-	Logger    *zap.SugaredLogger
+	Config    *config.CfgType    `json:"-"`
+	Logger    *zap.SugaredLogger `json:"-"`
 	NasDir    string
 	DirIsExis bool
 	Corp      []*config.Corp
-	ESClient  *elastic.Client
-	PGClient  *libs.PGClienter
+	ESClient  *libs.ESClient   `json:"-"`
+	PGClient  *libs.PGClienter `json:"-"`
 }
 
 func NewTenanter(cfg *config.CfgType, logger *zap.SugaredLogger) *Tenanter {
@@ -22,13 +22,13 @@ func NewNacos(cfg *config.CfgType, logger *zap.SugaredLogger) *Nacos {
 }
 
 type Nacos struct {
-	Config      *config.CfgType
-	Logger      *zap.SugaredLogger
-	Global      config.Global
-	NacosConfig config.NacosCfg
-	Client      http.Client
+	Config      *config.CfgType    `json:"-"`
+	Logger      *zap.SugaredLogger `json:"-"`
+	Global      config.Global      `json:"-"`
+	NacosConfig config.NacosCfg    `json:"-"`
+	Client      http.Client        `json:"-"`
 	Host        string
-	Token       string
+	Token       string `json:"-"`
 	Clusterdata ClusterStatus
 }
 
@@ -25,13 +25,13 @@ var tablerow []string
 var mutex sync.Mutex
 
 func init() {
-	task.Add(taskName, func() task.Tasker {
-		return NewNacos(config.Config, libs.Logger)
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewNacos(cfg, libs.Logger)
 	})
 }
 
 //func GetNacos() *Nacos {
-//	cfg := config.Config
+//	cfg := config.Get()
 //	nacos := newNacos(cfg)
 //	if !nacos.WithAuth() {
 //		return nil
@@ -54,7 +54,7 @@ func (nacos *Nacos) Check() {
 		nacos.WriteFile()
 		return
 	}
-	if config.Config.Report {
+	if nacos.Config.Report {
 		// 发送机器人
 		nacos.ReportRobot()
 		return
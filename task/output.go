@@ -1,10 +1,12 @@
 package task
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"sync"
 	"vhagar/chat"
 )
@@ -13,8 +15,14 @@ var (
 	outputWriter io.Writer
 	outputFile   *os.File
 	once         sync.Once
+
+	captureMu  sync.Mutex
+	captureBuf *bytes.Buffer
 )
 
+// 终端颜色控制符，保存到运行结果时去掉
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
 const outputFileName = "task_output.log" // 固定文件名
 
 // GetOutputWriter 返回全局唯一的 io.Writer，写入终端和文件
@@ -29,9 +37,33 @@ func GetOutputWriter() io.Writer {
 		outputFile = file
 		outputWriter = io.MultiWriter(os.Stdout, file)
 	})
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	if captureBuf != nil {
+		return io.MultiWriter(outputWriter, captureBuf)
+	}
 	return outputWriter
 }
 
+// startCapture 开始记录本次运行的输出，任务串行执行，同一时间只有一个记录
+func startCapture() {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	captureBuf = &bytes.Buffer{}
+}
+
+// stopCapture 结束记录并返回去掉颜色控制符的输出
+func stopCapture() string {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	if captureBuf == nil {
+		return ""
+	}
+	output := ansiPattern.ReplaceAllString(captureBuf.String(), "")
+	captureBuf = nil
+	return output
+}
+
 // CloseOutputFile 关闭文件，建议在 main 退出时调用
 func CloseOutputFile() {
 	if outputFile != nil {
@@ -0,0 +1,224 @@
+// Package probe @Author lanpang
+// @Date 2025/8/27 上午10:00:00
+// @Desc 单个目标的探测：状态码、JSON 断言、正则、耗时、证书有效期和重定向策略
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"vhagar/config"
+	"vhagar/notify"
+
+	"github.com/tidwall/gjson"
+)
+
+var errRedirect = errors.New("不允许重定向")
+
+// newClient 按目标的重定向、证书和代理配置创建客户端
+func newClient(target config.HTTPTarget, timeout time.Duration, proxyURL string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: target.Insecure}
+	transport.DisableKeepAlives = true
+	if target.Proxy && proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("代理地址无效: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	client := &http.Client{Transport: transport, Timeout: timeout}
+	switch target.Redirect {
+	case "none":
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	case "error":
+		client.CheckRedirect = func(req *http.Request, _ []*http.Request) error {
+			return fmt.Errorf("%w: %s", errRedirect, req.URL)
+		}
+	}
+	return client, nil
+}
+
+// Probe 探测一个目标，proxyURL 为全局代理，仅在目标开启 proxy 时使用
+func Probe(cfg config.HTTPCfg, target config.HTTPTarget, proxyURL string) *Result {
+	result := &Result{
+		Name:     target.Name,
+		URL:      target.URL,
+		Method:   strings.ToUpper(target.Method),
+		Severity: notify.Severity(target.Severity),
+	}
+	if result.Name == "" {
+		result.Name = target.URL
+	}
+	if result.Method == "" {
+		result.Method = http.MethodGet
+	}
+	if result.Severity == "" {
+		result.Severity = notify.SeverityWarning
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	client, err := newClient(target, timeout, proxyURL)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var body io.Reader
+	if target.Body != "" {
+		body = strings.NewReader(target.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, result.Method, target.URL, body)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for key, value := range target.Headers {
+		if strings.EqualFold(key, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(key, value)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Latency = time.Since(start)
+		// 证书过期导致请求失败时仍记录到期时间，按证书过期处理
+		var certErr x509.CertificateInvalidError
+		if errors.As(err, &certErr) && certErr.Reason == x509.Expired && certErr.Cert != nil {
+			checkCert(result, cfg, target, certErr.Cert.NotAfter)
+			return result
+		}
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	result.Latency = time.Since(start)
+	result.StatusCode = resp.StatusCode
+	if err != nil {
+		result.Error = "读取响应体失败: " + err.Error()
+		return result
+	}
+
+	if !expectedCode(target, resp.StatusCode) {
+		result.Failures = append(result.Failures, fmt.Sprintf("状态码 %d 不符合预期", resp.StatusCode))
+	}
+	if target.MaxLatency > 0 && result.Latency > target.MaxLatency {
+		result.Failures = append(result.Failures, fmt.Sprintf("耗时 %s 超过 %s", result.Latency.Round(time.Millisecond), target.MaxLatency))
+	}
+	for _, assert := range target.JSON {
+		if message := checkJSON(data, assert); message != "" {
+			result.Failures = append(result.Failures, message)
+		}
+	}
+	if target.BodyRegex != "" {
+		if pattern, err := regexp.Compile(target.BodyRegex); err != nil {
+			result.Failures = append(result.Failures, "正则无效: "+err.Error())
+		} else if !pattern.Match(data) {
+			result.Failures = append(result.Failures, fmt.Sprintf("响应体不匹配 %s", target.BodyRegex))
+		}
+	}
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		checkCert(result, cfg, target, resp.TLS.PeerCertificates[0].NotAfter)
+	}
+	return result
+}
+
+// expectedCode 未配置期望状态码时 2xx 为正常，不跟随重定向时 3xx 也为正常
+func expectedCode(target config.HTTPTarget, code int) bool {
+	if len(target.ExpectCodes) > 0 {
+		return slices.Contains(target.ExpectCodes, code)
+	}
+	if target.Redirect == "none" && code >= 300 && code < 400 {
+		return true
+	}
+	return code >= 200 && code < 300
+}
+
+// checkCert 检查证书剩余天数，已过期为严重问题
+func checkCert(result *Result, cfg config.HTTPCfg, target config.HTTPTarget, notAfter time.Time) {
+	threshold := target.CertExpiryDays
+	if threshold <= 0 {
+		threshold = cfg.CertExpiryDays
+	}
+	if threshold <= 0 {
+		threshold = defaultCertExpiryDays
+	}
+	result.CertExpiry = &notAfter
+	result.CertDays = int(time.Until(notAfter).Hours() / 24)
+	switch {
+	case time.Now().After(notAfter):
+		result.Severity = notify.SeverityCritical
+		result.Failures = append(result.Failures, fmt.Sprintf("证书已于 %s 过期", notAfter.Format("2006-01-02")))
+	case result.CertDays < threshold:
+		result.Failures = append(result.Failures, fmt.Sprintf("证书 %d 天后过期（%s）", result.CertDays, notAfter.Format("2006-01-02")))
+	}
+}
+
+// checkJSON 检查一个 JSON 断言，通过时返回空字符串
+func checkJSON(data []byte, assert config.JSONAssert) string {
+	value := gjson.GetBytes(data, assert.Path)
+	op := assert.Op
+	if op == "" {
+		op = "=="
+	}
+	if op == "exists" {
+		if !value.Exists() {
+			return fmt.Sprintf("JSON 路径 %s 不存在", assert.Path)
+		}
+		return ""
+	}
+	if !value.Exists() {
+		return fmt.Sprintf("JSON 路径 %s 不存在，期望 %s %s", assert.Path, op, assert.Value)
+	}
+	ok := false
+	switch op {
+	case "==":
+		ok = value.String() == assert.Value
+	case "!=":
+		ok = value.String() != assert.Value
+	case "contains":
+		ok = strings.Contains(value.String(), assert.Value)
+	case ">", ">=", "<", "<=":
+		expected, err := strconv.ParseFloat(assert.Value, 64)
+		if err != nil {
+			return fmt.Sprintf("JSON 断言 %s 的比较值 %q 不是数字", assert.Path, assert.Value)
+		}
+		actual := value.Float()
+		switch op {
+		case ">":
+			ok = actual > expected
+		case ">=":
+			ok = actual >= expected
+		case "<":
+			ok = actual < expected
+		case "<=":
+			ok = actual <= expected
+		}
+	default:
+		return fmt.Sprintf("JSON 断言 %s 的运算符 %q 无效", assert.Path, op)
+	}
+	if !ok {
+		return fmt.Sprintf("JSON %s 为 %s，期望 %s %s", assert.Path, value.String(), op, assert.Value)
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+// Package probe @Author lanpang
+// @Date 2025/8/27 上午10:00:00
+// @Desc
+package probe
+
+import (
+	"time"
+	"vhagar/config"
+	"vhagar/notify"
+
+	"go.uber.org/zap"
+)
+
+const taskName = "http"
+
+// DefaultInterval metrics 模式下未配置 [http] interval 时的探测间隔
+const DefaultInterval = 60 * time.Second
+
+const (
+	defaultTimeout        = 10 * time.Second
+	defaultCertExpiryDays = 14
+	maxBodySize           = 4 << 20 // 断言最多读取的响应体大小
+)
+
+type Prober struct {
+	Config  *config.CfgType    `json:"-"`
+	Logger  *zap.SugaredLogger `json:"-"`
+	Results []*Result
+}
+
+// Result 一个目标的探测结果，Failures 为空时探测成功
+type Result struct {
+	Name       string
+	URL        string
+	Method     string
+	StatusCode int
+	Latency    time.Duration
+	CertExpiry *time.Time `json:",omitempty"` // HTTPS 证书到期时间
+	CertDays   int        `json:",omitempty"` // 证书剩余天数
+	Severity   notify.Severity
+	Error      string   `json:",omitempty"` // 请求失败
+	Failures   []string `json:",omitempty"` // 未通过的检查项
+}
+
+// Success 请求成功且所有检查项通过
+func (r *Result) Success() bool {
+	return r.Error == "" && len(r.Failures) == 0
+}
+
+func NewProber(cfg *config.CfgType, logger *zap.SugaredLogger) *Prober {
+	return &Prober{
+		Config: cfg,
+		Logger: logger,
+	}
+}
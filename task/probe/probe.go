@@ -0,0 +1,165 @@
+// Package probe @Author lanpang
+// @Date 2025/8/27 上午10:00:00
+// @Desc HTTP 接口探测任务，目标在 [[http.targets]] 中配置
+package probe
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+	"vhagar/notify"
+	"vhagar/task"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+func init() {
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewProber(cfg, libs.Logger)
+	})
+}
+
+func (p *Prober) Name() string {
+	return taskName
+}
+
+func (p *Prober) Check() {
+	if p.Config.Report {
+		p.ReportRobot()
+		return
+	}
+	p.TableRender()
+}
+
+// ProbeAll 并发探测所有目标，结果与配置顺序一致
+func ProbeAll(cfg *config.CfgType) []*Result {
+	targets := cfg.HTTP.Targets
+	results := make([]*Result, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target config.HTTPTarget) {
+			defer wg.Done()
+			results[i] = Probe(cfg.HTTP, target, cfg.ProxyURL)
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+func (p *Prober) Gather() {
+	if len(p.Config.HTTP.Targets) == 0 {
+		p.Logger.Infow("未配置 HTTP 探测目标，跳过", "task", taskName)
+		return
+	}
+	p.Results = ProbeAll(p.Config)
+	for _, result := range p.Results {
+		if !result.Success() {
+			p.Logger.Errorw("HTTP 探测失败", "name", result.Name, "url", result.URL, "err", result.Error, "failures", result.Failures)
+		}
+	}
+}
+
+// reason 失败原因
+func (r *Result) reason() string {
+	if r.Error != "" {
+		return r.Error
+	}
+	return strings.Join(r.Failures, "; ")
+}
+
+func (p *Prober) TableRender() {
+	if len(p.Results) == 0 {
+		return
+	}
+	failed := 0
+	table := tablewriter.NewWriter(task.GetOutputWriter())
+	table.SetHeader([]string{"名称", "方法", "URL", "状态码", "耗时", "证书剩余天数", "结果", "说明"})
+	for _, result := range p.Results {
+		status := "正常"
+		if !result.Success() {
+			status = "异常"
+			failed++
+		}
+		certDays := "-"
+		if result.CertExpiry != nil {
+			certDays = strconv.Itoa(result.CertDays)
+		}
+		table.Append([]string{
+			result.Name,
+			result.Method,
+			result.URL,
+			strconv.Itoa(result.StatusCode),
+			result.Latency.Round(time.Millisecond).String(),
+			certDays,
+			status,
+			result.reason(),
+		})
+	}
+	table.SetCaption(true, fmt.Sprintf("总共探测 %d 个接口，%d 个异常", len(p.Results), failed))
+	table.Render()
+}
+
+func (p *Prober) ReportRobot() {
+	if len(p.Results) == 0 {
+		return
+	}
+	var failed []*Result
+	severity := notify.SeverityInfo
+	for _, result := range p.Results {
+		if result.Success() {
+			continue
+		}
+		failed = append(failed, result)
+		if result.Severity.Level() > severity.Level() {
+			severity = result.Severity
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString("# HTTP 接口探测 \n")
+	builder.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
+	builder.WriteString("**巡检时间：**<font color='info'>" + time.Now().Format("2006-01-02 15:04:05") + "</font>\n")
+	builder.WriteString("**探测接口数：**<font color='info'>" + strconv.Itoa(len(p.Results)) + "</font>\n")
+	builder.WriteString("**异常接口数：**<font color='info'>" + strconv.Itoa(len(failed)) + "</font>\n")
+	if len(failed) > 0 {
+		builder.WriteString("==================\n")
+		for _, result := range failed {
+			builder.WriteString(fmt.Sprintf("> <font color='warning'>%s</font>：%s\n", result.Name, result.reason()))
+		}
+	}
+	switch severity {
+	case notify.SeverityCritical:
+		builder.WriteString("\n<font color='red'>**注意！HTTP 接口探测异常！**</font>" + task.CallUser(notify.Mentions(taskName, severity)))
+	case notify.SeverityWarning:
+		builder.WriteString("\n<font color='warning'>**注意！HTTP 接口探测存在告警！**</font>" + task.CallUser(notify.Mentions(taskName, severity)))
+	}
+
+	markdown := &notify.WeChatMarkdown{
+		MsgType: "markdown",
+		Markdown: &notify.Markdown{
+			Content: builder.String(),
+		},
+	}
+	notify.Send(markdown, taskName)
+}
+
+// Findings 实现 task.Finder，按目标配置的级别告警，证书过期为严重问题
+func (p *Prober) Findings() []notify.Finding {
+	var findings []notify.Finding
+	for _, result := range p.Results {
+		if result.Success() {
+			continue
+		}
+		findings = append(findings, notify.Finding{
+			Key:      "http:" + result.Name,
+			Severity: result.Severity,
+			Message:  fmt.Sprintf("HTTP 探测异常 %s: %s", result.Name, result.reason()),
+		})
+	}
+	return findings
+}
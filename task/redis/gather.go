@@ -0,0 +1,331 @@
+// Package redis @Author lanpang
+// @Date 2025/8/15 上午10:00:00
+// @Desc 按部署模式采集 Redis 信息：单机、Cluster、Sentinel
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vhagar/libs"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// 单个实例的采集超时
+const gatherTimeout = 10 * time.Second
+
+// Redis Cluster 槽位总数
+const clusterSlots = 16384
+
+func (redis *Redis) gatherInstance(cfg libs.RedisConfig) *Instance {
+	instance := newInstance(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), gatherTimeout)
+	defer cancel()
+
+	var err error
+	switch instance.Mode {
+	case libs.RedisCluster:
+		err = instance.gatherCluster(ctx, cfg)
+	case libs.RedisSentinel:
+		err = instance.gatherSentinel(ctx, cfg)
+	default:
+		err = instance.gatherStandalone(ctx, cfg)
+	}
+	if err != nil {
+		redis.Logger.Errorw("Redis 巡检失败", "instance", instance.Name, "err", err)
+		instance.Error = err.Error()
+	}
+	return instance
+}
+
+func (instance *Instance) gatherStandalone(ctx context.Context, cfg libs.RedisConfig) error {
+	client, err := libs.NewRedisClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	info, err := client.Info(ctx).Result()
+	if err != nil {
+		return fmt.Errorf("无法获取 Redis 信息: %w", err)
+	}
+	infoMap := parseRedisInfo(info)
+	instance.setInfo(infoMap)
+	instance.Servers = append(instance.Servers, inspectServer(ctx, client, infoMap))
+	return nil
+}
+
+func (instance *Instance) setInfo(infoMap map[string]string) {
+	instance.Version = infoMap["redis_version"]
+	instance.Role = infoMap["role"]
+	instance.Slaves, _ = strconv.Atoi(infoMap["connected_slaves"])
+	instance.CurrentClients, _ = strconv.Atoi(infoMap["connected_clients"])
+	instance.MaxClients, _ = strconv.Atoi(infoMap["maxclients"])
+	instance.UsedMemory, _ = strconv.ParseInt(infoMap["used_memory"], 10, 64)
+	instance.KeyCount = keyCount(infoMap)
+	instance.DBs = keyspaceDBs(infoMap)
+}
+
+// keyCount 所有库的键数量之和，keyspace 中每个库一行：db0:keys=1,expires=0,avg_ttl=0
+func keyCount(infoMap map[string]string) int {
+	total := 0
+	for name, value := range infoMap {
+		if !strings.HasPrefix(name, "db") {
+			continue
+		}
+		if _, err := strconv.Atoi(name[2:]); err != nil {
+			continue
+		}
+		for _, field := range strings.Split(value, ",") {
+			if k, v, ok := strings.Cut(field, "="); ok && k == "keys" {
+				count, _ := strconv.Atoi(v)
+				total += count
+			}
+		}
+	}
+	return total
+}
+
+// keyspaceDBs keyspace 中有键的库号
+func keyspaceDBs(infoMap map[string]string) []int {
+	var dbs []int
+	for name := range infoMap {
+		if !strings.HasPrefix(name, "db") {
+			continue
+		}
+		if db, err := strconv.Atoi(name[2:]); err == nil {
+			dbs = append(dbs, db)
+		}
+	}
+	sort.Ints(dbs)
+	return dbs
+}
+
+func (instance *Instance) gatherCluster(ctx context.Context, cfg libs.RedisConfig) error {
+	client, err := libs.NewRedisClusterClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	cluster := &ClusterInfo{}
+	instance.Cluster = cluster
+	info, err := client.ClusterInfo(ctx).Result()
+	if err != nil {
+		return fmt.Errorf("CLUSTER INFO 失败: %w", err)
+	}
+	infoMap := parseRedisInfo(info)
+	cluster.State = infoMap["cluster_state"]
+	cluster.SlotsAssigned, _ = strconv.Atoi(infoMap["cluster_slots_assigned"])
+	cluster.SlotsOK, _ = strconv.Atoi(infoMap["cluster_slots_ok"])
+	cluster.SlotsPfail, _ = strconv.Atoi(infoMap["cluster_slots_pfail"])
+	cluster.SlotsFail, _ = strconv.Atoi(infoMap["cluster_slots_fail"])
+	cluster.KnownNodes, _ = strconv.Atoi(infoMap["cluster_known_nodes"])
+	cluster.Size, _ = strconv.Atoi(infoMap["cluster_size"])
+
+	nodes, err := client.ClusterNodes(ctx).Result()
+	if err != nil {
+		return fmt.Errorf("CLUSTER NODES 失败: %w", err)
+	}
+	var covered [clusterSlots]bool
+	cluster.Nodes = parseClusterNodes(nodes, &covered)
+	cluster.UncoveredRanges, cluster.UncoveredSlots = uncoveredRanges(covered[:])
+
+	// 每个节点单独检查，连接数、内存和键数量取各主节点之和，版本取任一节点
+	var mu sync.Mutex
+	var masters []map[string]string
+	err = client.ForEachShard(ctx, func(ctx context.Context, node *goredis.Client) error {
+		info, err := node.Info(ctx).Result()
+		if err != nil {
+			return fmt.Errorf("%s: %w", node.Options().Addr, err)
+		}
+		infoMap := parseRedisInfo(info)
+		server := inspectServer(ctx, node, infoMap)
+		mu.Lock()
+		defer mu.Unlock()
+		instance.Servers = append(instance.Servers, server)
+		if infoMap["role"] == "master" {
+			masters = append(masters, infoMap)
+		}
+		return nil
+	})
+	sort.Slice(instance.Servers, func(i, j int) bool { return instance.Servers[i].Addr < instance.Servers[j].Addr })
+	for _, infoMap := range masters {
+		instance.Version = infoMap["redis_version"]
+		count, _ := strconv.Atoi(infoMap["connected_slaves"])
+		instance.Slaves += count
+		clients, _ := strconv.Atoi(infoMap["connected_clients"])
+		instance.CurrentClients += clients
+		maxClients, _ := strconv.Atoi(infoMap["maxclients"])
+		instance.MaxClients += maxClients
+		memory, _ := strconv.ParseInt(infoMap["used_memory"], 10, 64)
+		instance.UsedMemory += memory
+		instance.KeyCount += keyCount(infoMap)
+	}
+	instance.Role = fmt.Sprintf("cluster(%d 主)", len(masters))
+	if err != nil {
+		return fmt.Errorf("获取节点信息失败: %w", err)
+	}
+	return nil
+}
+
+// parseClusterNodes 解析 CLUSTER NODES，每行格式：
+// <id> <ip:port@cport[,hostname]> <flags> <master> <ping-sent> <pong-recv> <config-epoch> <link-state> <slot> ...
+// 正常的主节点负责的槽位记入 covered
+func parseClusterNodes(text string, covered *[clusterSlots]bool) []ClusterNode {
+	var nodes []ClusterNode
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			continue
+		}
+		node := ClusterNode{
+			ID:        fields[0],
+			Addr:      strings.SplitN(strings.SplitN(fields[1], "@", 2)[0], ",", 2)[0],
+			Flags:     fields[2],
+			LinkState: fields[7],
+		}
+		if fields[3] != "-" {
+			node.MasterID = fields[3]
+		}
+		for _, flag := range strings.Split(node.Flags, ",") {
+			switch flag {
+			case "master", "slave":
+				node.Role = flag
+			case "fail":
+				node.Fail = true
+			case "fail?":
+				node.PFail = true
+			}
+		}
+		for _, slot := range fields[8:] {
+			// [slot->-id] 为迁移中的槽位
+			if strings.HasPrefix(slot, "[") {
+				continue
+			}
+			start, end, found := strings.Cut(slot, "-")
+			from, err := strconv.Atoi(start)
+			if err != nil {
+				continue
+			}
+			to := from
+			if found {
+				if to, err = strconv.Atoi(end); err != nil {
+					continue
+				}
+			}
+			for i := from; i <= to && i < clusterSlots; i++ {
+				node.Slots++
+				if node.Role == "master" && !node.Fail {
+					covered[i] = true
+				}
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Addr < nodes[j].Addr })
+	return nodes
+}
+
+// uncoveredRanges 未覆盖的槽位区间和数量
+func uncoveredRanges(covered []bool) ([]string, int) {
+	var ranges []string
+	count := 0
+	for i := 0; i < len(covered); i++ {
+		if covered[i] {
+			continue
+		}
+		j := i
+		for j+1 < len(covered) && !covered[j+1] {
+			j++
+		}
+		count += j - i + 1
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(i))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", i, j))
+		}
+		i = j
+	}
+	return ranges, count
+}
+
+func (instance *Instance) gatherSentinel(ctx context.Context, cfg libs.RedisConfig) error {
+	client, err := libs.NewRedisSentinelClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	sentinel := &SentinelInfo{MasterName: cfg.MasterName}
+	instance.Sentinel = sentinel
+	master, err := client.Master(ctx, cfg.MasterName).Result()
+	if err != nil {
+		return fmt.Errorf("获取主节点 %s 失败: %w", cfg.MasterName, err)
+	}
+	sentinel.MasterAddr = net.JoinHostPort(master["ip"], master["port"])
+	sentinel.MasterFlags = master["flags"]
+	sentinel.Quorum, _ = strconv.Atoi(master["quorum"])
+	others, _ := strconv.Atoi(master["num-other-sentinels"])
+	sentinel.Sentinels = others + 1
+
+	if msg, err := client.CkQuorum(ctx, cfg.MasterName).Result(); err != nil {
+		sentinel.QuorumMsg = err.Error()
+	} else {
+		sentinel.QuorumOK = true
+		sentinel.QuorumMsg = msg
+	}
+
+	replicas, err := client.Slaves(ctx, cfg.MasterName).Result()
+	if err != nil {
+		return fmt.Errorf("获取从节点失败: %w", err)
+	}
+	for _, item := range replicas {
+		fields := pairs(item)
+		sentinel.Replicas = append(sentinel.Replicas, SentinelReplica{
+			Addr:       net.JoinHostPort(fields["ip"], fields["port"]),
+			Flags:      fields["flags"],
+			LinkStatus: fields["master-link-status"],
+		})
+	}
+	sort.Slice(sentinel.Replicas, func(i, j int) bool { return sentinel.Replicas[i].Addr < sentinel.Replicas[j].Addr })
+
+	// 连接哨兵返回的主节点读取运行信息
+	masterCfg := cfg
+	masterCfg.Addr = sentinel.MasterAddr
+	if err := instance.gatherStandalone(ctx, masterCfg); err != nil {
+		return err
+	}
+	// 从节点单独检查主从链路，连接失败时哨兵的下线标记已能反映
+	for _, replica := range sentinel.Replicas {
+		replicaCfg := cfg
+		replicaCfg.Addr = replica.Addr
+		client, err := libs.NewRedisClient(replicaCfg)
+		if err != nil {
+			continue
+		}
+		if info, err := client.Info(ctx).Result(); err == nil {
+			instance.Servers = append(instance.Servers, inspectServer(ctx, client, parseRedisInfo(info)))
+		}
+		_ = client.Close()
+	}
+	return nil
+}
+
+// pairs 哨兵返回的 [k1 v1 k2 v2 ...] 转为 map
+func pairs(item interface{}) map[string]string {
+	result := map[string]string{}
+	list, ok := item.([]interface{})
+	if !ok {
+		return result
+	}
+	for i := 0; i+1 < len(list); i += 2 {
+		result[fmt.Sprint(list[i])] = fmt.Sprint(list[i+1])
+	}
+	return result
+}
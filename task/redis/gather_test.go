@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseClusterNodes(t *testing.T) {
+	const text = `07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004,redis-4 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected
+67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 127.0.0.1:30002@31002 master - 0 1426238316232 2 connected 5461-10922
+292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 127.0.0.1:30003@31003 master,fail - 1426238317741 1426238315000 3 disconnected 10923-16383
+e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master - 0 0 1 connected 0-5459 5460 [5461->-67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1]
+6ec23923021cf3ffec47632106199cb7f496ce01 127.0.0.1:30005@31005 slave,fail? 67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 0 1426238316232 5 connected
+bad line
+`
+	var covered [clusterSlots]bool
+	nodes := parseClusterNodes(text, &covered)
+
+	want := []struct {
+		addr     string
+		role     string
+		masterID string
+		slots    int
+		fail     bool
+		pfail    bool
+	}{
+		{"127.0.0.1:30001", "master", "", 5461, false, false},
+		{"127.0.0.1:30002", "master", "", 5462, false, false},
+		{"127.0.0.1:30003", "master", "", 5461, true, false},
+		{"127.0.0.1:30004", "slave", "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca", 0, false, false},
+		{"127.0.0.1:30005", "slave", "67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1", 0, false, true},
+	}
+	if len(nodes) != len(want) {
+		t.Fatalf("解析出 %d 个节点，want %d: %+v", len(nodes), len(want), nodes)
+	}
+	for i, w := range want {
+		n := nodes[i]
+		if n.Addr != w.addr || n.Role != w.role || n.MasterID != w.masterID || n.Slots != w.slots || n.Fail != w.fail || n.PFail != w.pfail {
+			t.Errorf("节点 %d = %+v, want %+v", i, n, w)
+		}
+	}
+
+	// 下线主节点的槽位不算覆盖
+	ranges, count := uncoveredRanges(covered[:])
+	if !reflect.DeepEqual(ranges, []string{"10923-16383"}) || count != 5461 {
+		t.Errorf("uncoveredRanges = %v, %d", ranges, count)
+	}
+}
+
+func TestUncoveredRanges(t *testing.T) {
+	slots := func(size int, covered ...int) []bool {
+		s := make([]bool, size)
+		for _, i := range covered {
+			s[i] = true
+		}
+		return s
+	}
+	tests := []struct {
+		name    string
+		covered []bool
+		ranges  []string
+		count   int
+	}{
+		{"全部覆盖", slots(4, 0, 1, 2, 3), nil, 0},
+		{"全部未覆盖", slots(4), []string{"0-3"}, 4},
+		{"单个槽位", slots(4, 0, 1, 3), []string{"2"}, 1},
+		{"首尾缺失", slots(6, 1, 2, 3), []string{"0", "4-5"}, 3},
+		{"多段", slots(8, 2, 5), []string{"0-1", "3-4", "6-7"}, 6},
+	}
+	for _, tt := range tests {
+		ranges, count := uncoveredRanges(tt.covered)
+		if !reflect.DeepEqual(ranges, tt.ranges) || count != tt.count {
+			t.Errorf("%s: uncoveredRanges = %v, %d, want %v, %d", tt.name, ranges, count, tt.ranges, tt.count)
+		}
+	}
+}
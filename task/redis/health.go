@@ -0,0 +1,261 @@
+// Package redis @Author lanpang
+// @Date 2025/8/16 上午10:00:00
+// @Desc 单个 Redis 节点的深度检查：内存、持久化、主从复制、慢查询和延迟事件
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"vhagar/notify"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// 检查阈值
+const (
+	memoryWarnPercent     = 80
+	memoryCriticalPercent = 90
+	fragRatioWarn         = 1.5
+	fragMinMemory         = 100 * 1024 * 1024 // 内存较小时碎片率波动大，不检查
+	lastSaveWarn          = 24 * time.Hour
+	replicaLagBytesWarn   = 10 * 1024 * 1024
+	replicaLagSecondsWarn = 30
+	slowLogCount          = 10
+	slowLogWindow         = 24 * time.Hour
+	slowWarn              = 100 * time.Millisecond
+	latencyWarn           = 100 // ms
+)
+
+// Server 单个节点的运行状态
+type Server struct {
+	Addr             string
+	Role             string
+	UsedMemory       int64
+	MaxMemory        int64
+	MaxMemoryPolicy  string
+	FragRatio        float64
+	EvictedKeys      int64
+	RejectedConns    int64
+	RDBStatus        string
+	RDBChanges       int64 // 上次保存后的修改次数
+	LastSave         time.Time
+	AOFEnabled       bool
+	AOFStatus        string
+	MasterLinkStatus string `json:",omitempty"` // 从节点与主节点的链路状态
+	Replicas         []ReplicaLag
+	SlowLogs         []SlowLog
+	Latency          []LatencyEvent
+}
+
+// ReplicaLag 主节点视角的从节点复制进度
+type ReplicaLag struct {
+	Addr     string
+	State    string
+	LagBytes int64 // master_repl_offset 与从节点 offset 之差
+	LagSecs  int64 // 距上次收到从节点 ACK 的秒数
+}
+
+type SlowLog struct {
+	Time     time.Time
+	Duration time.Duration
+	Command  string
+}
+
+// LatencyEvent LATENCY LATEST 的一条事件，需开启 latency-monitor-threshold
+type LatencyEvent struct {
+	Event  string
+	Time   time.Time
+	Latest int64 // ms
+	Max    int64 // ms
+}
+
+// inspectServer 采集节点的深度检查信息，infoMap 为已读取的 INFO
+func inspectServer(ctx context.Context, client *goredis.Client, infoMap map[string]string) *Server {
+	server := &Server{
+		Addr:             client.Options().Addr,
+		Role:             infoMap["role"],
+		MaxMemoryPolicy:  infoMap["maxmemory_policy"],
+		RDBStatus:        infoMap["rdb_last_bgsave_status"],
+		AOFEnabled:       infoMap["aof_enabled"] == "1",
+		AOFStatus:        infoMap["aof_last_write_status"],
+		MasterLinkStatus: infoMap["master_link_status"],
+	}
+	server.UsedMemory, _ = strconv.ParseInt(infoMap["used_memory"], 10, 64)
+	server.MaxMemory, _ = strconv.ParseInt(infoMap["maxmemory"], 10, 64)
+	server.FragRatio, _ = strconv.ParseFloat(infoMap["mem_fragmentation_ratio"], 64)
+	server.EvictedKeys, _ = strconv.ParseInt(infoMap["evicted_keys"], 10, 64)
+	server.RejectedConns, _ = strconv.ParseInt(infoMap["rejected_connections"], 10, 64)
+	server.RDBChanges, _ = strconv.ParseInt(infoMap["rdb_changes_since_last_save"], 10, 64)
+	if ts, err := strconv.ParseInt(infoMap["rdb_last_save_time"], 10, 64); err == nil && ts > 0 {
+		server.LastSave = time.Unix(ts, 0)
+	}
+	server.Replicas = replicaLags(infoMap)
+
+	// 慢查询和延迟事件可能被 rename-command 禁用，失败时跳过
+	if logs, err := client.SlowLogGet(ctx, slowLogCount).Result(); err == nil {
+		for _, log := range logs {
+			server.SlowLogs = append(server.SlowLogs, SlowLog{Time: log.Time, Duration: log.Duration, Command: slowCommand(log.Args)})
+		}
+	}
+	if result, err := client.Do(ctx, "latency", "latest").Slice(); err == nil {
+		for _, item := range result {
+			fields, ok := item.([]interface{})
+			if !ok || len(fields) < 4 {
+				continue
+			}
+			event := LatencyEvent{Event: fmt.Sprint(fields[0])}
+			ts, _ := fields[1].(int64)
+			event.Time = time.Unix(ts, 0)
+			event.Latest, _ = fields[2].(int64)
+			event.Max, _ = fields[3].(int64)
+			server.Latency = append(server.Latency, event)
+		}
+	}
+	return server
+}
+
+// replicaLags 解析 INFO replication 中的 slaveN:ip=x,port=6379,state=online,offset=100,lag=0
+func replicaLags(infoMap map[string]string) []ReplicaLag {
+	masterOffset, _ := strconv.ParseInt(infoMap["master_repl_offset"], 10, 64)
+	count, _ := strconv.Atoi(infoMap["connected_slaves"])
+	var lags []ReplicaLag
+	for i := 0; i < count; i++ {
+		value, ok := infoMap["slave"+strconv.Itoa(i)]
+		if !ok {
+			continue
+		}
+		fields := map[string]string{}
+		for _, field := range strings.Split(value, ",") {
+			if k, v, ok := strings.Cut(field, "="); ok {
+				fields[k] = v
+			}
+		}
+		offset, _ := strconv.ParseInt(fields["offset"], 10, 64)
+		lag := ReplicaLag{Addr: fields["ip"] + ":" + fields["port"], State: fields["state"], LagBytes: masterOffset - offset}
+		lag.LagSecs, _ = strconv.ParseInt(fields["lag"], 10, 64)
+		lags = append(lags, lag)
+	}
+	return lags
+}
+
+// slowCommand 慢查询命令，参数过长时截断
+func slowCommand(args []string) string {
+	command := strings.Join(args, " ")
+	if len([]rune(command)) > 80 {
+		command = string([]rune(command)[:80]) + "..."
+	}
+	return command
+}
+
+// MemoryPercent 内存使用占 maxmemory 的百分比，未设置 maxmemory 时为 0
+func (server *Server) MemoryPercent() float64 {
+	if server.MaxMemory <= 0 {
+		return 0
+	}
+	return float64(server.UsedMemory) / float64(server.MaxMemory) * 100
+}
+
+// findings 节点的检查结果，prefix 为所属实例名称
+func (server *Server) findings(prefix string) []notify.Finding {
+	var findings []notify.Finding
+	// 单机实例未配置名称时名称即地址，不重复显示
+	label := prefix + " 节点 " + server.Addr
+	if prefix == server.Addr {
+		label = prefix
+	}
+	add := func(severity notify.Severity, key, message string) {
+		findings = append(findings, notify.Finding{
+			Key:      prefix + ":" + server.Addr + ":" + key,
+			Severity: severity,
+			Message:  label + " " + message,
+		})
+	}
+
+	// 内存
+	switch percent := server.MemoryPercent(); {
+	case server.MaxMemory <= 0:
+		add(notify.SeverityInfo, "maxmemory", "未设置 maxmemory，内存可能无限增长")
+	case percent >= memoryCriticalPercent:
+		add(notify.SeverityCritical, "memory", fmt.Sprintf("内存使用 %.1f%%（%s/%s）", percent, formatMemory(server.UsedMemory), formatMemory(server.MaxMemory)))
+	case percent >= memoryWarnPercent:
+		add(notify.SeverityWarning, "memory", fmt.Sprintf("内存使用 %.1f%%（%s/%s）", percent, formatMemory(server.UsedMemory), formatMemory(server.MaxMemory)))
+	}
+	if server.UsedMemory >= fragMinMemory && server.FragRatio > fragRatioWarn {
+		add(notify.SeverityWarning, "fragmentation", fmt.Sprintf("内存碎片率过高: %.2f", server.FragRatio))
+	}
+	if server.FragRatio > 0 && server.FragRatio < 1 {
+		add(notify.SeverityWarning, "swap", fmt.Sprintf("内存碎片率 %.2f 小于 1，可能使用了 swap", server.FragRatio))
+	}
+	if server.EvictedKeys > 0 {
+		add(notify.SeverityWarning, "evicted", fmt.Sprintf("已淘汰 %d 个键，内存不足", server.EvictedKeys))
+	}
+	if server.RejectedConns > 0 {
+		add(notify.SeverityWarning, "rejected", fmt.Sprintf("已拒绝 %d 个连接，连接数达到 maxclients", server.RejectedConns))
+	}
+
+	// 持久化
+	if server.RDBStatus != "" && server.RDBStatus != "ok" {
+		add(notify.SeverityCritical, "rdb", "RDB 最近一次保存失败: "+server.RDBStatus)
+	}
+	if server.AOFEnabled && server.AOFStatus != "" && server.AOFStatus != "ok" {
+		add(notify.SeverityCritical, "aof", "AOF 最近一次写入失败: "+server.AOFStatus)
+	}
+	if !server.LastSave.IsZero() && server.RDBChanges > 0 {
+		if age := time.Since(server.LastSave); age > lastSaveWarn {
+			add(notify.SeverityWarning, "last_save", fmt.Sprintf("已 %s 未保存 RDB，期间修改 %d 次", formatAge(age), server.RDBChanges))
+		}
+	}
+
+	// 主从复制
+	if server.Role == "slave" && server.MasterLinkStatus != "up" {
+		add(notify.SeverityCritical, "master_link", "与主节点的链路断开: "+server.MasterLinkStatus)
+	}
+	for _, replica := range server.Replicas {
+		switch {
+		case replica.State != "online":
+			add(notify.SeverityWarning, "replica:"+replica.Addr, fmt.Sprintf("从节点 %s 状态 %s", replica.Addr, replica.State))
+		case replica.LagBytes > replicaLagBytesWarn || replica.LagSecs > replicaLagSecondsWarn:
+			add(notify.SeverityWarning, "replica:"+replica.Addr, fmt.Sprintf("从节点 %s 复制延迟 %s，%d 秒未确认", replica.Addr, formatMemory(replica.LagBytes), replica.LagSecs))
+		}
+	}
+
+	// 慢查询：统计最近一天内的记录
+	var recent int
+	var slowest SlowLog
+	for _, log := range server.SlowLogs {
+		if time.Since(log.Time) > slowLogWindow {
+			continue
+		}
+		recent++
+		if log.Duration > slowest.Duration {
+			slowest = log
+		}
+	}
+	if recent > 0 {
+		severity := notify.SeverityInfo
+		if slowest.Duration >= slowWarn {
+			severity = notify.SeverityWarning
+		}
+		add(severity, "slowlog", fmt.Sprintf("最近一天 %d 条慢查询，最慢 %s: %s", recent, slowest.Duration.Round(time.Millisecond), slowest.Command))
+	}
+
+	// 延迟事件
+	for _, event := range server.Latency {
+		severity := notify.SeverityInfo
+		if event.Max >= latencyWarn {
+			severity = notify.SeverityWarning
+		}
+		add(severity, "latency:"+event.Event, fmt.Sprintf("延迟事件 %s 最近 %dms，最大 %dms", event.Event, event.Latest, event.Max))
+	}
+	return findings
+}
+
+func formatAge(age time.Duration) string {
+	if age >= 24*time.Hour {
+		return fmt.Sprintf("%.1f 天", age.Hours()/24)
+	}
+	return fmt.Sprintf("%.1f 小时", age.Hours())
+}
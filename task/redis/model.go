@@ -13,22 +13,86 @@ import (
 const taskName = "redis"
 
 type Redis struct {
-	Config         *config.CfgType
-	Logger         *zap.SugaredLogger
-	RedisConfig    libs.RedisConfig
+	Config    *config.CfgType    `json:"-"`
+	Logger    *zap.SugaredLogger `json:"-"`
+	Instances []*Instance
+}
+
+// Instance 一个 Redis 部署的巡检结果，cluster 的连接数、内存和键数量为所有主节点之和
+type Instance struct {
+	Name           string
+	Mode           string
+	Addr           string
+	Error          string `json:",omitempty"` // 无法连接或采集失败
 	Version        string
 	Role           string
 	Slaves         int
 	CurrentClients int
 	MaxClients     int
-	UsedMemory     string
+	UsedMemory     int64
 	KeyCount       int
+	DBs            []int         `json:"-"` // 有键的库，扫描时逐个扫描
+	Cluster        *ClusterInfo  `json:",omitempty"`
+	Sentinel       *SentinelInfo `json:",omitempty"`
+	Servers        []*Server     // 各数据节点的深度检查
+	Scan           *KeyScan      `json:",omitempty"` // --scan-keys 的大键、热键扫描结果
+}
+
+// ClusterInfo CLUSTER INFO 和 CLUSTER NODES 的结果
+type ClusterInfo struct {
+	State           string
+	SlotsAssigned   int
+	SlotsOK         int
+	SlotsPfail      int
+	SlotsFail       int
+	KnownNodes      int
+	Size            int
+	UncoveredSlots  int      // 没有正常主节点负责的槽位数
+	UncoveredRanges []string `json:",omitempty"`
+	Nodes           []ClusterNode
+}
+
+type ClusterNode struct {
+	ID        string
+	Addr      string
+	Role      string // master、slave
+	Flags     string
+	MasterID  string `json:",omitempty"`
+	LinkState string
+	Slots     int
+	Fail      bool // 已被集群判定下线
+	PFail     bool // 疑似下线
+}
+
+// SentinelInfo 哨兵视角的主从拓扑
+type SentinelInfo struct {
+	MasterName  string
+	MasterAddr  string
+	MasterFlags string
+	Quorum      int
+	Sentinels   int // 包括当前连接的哨兵
+	QuorumOK    bool
+	QuorumMsg   string
+	Replicas    []SentinelReplica
+}
+
+type SentinelReplica struct {
+	Addr       string
+	Flags      string
+	LinkStatus string // master-link-status
 }
 
 func NewRedis(cfg *config.CfgType, logger *zap.SugaredLogger) *Redis {
 	return &Redis{
-		Config:      cfg,
-		Logger:      logger,
-		RedisConfig: cfg.Redis,
+		Config: cfg,
+		Logger: logger,
+	}
+}
+
+func newInstance(cfg libs.RedisConfig) *Instance {
+	mode := cfg.Mode
+	if mode == "" {
+		mode = libs.RedisStandalone
 	}
+	return &Instance{Name: cfg.Label(), Mode: mode, Addr: cfg.Addr}
 }
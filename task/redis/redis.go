@@ -1,9 +1,7 @@
 package redis
 
 import (
-	"context"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
 	"time"
@@ -16,15 +14,14 @@ import (
 )
 
 func init() {
-	task.Add(taskName, func() task.Tasker {
-		return NewRedis(config.Config, libs.Logger)
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewRedis(cfg, libs.Logger)
 	})
 }
 
 func (redis *Redis) Check() {
 	//task.EchoPrompt("开始巡检 Redis 状态信息")
-	redis.Gather()
-	if config.Config.Report {
+	if redis.Config.Report {
 		// 发送机器人
 		redis.ReportRobot()
 		return
@@ -33,56 +30,77 @@ func (redis *Redis) Check() {
 }
 
 func (redis *Redis) Gather() {
-	redisClient, err := libs.NewRedisClient(redis.Config.Redis)
-	if err != nil {
-		redis.Logger.Errorw("Failed to create redis client", "err", err)
+	instances := redis.Config.Redis.List()
+	if len(instances) == 0 {
+		redis.Logger.Errorw("未配置 Redis 地址")
 		return
 	}
-
-	defer func() {
-		if redisClient != nil {
-			err := redisClient.Close()
-			if err != nil {
-				return
-			}
+	for _, cfg := range instances {
+		instance := redis.gatherInstance(cfg)
+		if redis.Config.Redis.Scan.Enable && instance.Error == "" {
+			redis.scanKeys(instance, cfg)
 		}
-	}()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	info, err := redisClient.Info(ctx).Result()
-	if err != nil {
-		log.Printf("无法获取 Redis 信息: %v", err)
-		redis.Logger.Error("无法获取 Redis 信息: %v", err)
-		return
+		redis.Instances = append(redis.Instances, instance)
 	}
-
-	infoMap := parseRedisInfo(info)
-
-	redis.Version = infoMap["redis_version"]
-	redis.Role = infoMap["role"]
-	redis.Slaves, _ = strconv.Atoi(infoMap["connected_slaves"])
-	redis.CurrentClients, _ = strconv.Atoi(infoMap["connected_clients"])
-	redis.MaxClients, _ = strconv.Atoi(infoMap["maxclients"])
-	redis.UsedMemory = formatMemory(infoMap["used_memory"])
-	redis.KeyCount, _ = strconv.Atoi(strings.Split(strings.Split(infoMap["db0"], ",")[0], "=")[1])
 }
 
 func (redis *Redis) TableRender() {
-	table := tablewriter.NewWriter(task.GetOutputWriter())
-	table.SetHeader([]string{"属性", "值"})
-	table.SetBorder(false)
-	table.AppendBulk([][]string{
-		{"版本", redis.Version},
-		{"角色", redis.Role},
-		{"从节点数", strconv.Itoa(redis.Slaves)},
-		{"当前连接数", strconv.Itoa(redis.CurrentClients)},
-		{"最大连接数", strconv.Itoa(redis.MaxClients)},
-		{"使用内存", redis.UsedMemory},
-		{"键数量", strconv.Itoa(redis.KeyCount)},
-	})
+	out := task.GetOutputWriter()
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"实例", "模式", "版本", "角色", "从节点数", "当前连接数", "最大连接数", "使用内存", "键数量", "状态"})
+	for _, instance := range redis.Instances {
+		status := "正常"
+		if instance.Error != "" {
+			status = instance.Error
+		}
+		table.Append([]string{
+			instance.Name,
+			instance.Mode,
+			instance.Version,
+			instance.Role,
+			strconv.Itoa(instance.Slaves),
+			strconv.Itoa(instance.CurrentClients),
+			strconv.Itoa(instance.MaxClients),
+			formatMemory(instance.UsedMemory),
+			strconv.Itoa(instance.KeyCount),
+			status,
+		})
+	}
 	table.Render()
+
+	for _, instance := range redis.Instances {
+		if cluster := instance.Cluster; cluster != nil {
+			table := tablewriter.NewWriter(out)
+			table.SetHeader([]string{"节点", "角色", "标记", "连接状态", "槽位数"})
+			for _, node := range cluster.Nodes {
+				table.Append([]string{node.Addr, node.Role, node.Flags, node.LinkState, strconv.Itoa(node.Slots)})
+			}
+			table.SetCaption(true, fmt.Sprintf("%s 集群状态: %s, 已分配槽位: %d, 正常槽位: %d, 未覆盖槽位: %d, 节点数: %d",
+				instance.Name, cluster.State, cluster.SlotsAssigned, cluster.SlotsOK, cluster.UncoveredSlots, cluster.KnownNodes))
+			table.Render()
+		}
+		if sentinel := instance.Sentinel; sentinel != nil {
+			table := tablewriter.NewWriter(out)
+			table.SetHeader([]string{"节点", "角色", "标记", "主从链路"})
+			table.Append([]string{sentinel.MasterAddr, "master", sentinel.MasterFlags, "-"})
+			for _, replica := range sentinel.Replicas {
+				table.Append([]string{replica.Addr, "slave", replica.Flags, replica.LinkStatus})
+			}
+			table.SetCaption(true, fmt.Sprintf("%s 主节点: %s, 哨兵数: %d, quorum: %d, %s",
+				instance.Name, sentinel.MasterName, sentinel.Sentinels, sentinel.Quorum, sentinel.QuorumMsg))
+			table.Render()
+		}
+	}
+
+	redis.renderServers()
+	redis.renderScans()
+
+	if findings := redis.Findings(); len(findings) > 0 {
+		fmt.Fprintln(out, "警告:")
+		for _, finding := range findings {
+			fmt.Fprintf(out, "- [%s] %s\n", finding.Severity, finding.Message)
+		}
+	}
 }
 
 func (redis *Redis) Name() string {
@@ -104,8 +122,7 @@ func parseRedisInfo(info string) map[string]string {
 	return result
 }
 
-func formatMemory(memoryStr string) string {
-	memory, _ := strconv.ParseInt(memoryStr, 10, 64)
+func formatMemory(memory int64) string {
 	if memory < 1024 {
 		return fmt.Sprintf("%d B", memory)
 	} else if memory < 1024*1024 {
@@ -121,15 +138,54 @@ func (redis *Redis) ReportRobot() {
 	var builder strings.Builder
 	// 组装巡检内容
 	builder.WriteString("# Redis 巡检 \n")
-	builder.WriteString("**项目名称：**<font color='info'>" + config.Config.ProjectName + "</font>\n")
+	builder.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
 	builder.WriteString("**巡检时间：**<font color='info'>" + time.Now().Format("2006-01-02") + "</font>\n")
-	builder.WriteString("**版本：**<font color='info'>" + redis.Version + "</font>\n")
-	builder.WriteString("**角色：**<font color='info'>" + redis.Role + "</font>\n")
-	builder.WriteString("**从节点数：**<font color='info'>" + strconv.Itoa(redis.Slaves) + "</font>\n")
-	builder.WriteString("**当前连接数：**<font color='info'>" + strconv.Itoa(redis.CurrentClients) + "</font>\n")
-	builder.WriteString("**最大连接数：**<font color='info'>" + strconv.Itoa(redis.MaxClients) + "</font>\n")
-	builder.WriteString("**使用内存：**<font color='info'>" + redis.UsedMemory + "</font>\n")
-	builder.WriteString("**键数量：**<font color='info'>" + strconv.Itoa(redis.KeyCount) + "</font>\n")
+	for _, instance := range redis.Instances {
+		builder.WriteString("==================\n")
+		builder.WriteString("## 实例：<font color='info'>" + instance.Name + "</font>\n")
+		builder.WriteString("**模式：**<font color='info'>" + instance.Mode + "</font>\n")
+		if instance.Error != "" {
+			builder.WriteString("**状态：**<font color='red'>" + instance.Error + "</font>\n")
+			continue
+		}
+		builder.WriteString("**版本：**<font color='info'>" + instance.Version + "</font>\n")
+		builder.WriteString("**角色：**<font color='info'>" + instance.Role + "</font>\n")
+		builder.WriteString("**从节点数：**<font color='info'>" + strconv.Itoa(instance.Slaves) + "</font>\n")
+		builder.WriteString("**当前连接数：**<font color='info'>" + strconv.Itoa(instance.CurrentClients) + "</font>\n")
+		builder.WriteString("**最大连接数：**<font color='info'>" + strconv.Itoa(instance.MaxClients) + "</font>\n")
+		builder.WriteString("**使用内存：**<font color='info'>" + formatMemory(instance.UsedMemory) + "</font>\n")
+		builder.WriteString("**键数量：**<font color='info'>" + strconv.Itoa(instance.KeyCount) + "</font>\n")
+		if cluster := instance.Cluster; cluster != nil {
+			builder.WriteString("**集群状态：**<font color='info'>" + cluster.State + "</font>\n")
+			builder.WriteString(fmt.Sprintf("**节点数：**<font color='info'>%d</font>\n", cluster.KnownNodes))
+			builder.WriteString(fmt.Sprintf("**未覆盖槽位：**<font color='info'>%d</font>\n", cluster.UncoveredSlots))
+		}
+		if sentinel := instance.Sentinel; sentinel != nil {
+			builder.WriteString("**主节点：**<font color='info'>" + sentinel.MasterName + " " + sentinel.MasterAddr + "</font>\n")
+			builder.WriteString(fmt.Sprintf("**哨兵数：**<font color='info'>%d (quorum %d)</font>\n", sentinel.Sentinels, sentinel.Quorum))
+		}
+		if instance.Scan != nil {
+			instance.Scan.report(&builder)
+		}
+	}
+
+	findings := redis.Findings()
+	if len(findings) > 0 {
+		builder.WriteString("\n警告:\n")
+		for _, finding := range findings {
+			builder.WriteString(fmt.Sprintf("- %s\n", finding.Message))
+		}
+		// 只有提示级别的问题时不@人
+		severity := notify.SeverityInfo
+		for _, finding := range findings {
+			if finding.Severity.Level() > severity.Level() {
+				severity = finding.Severity
+			}
+		}
+		if severity != notify.SeverityInfo {
+			builder.WriteString(task.CallUser(notify.Mentions(taskName, severity)))
+		}
+	}
 
 	markdown := &notify.WeChatMarkdown{
 		MsgType: "markdown",
@@ -140,3 +196,148 @@ func (redis *Redis) ReportRobot() {
 
 	notify.Send(markdown, taskName)
 }
+
+// Findings 实现 task.Finder，实例无法连接、集群槽位未覆盖、节点下线、哨兵无法达成 quorum 等问题
+func (redis *Redis) Findings() []notify.Finding {
+	var findings []notify.Finding
+	if len(redis.Instances) == 0 {
+		return append(findings, notify.Finding{Key: "unconfigured", Severity: notify.SeverityCritical, Message: "未配置 Redis 实例"})
+	}
+	for _, instance := range redis.Instances {
+		findings = append(findings, instance.findings()...)
+	}
+	return findings
+}
+
+func (instance *Instance) findings() []notify.Finding {
+	var findings []notify.Finding
+	add := func(severity notify.Severity, key, message string) {
+		findings = append(findings, notify.Finding{Key: instance.Name + ":" + key, Severity: severity, Message: instance.Name + " " + message})
+	}
+
+	if instance.Error != "" {
+		add(notify.SeverityCritical, "unreachable", "巡检失败: "+instance.Error)
+	}
+	if instance.MaxClients > 0 && instance.CurrentClients*100 > instance.MaxClients*80 {
+		add(notify.SeverityWarning, "clients", fmt.Sprintf("连接数过高: %d/%d", instance.CurrentClients, instance.MaxClients))
+	}
+
+	if cluster := instance.Cluster; cluster != nil && cluster.State != "" {
+		if cluster.State != "ok" {
+			add(notify.SeverityCritical, "cluster_state", "集群状态异常: "+cluster.State)
+		}
+		if cluster.UncoveredSlots > 0 {
+			add(notify.SeverityCritical, "cluster_slots", fmt.Sprintf("%d 个槽位没有正常的主节点: %s", cluster.UncoveredSlots, strings.Join(cluster.UncoveredRanges, ",")))
+		}
+		for _, node := range cluster.Nodes {
+			switch {
+			case node.Fail:
+				add(notify.SeverityCritical, "node_fail:"+node.Addr, fmt.Sprintf("%s 节点 %s 已下线", node.Role, node.Addr))
+			case node.PFail:
+				add(notify.SeverityWarning, "node_pfail:"+node.Addr, fmt.Sprintf("%s 节点 %s 疑似下线", node.Role, node.Addr))
+			case node.LinkState != "connected":
+				add(notify.SeverityWarning, "node_link:"+node.Addr, fmt.Sprintf("节点 %s 集群总线断开", node.Addr))
+			}
+		}
+	}
+
+	if sentinel := instance.Sentinel; sentinel != nil && sentinel.MasterAddr != "" {
+		if strings.Contains(sentinel.MasterFlags, "o_down") || strings.Contains(sentinel.MasterFlags, "s_down") {
+			add(notify.SeverityCritical, "master_down", fmt.Sprintf("主节点 %s 下线: %s", sentinel.MasterAddr, sentinel.MasterFlags))
+		}
+		if !sentinel.QuorumOK {
+			add(notify.SeverityCritical, "quorum", "哨兵无法达成 quorum: "+sentinel.QuorumMsg)
+		}
+		if len(sentinel.Replicas) == 0 {
+			add(notify.SeverityWarning, "no_replica", "主节点没有从节点")
+		}
+		for _, replica := range sentinel.Replicas {
+			if strings.Contains(replica.Flags, "s_down") || replica.LinkStatus != "ok" {
+				add(notify.SeverityWarning, "replica:"+replica.Addr, fmt.Sprintf("从节点 %s 异常: %s, 主从链路 %s", replica.Addr, replica.Flags, replica.LinkStatus))
+			}
+		}
+	}
+
+	for _, server := range instance.Servers {
+		findings = append(findings, server.findings(instance.Name)...)
+	}
+	if instance.Scan != nil {
+		findings = append(findings, instance.Scan.findings(instance.Name)...)
+	}
+	return findings
+}
+
+// renderServers 各节点的内存、持久化和复制状态，以及慢查询和延迟事件
+func (redis *Redis) renderServers() {
+	out := task.GetOutputWriter()
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"实例", "节点", "角色", "内存/上限", "碎片率", "淘汰键", "拒绝连接", "RDB", "AOF", "上次保存", "主从链路"})
+	var slowRows, latencyRows [][]string
+	for _, instance := range redis.Instances {
+		for _, server := range instance.Servers {
+			limit := "不限"
+			if server.MaxMemory > 0 {
+				limit = fmt.Sprintf("%s (%.1f%%)", formatMemory(server.MaxMemory), server.MemoryPercent())
+			}
+			aof := "关闭"
+			if server.AOFEnabled {
+				aof = server.AOFStatus
+			}
+			lastSave := "-"
+			if !server.LastSave.IsZero() {
+				lastSave = server.LastSave.Format("01-02 15:04")
+			}
+			link := server.MasterLinkStatus
+			if server.Role == "master" {
+				link = replicaSummary(server.Replicas)
+			}
+			table.Append([]string{
+				instance.Name,
+				server.Addr,
+				server.Role,
+				formatMemory(server.UsedMemory) + "/" + limit,
+				strconv.FormatFloat(server.FragRatio, 'f', 2, 64),
+				strconv.FormatInt(server.EvictedKeys, 10),
+				strconv.FormatInt(server.RejectedConns, 10),
+				server.RDBStatus,
+				aof,
+				lastSave,
+				link,
+			})
+			for _, log := range server.SlowLogs {
+				slowRows = append(slowRows, []string{server.Addr, log.Time.Format("01-02 15:04:05"), log.Duration.Round(time.Microsecond).String(), log.Command})
+			}
+			for _, event := range server.Latency {
+				latencyRows = append(latencyRows, []string{server.Addr, event.Event, event.Time.Format("01-02 15:04:05"), strconv.FormatInt(event.Latest, 10), strconv.FormatInt(event.Max, 10)})
+			}
+		}
+	}
+	table.Render()
+
+	if len(slowRows) > 0 {
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"节点", "时间", "耗时", "命令"})
+		table.AppendBulk(slowRows)
+		table.SetCaption(true, "慢查询 SLOWLOG GET")
+		table.Render()
+	}
+	if len(latencyRows) > 0 {
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"节点", "事件", "时间", "最近(ms)", "最大(ms)"})
+		table.AppendBulk(latencyRows)
+		table.SetCaption(true, "延迟事件 LATENCY LATEST")
+		table.Render()
+	}
+}
+
+// replicaSummary 主节点的从节点复制延迟
+func replicaSummary(replicas []ReplicaLag) string {
+	if len(replicas) == 0 {
+		return "-"
+	}
+	var parts []string
+	for _, replica := range replicas {
+		parts = append(parts, fmt.Sprintf("%s %s 延迟 %s", replica.Addr, replica.State, formatMemory(replica.LagBytes)))
+	}
+	return strings.Join(parts, "\n")
+}
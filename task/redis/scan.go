@@ -0,0 +1,501 @@
+// Package redis @Author lanpang
+// @Date 2025/8/18 上午10:00:00
+// @Desc 大键、热键扫描：限速 SCAN 全部键，采样内存占用，按前缀汇总，LFU 策略下统计访问频率
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+	"vhagar/notify"
+	"vhagar/task"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/olekukonko/tablewriter"
+)
+
+// 扫描默认参数
+const (
+	defaultScanCount   = 500
+	defaultScanRate    = 2000
+	defaultScanSamples = 5
+	defaultScanTopN    = 20
+	defaultScanTimeout = 30 * time.Minute
+	noPrefix           = "(无前缀)"
+	// 大键阈值
+	bigKeyBytes  = 10 * 1024 * 1024
+	bigKeyLength = 10000
+)
+
+// KeyScan 一个实例的扫描结果
+type KeyScan struct {
+	Scanned    int
+	TotalBytes int64
+	Duration   string
+	LFU        bool   // 淘汰策略为 LFU 时才能统计热键
+	Truncated  bool   // 达到 limit 后停止
+	Error      string `json:",omitempty"`
+	TopKeys    []KeyStat
+	Prefixes   []PrefixStat
+	HotKeys    []KeyStat `json:",omitempty"`
+}
+
+type KeyStat struct {
+	Key    string
+	Type   string
+	Bytes  int64
+	Length int64 // 字符串为字节数，集合类型为元素个数
+	Freq   int64 `json:",omitempty"` // OBJECT FREQ，对数计数
+	Node   string
+	DB     int
+}
+
+type PrefixStat struct {
+	Prefix  string
+	Keys    int
+	Bytes   int64
+	Percent float64
+}
+
+// scanNode 一个节点（或单机的一个库）
+type scanNode struct {
+	client *goredis.Client
+	db     int
+	lfu    bool
+}
+
+// keyScanner 合并多个节点的扫描结果
+type keyScanner struct {
+	cfg      config.RedisScanCfg
+	base     libs.RedisConfig // 实例的连接配置，地址和库号按节点替换
+	mu       sync.Mutex
+	scan     *KeyScan
+	top      []KeyStat
+	hot      []KeyStat
+	prefixes map[string]*PrefixStat
+}
+
+func newKeyScanner(cfg config.RedisScanCfg, base libs.RedisConfig) *keyScanner {
+	if cfg.Count <= 0 {
+		cfg.Count = defaultScanCount
+	}
+	if cfg.Rate <= 0 {
+		cfg.Rate = defaultScanRate
+	}
+	if cfg.Samples <= 0 {
+		cfg.Samples = defaultScanSamples
+	}
+	if cfg.TopN <= 0 {
+		cfg.TopN = defaultScanTopN
+	}
+	if cfg.Separator == "" {
+		cfg.Separator = ":"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultScanTimeout
+	}
+	return &keyScanner{cfg: cfg, base: base, scan: &KeyScan{}, prefixes: map[string]*PrefixStat{}}
+}
+
+// scanKeys 扫描实例的所有键。cluster 扫描各主节点，sentinel 优先扫描从节点以减少对主节点的影响
+func (redis *Redis) scanKeys(instance *Instance, cfg libs.RedisConfig) {
+	scanner := newKeyScanner(redis.Config.Redis.Scan, cfg)
+	instance.Scan = scanner.scan
+	ctx, cancel := context.WithTimeout(context.Background(), scanner.cfg.Timeout)
+	defer cancel()
+	start := time.Now()
+
+	nodes, closeNodes, err := instance.scanNodes(ctx, cfg)
+	defer closeNodes()
+	if err == nil {
+		var wg sync.WaitGroup
+		errs := make(chan error, len(nodes))
+		for _, node := range nodes {
+			wg.Add(1)
+			go func(node scanNode) {
+				defer wg.Done()
+				if err := scanner.scanNode(ctx, node); err != nil {
+					errs <- fmt.Errorf("%s db%d: %w", node.client.Options().Addr, node.db, err)
+				}
+			}(node)
+		}
+		wg.Wait()
+		close(errs)
+		var messages []string
+		for err := range errs {
+			messages = append(messages, err.Error())
+		}
+		if len(messages) > 0 {
+			err = fmt.Errorf("%s", strings.Join(messages, "; "))
+		}
+	}
+	if err != nil {
+		redis.Logger.Errorw("Redis 键扫描失败", "instance", instance.Name, "err", err)
+		scanner.scan.Error = err.Error()
+	}
+	scanner.finish(ctx)
+	scanner.scan.Duration = time.Since(start).Round(time.Second).String()
+}
+
+// scanNodes 需要扫描的节点，返回的 closeNodes 关闭连接
+func (instance *Instance) scanNodes(ctx context.Context, cfg libs.RedisConfig) ([]scanNode, func(), error) {
+	var clients []*goredis.Client
+	closeNodes := func() {
+		for _, client := range clients {
+			_ = client.Close()
+		}
+	}
+	lfu := map[string]bool{}
+	for _, server := range instance.Servers {
+		lfu[server.Addr] = strings.Contains(server.MaxMemoryPolicy, "lfu")
+	}
+	var nodes []scanNode
+	add := func(addr string, db int) error {
+		client, err := libs.NewRedisClient(nodeConfig(cfg, addr, db))
+		if err != nil {
+			return err
+		}
+		clients = append(clients, client)
+		nodes = append(nodes, scanNode{client: client, db: db, lfu: lfu[addr]})
+		return nil
+	}
+
+	switch instance.Mode {
+	case libs.RedisCluster:
+		for _, server := range instance.Servers {
+			if server.Role != "master" {
+				continue
+			}
+			if err := add(server.Addr, 0); err != nil {
+				return nodes, closeNodes, err
+			}
+		}
+	case libs.RedisSentinel:
+		if instance.Sentinel == nil {
+			return nodes, closeNodes, fmt.Errorf("未获取到主从拓扑")
+		}
+		addr := instance.Sentinel.MasterAddr
+		for _, replica := range instance.Sentinel.Replicas {
+			if replica.LinkStatus == "ok" && !strings.Contains(replica.Flags, "down") {
+				addr = replica.Addr
+				// 从节点和主节点的淘汰策略一致
+				lfu[addr] = lfu[instance.Sentinel.MasterAddr]
+				break
+			}
+		}
+		for _, db := range instance.DBs {
+			if err := add(addr, db); err != nil {
+				return nodes, closeNodes, err
+			}
+		}
+	default:
+		for _, db := range instance.DBs {
+			if err := add(cfg.Addr, db); err != nil {
+				return nodes, closeNodes, err
+			}
+		}
+	}
+	return nodes, closeNodes, nil
+}
+
+// scanNode 限速扫描一个节点：每批 SCAN 的键通过 pipeline 查询类型、内存和访问频率
+func (s *keyScanner) scanNode(ctx context.Context, node scanNode) error {
+	addr := node.client.Options().Addr
+	var cursor uint64
+	scanned := 0
+	for {
+		batchStart := time.Now()
+		keys, next, err := node.client.Scan(ctx, cursor, "", s.cfg.Count).Result()
+		if err != nil {
+			return err
+		}
+		if s.cfg.Limit > 0 && scanned+len(keys) > s.cfg.Limit {
+			keys = keys[:s.cfg.Limit-scanned]
+		}
+		if len(keys) > 0 {
+			stats, err := s.inspectKeys(ctx, node, keys)
+			if err != nil {
+				return err
+			}
+			for i := range stats {
+				stats[i].Node = addr
+				stats[i].DB = node.db
+			}
+			s.add(stats, node.lfu)
+			scanned += len(keys)
+		}
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+		if s.cfg.Limit > 0 && scanned >= s.cfg.Limit {
+			s.mu.Lock()
+			s.scan.Truncated = true
+			s.mu.Unlock()
+			return nil
+		}
+		// 按每秒键数限速
+		wait := time.Duration(len(keys))*time.Second/time.Duration(s.cfg.Rate) - time.Since(batchStart)
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+func (s *keyScanner) inspectKeys(ctx context.Context, node scanNode, keys []string) ([]KeyStat, error) {
+	pipe := node.client.Pipeline()
+	types := make([]*goredis.StatusCmd, len(keys))
+	sizes := make([]*goredis.IntCmd, len(keys))
+	freqs := make([]*goredis.Cmd, len(keys))
+	for i, key := range keys {
+		types[i] = pipe.Type(ctx, key)
+		sizes[i] = pipe.MemoryUsage(ctx, key, s.cfg.Samples)
+		if node.lfu {
+			freqs[i] = pipe.Do(ctx, "object", "freq", key)
+		}
+	}
+	// 扫描期间键可能过期或被删除，单个命令失败不影响整批
+	if _, err := pipe.Exec(ctx); err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	stats := make([]KeyStat, 0, len(keys))
+	for i, key := range keys {
+		bytes, err := sizes[i].Result()
+		if err != nil {
+			continue
+		}
+		stat := KeyStat{Key: key, Type: types[i].Val(), Bytes: bytes}
+		if freqs[i] != nil {
+			stat.Freq, _ = freqs[i].Int64()
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// add 汇总一批键，只保留较大和较热的候选，避免键很多时占用内存
+func (s *keyScanner) add(stats []KeyStat, lfu bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lfu {
+		s.scan.LFU = true
+	}
+	for _, stat := range stats {
+		s.scan.Scanned++
+		s.scan.TotalBytes += stat.Bytes
+		prefix := keyPrefix(stat.Key, s.cfg.Separator)
+		p, ok := s.prefixes[prefix]
+		if !ok {
+			p = &PrefixStat{Prefix: prefix}
+			s.prefixes[prefix] = p
+		}
+		p.Keys++
+		p.Bytes += stat.Bytes
+	}
+	s.top = keepTop(append(s.top, stats...), s.cfg.TopN, func(a, b KeyStat) bool { return a.Bytes > b.Bytes })
+	if lfu {
+		s.hot = keepTop(append(s.hot, stats...), s.cfg.TopN, func(a, b KeyStat) bool { return a.Freq > b.Freq })
+	}
+}
+
+// keepTop 候选数量超过 n 的 4 倍时排序截断
+func keepTop(stats []KeyStat, n int, less func(a, b KeyStat) bool) []KeyStat {
+	if len(stats) <= 4*n {
+		return stats
+	}
+	sort.Slice(stats, func(i, j int) bool { return less(stats[i], stats[j]) })
+	return append([]KeyStat(nil), stats[:n]...)
+}
+
+// nodeConfig 连接单个节点的配置，cluster 和 sentinel 的节点按单机方式连接
+func nodeConfig(cfg libs.RedisConfig, addr string, db int) libs.RedisConfig {
+	cfg.Mode = libs.RedisStandalone
+	cfg.Addr = addr
+	cfg.DB = db
+	return cfg
+}
+
+func keyPrefix(key, separator string) string {
+	if i := strings.Index(key, separator); i > 0 {
+		return key[:i+len(separator)] + "*"
+	}
+	return noPrefix
+}
+
+// finish 排序输出，并补充大键的元素个数
+func (s *keyScanner) finish(ctx context.Context) {
+	n := s.cfg.TopN
+	sort.Slice(s.top, func(i, j int) bool { return s.top[i].Bytes > s.top[j].Bytes })
+	if len(s.top) > n {
+		s.top = s.top[:n]
+	}
+	sort.Slice(s.hot, func(i, j int) bool { return s.hot[i].Freq > s.hot[j].Freq })
+	if len(s.hot) > n {
+		s.hot = s.hot[:n]
+	}
+	s.scan.TopKeys = s.top
+	s.scan.HotKeys = s.hot
+	s.lengths(ctx)
+
+	for _, p := range s.prefixes {
+		if s.scan.TotalBytes > 0 {
+			p.Percent = float64(p.Bytes) / float64(s.scan.TotalBytes) * 100
+		}
+		s.scan.Prefixes = append(s.scan.Prefixes, *p)
+	}
+	sort.Slice(s.scan.Prefixes, func(i, j int) bool { return s.scan.Prefixes[i].Bytes > s.scan.Prefixes[j].Bytes })
+	if len(s.scan.Prefixes) > n {
+		s.scan.Prefixes = s.scan.Prefixes[:n]
+	}
+}
+
+// lengths 按类型查询大键的长度
+func (s *keyScanner) lengths(ctx context.Context) {
+	clients := map[string]*goredis.Client{}
+	defer func() {
+		for _, client := range clients {
+			_ = client.Close()
+		}
+	}()
+	for i := range s.scan.TopKeys {
+		stat := &s.scan.TopKeys[i]
+		id := fmt.Sprintf("%s/%d", stat.Node, stat.DB)
+		client, ok := clients[id]
+		if !ok {
+			var err error
+			if client, err = libs.NewRedisClient(nodeConfig(s.base, stat.Node, stat.DB)); err != nil {
+				continue
+			}
+			clients[id] = client
+		}
+		var cmd *goredis.IntCmd
+		switch stat.Type {
+		case "string":
+			cmd = client.StrLen(ctx, stat.Key)
+		case "list":
+			cmd = client.LLen(ctx, stat.Key)
+		case "hash":
+			cmd = client.HLen(ctx, stat.Key)
+		case "set":
+			cmd = client.SCard(ctx, stat.Key)
+		case "zset":
+			cmd = client.ZCard(ctx, stat.Key)
+		case "stream":
+			cmd = client.XLen(ctx, stat.Key)
+		default:
+			continue
+		}
+		stat.Length, _ = cmd.Result()
+	}
+}
+
+// renderScans 大键、前缀汇总和热键表格
+func (redis *Redis) renderScans() {
+	out := task.GetOutputWriter()
+	for _, instance := range redis.Instances {
+		scan := instance.Scan
+		if scan == nil {
+			continue
+		}
+		caption := fmt.Sprintf("%s 已扫描 %d 个键，共 %s，耗时 %s", instance.Name, scan.Scanned, formatMemory(scan.TotalBytes), scan.Duration)
+		if scan.Truncated {
+			caption += "，达到扫描上限未扫描完"
+		}
+		if scan.Error != "" {
+			caption += "，扫描失败: " + scan.Error
+		}
+
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"键", "类型", "内存", "长度", "节点", "库"})
+		for _, key := range scan.TopKeys {
+			table.Append([]string{key.Key, key.Type, formatMemory(key.Bytes), strconv.FormatInt(key.Length, 10), key.Node, strconv.Itoa(key.DB)})
+		}
+		table.SetCaption(true, "大键 "+caption)
+		table.Render()
+
+		table = tablewriter.NewWriter(out)
+		table.SetHeader([]string{"前缀", "键数量", "内存", "占比"})
+		for _, prefix := range scan.Prefixes {
+			table.Append([]string{prefix.Prefix, strconv.Itoa(prefix.Keys), formatMemory(prefix.Bytes), fmt.Sprintf("%.1f%%", prefix.Percent)})
+		}
+		table.SetCaption(true, instance.Name+" 键前缀汇总")
+		table.Render()
+
+		if !scan.LFU {
+			fmt.Fprintf(out, "%s 淘汰策略不是 LFU，无法统计热键\n", instance.Name)
+			continue
+		}
+		table = tablewriter.NewWriter(out)
+		table.SetHeader([]string{"键", "类型", "访问频率", "内存", "节点", "库"})
+		for _, key := range scan.HotKeys {
+			table.Append([]string{key.Key, key.Type, strconv.FormatInt(key.Freq, 10), formatMemory(key.Bytes), key.Node, strconv.Itoa(key.DB)})
+		}
+		table.SetCaption(true, instance.Name+" 热键 OBJECT FREQ")
+		table.Render()
+	}
+}
+
+// report 机器人消息中的扫描结果，只列出前 5 个大键、前缀和热键
+func (scan *KeyScan) report(builder *strings.Builder) {
+	const limit = 5
+	builder.WriteString(fmt.Sprintf("**键扫描：**<font color='info'>%d 个键，%s，耗时 %s</font>\n", scan.Scanned, formatMemory(scan.TotalBytes), scan.Duration))
+	if scan.Error != "" {
+		builder.WriteString("**扫描失败：**<font color='red'>" + scan.Error + "</font>\n")
+	}
+	if len(scan.TopKeys) > 0 {
+		builder.WriteString("**大键：**\n")
+		for i, key := range scan.TopKeys {
+			if i == limit {
+				break
+			}
+			builder.WriteString(fmt.Sprintf("> %s (%s) <font color='warning'>%s</font> 长度 %d\n", key.Key, key.Type, formatMemory(key.Bytes), key.Length))
+		}
+	}
+	if len(scan.Prefixes) > 0 {
+		builder.WriteString("**前缀占比：**\n")
+		for i, prefix := range scan.Prefixes {
+			if i == limit {
+				break
+			}
+			builder.WriteString(fmt.Sprintf("> %s <font color='info'>%.1f%%</font> %d 个键 %s\n", prefix.Prefix, prefix.Percent, prefix.Keys, formatMemory(prefix.Bytes)))
+		}
+	}
+	if len(scan.HotKeys) > 0 {
+		builder.WriteString("**热键：**\n")
+		for i, key := range scan.HotKeys {
+			if i == limit {
+				break
+			}
+			builder.WriteString(fmt.Sprintf("> %s (%s) 访问频率 <font color='warning'>%d</font>\n", key.Key, key.Type, key.Freq))
+		}
+	}
+}
+
+// findings 大键：内存超过 10MB 或元素超过 1 万
+func (scan *KeyScan) findings(name string) []notify.Finding {
+	var findings []notify.Finding
+	if scan.Error != "" {
+		findings = append(findings, notify.Finding{Key: name + ":scan", Severity: notify.SeverityInfo, Message: name + " 键扫描失败: " + scan.Error})
+	}
+	for _, key := range scan.TopKeys {
+		if key.Bytes < bigKeyBytes && (key.Type == "string" || key.Length < bigKeyLength) {
+			continue
+		}
+		findings = append(findings, notify.Finding{
+			Key:      fmt.Sprintf("%s:bigkey:%s/%d:%s", name, key.Node, key.DB, key.Key),
+			Severity: notify.SeverityWarning,
+			Message:  fmt.Sprintf("%s 大键 %s (%s, db%d) 占用 %s，长度 %d", name, key.Key, key.Type, key.DB, formatMemory(key.Bytes), key.Length),
+		})
+	}
+	return findings
+}
@@ -0,0 +1,55 @@
+// Package task @Author lanpang
+// @Date 2025/7/28 上午11:05:00
+// @Desc
+package task
+
+import (
+	"encoding/json"
+	"time"
+	"vhagar/notify"
+)
+
+// 运行状态
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusError   = "error"
+)
+
+// Result 单次巡检的运行结果
+type Result struct {
+	ID         string             `json:"id"`
+	Task       string             `json:"task"`
+	Profile    string             `json:"profile,omitempty"` // 运行时使用的环境
+	Status     string             `json:"status"`
+	Severity   notify.Severity    `json:"severity"` // 本次运行的最高告警级别
+	StartTime  time.Time          `json:"startTime"`
+	EndTime    time.Time          `json:"endTime"`
+	Error      string             `json:"error,omitempty"`
+	Findings   []notify.Finding   `json:"findings,omitempty"`
+	Reports    []string           `json:"reports,omitempty"` // 汇总模式下暂存的机器人报告
+	Deliveries []*notify.Delivery `json:"deliveries,omitempty"`
+	Output     string             `json:"output,omitempty"` // 表格输出
+	Data       json.RawMessage    `json:"data,omitempty"`   // 任务采集到的结构化数据
+}
+
+// maxSeverity 问题列表中的最高告警级别，没有问题时为 info
+func maxSeverity(findings []notify.Finding) notify.Severity {
+	severity := notify.SeverityInfo
+	for _, finding := range findings {
+		if finding.Severity.Level() > severity.Level() {
+			severity = finding.Severity
+		}
+	}
+	return severity
+}
+
+// DeliveryFailed 是否有机器人消息投递失败。投递是异步的，排队中的消息不算失败
+func (r *Result) DeliveryFailed() bool {
+	for _, delivery := range r.Deliveries {
+		if delivery.Failed() {
+			return true
+		}
+	}
+	return false
+}
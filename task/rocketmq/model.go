@@ -21,9 +21,9 @@ func NewRocketMQ(cfg *config.CfgType, logger *zap.SugaredLogger) *RocketMQ {
 }
 
 type RocketMQ struct {
-	Config      *config.CfgType
-	Logger      *zap.SugaredLogger
-	RocketMQCfg config.RocketMQCfg
+	Config      *config.CfgType    `json:"-"`
+	Logger      *zap.SugaredLogger `json:"-"`
+	RocketMQCfg config.RocketMQCfg `json:"-"`
 	BrokerMap   map[string]*BrokerDetail
 }
 
@@ -25,8 +25,8 @@ const (
 )
 
 func init() {
-	task.Add(taskName, func() task.Tasker {
-		return NewRocketMQ(config.Config, libs.Logger)
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewRocketMQ(cfg, libs.Logger)
 	})
 }
 
@@ -45,7 +45,7 @@ func (rocketmq *RocketMQ) ReportRobot() {
 
 	// 组装巡检内容
 	builder.WriteString("# RocketMQ 巡检 \n")
-	builder.WriteString("**项目名称：**<font color='info'>" + config.Config.ProjectName + "</font>\n")
+	builder.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
 	builder.WriteString("**巡检时间：**<font color='info'>" + time.Now().Format("2006-01-02") + "</font>\n")
 	builder.WriteString("**巡检内容：**\n\n")
 	builder.WriteString("**Broker 健康数：**<font color='info'>" + strconv.Itoa(len(brokerList)) + "</font>\n")
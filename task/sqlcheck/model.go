@@ -0,0 +1,55 @@
+// Package sqlcheck @Author lanpang
+// @Date 2025/8/25 上午10:00:00
+// @Desc
+package sqlcheck
+
+import (
+	"time"
+	"vhagar/config"
+	"vhagar/notify"
+
+	"go.uber.org/zap"
+)
+
+const taskName = "sqlcheck"
+
+// 检查结果状态
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusError   = "error"   // 连接或执行 SQL 失败
+	StatusSkipped = "skipped" // 7 天平均值的历史样本不足
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	avgWindow      = 7 * 24 * time.Hour
+	minAvgSamples  = 3 // 历史样本少于该次数时不与平均值比较
+)
+
+type SQLCheck struct {
+	Config  *config.CfgType    `json:"-"`
+	Logger  *zap.SugaredLogger `json:"-"`
+	Results []*CheckResult
+}
+
+// CheckResult 一项检查的结果
+type CheckResult struct {
+	Name       string
+	Datasource string
+	Condition  string
+	Severity   notify.Severity
+	Status     string
+	Rows       int
+	Value      *float64 `json:",omitempty"` // 第一行第一列，非数字时为空
+	Average    *float64 `json:",omitempty"` // 7 天平均值，仅 avg7d 条件
+	Message    string   `json:",omitempty"` // 未通过的原因或错误信息
+	Duration   time.Duration
+}
+
+func NewSQLCheck(cfg *config.CfgType, logger *zap.SugaredLogger) *SQLCheck {
+	return &SQLCheck{
+		Config: cfg,
+		Logger: logger,
+	}
+}
@@ -0,0 +1,425 @@
+// Package sqlcheck @Author lanpang
+// @Date 2025/8/25 上午10:00:00
+// @Desc 配置定义的 SQL 检查，新增业务数据检查只需修改配置
+package sqlcheck
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+	"vhagar/notify"
+	"vhagar/task"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	timeLayout = "2006-01-02 15:04:05"
+)
+
+func init() {
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewSQLCheck(cfg, libs.Logger)
+	})
+}
+
+func (check *SQLCheck) Name() string {
+	return taskName
+}
+
+func (check *SQLCheck) Check() {
+	if check.Config.Report {
+		check.ReportRobot()
+		return
+	}
+	check.TableRender()
+}
+
+// placeholders SQL 中的日期占位符，日期为零点
+func placeholders(now time.Time) *strings.Replacer {
+	today := task.GetZeroTime(now)
+	yesterday := today.AddDate(0, 0, -1)
+	return strings.NewReplacer(
+		"{{today}}", today.Format(dateLayout),
+		"{{yesterday}}", yesterday.Format(dateLayout),
+		"{{today_time}}", today.Format(timeLayout),
+		"{{yesterday_time}}", yesterday.Format(timeLayout),
+		"{{yesterday_ymd}}", yesterday.Format("20060102"),
+		"{{now}}", now.Format(timeLayout),
+	)
+}
+
+// connections 同一数据源和库的检查共用连接，连接失败后不再重试
+type connections struct {
+	cfg    *config.CfgType
+	mysql  map[string]*sql.DB
+	pg     map[string]*pgx.Conn
+	failed map[string]error
+}
+
+func (c *connections) mysqlDB(source, database string) (*sql.DB, error) {
+	key := source + "/" + database
+	if db, ok := c.mysql[key]; ok {
+		return db, nil
+	}
+	if err, ok := c.failed[key]; ok {
+		return nil, err
+	}
+	conf := c.cfg.Doris.DB
+	if source == config.SourceMySQL {
+		conf = c.cfg.SQLCheck.MySQL
+	}
+	db, err := libs.NewMysqlClient(conf, database)
+	if err != nil {
+		c.failed[key] = err
+		return nil, err
+	}
+	c.mysql[key] = db
+	return db, nil
+}
+
+func (c *connections) pgConn(database string) (*pgx.Conn, error) {
+	key := config.SourcePG + "/" + database
+	if conn, ok := c.pg[database]; ok {
+		return conn, nil
+	}
+	if err, ok := c.failed[key]; ok {
+		return nil, err
+	}
+	conn, err := libs.NewPGClient(c.cfg.PG, database)
+	if err != nil {
+		c.failed[key] = err
+		return nil, err
+	}
+	c.pg[database] = conn
+	return conn, nil
+}
+
+func (c *connections) close() {
+	for _, db := range c.mysql {
+		_ = db.Close()
+	}
+	for _, conn := range c.pg {
+		_ = conn.Close(context.Background())
+	}
+}
+
+// query 执行 SQL，返回结果行数和第一行第一列
+func (c *connections) query(ctx context.Context, item config.SQLCheck, query string) (int, string, error) {
+	database := item.Database
+	switch item.Datasource {
+	case config.SourceDoris, config.SourceMySQL:
+		if database == "" && item.Datasource == config.SourceDoris {
+			database = "wshoto"
+		}
+		db, err := c.mysqlDB(item.Datasource, database)
+		if err != nil {
+			return 0, "", err
+		}
+		return queryMysql(ctx, db, query)
+	case config.SourcePG:
+		if database == "" {
+			database = "qv30"
+		}
+		conn, err := c.pgConn(database)
+		if err != nil {
+			return 0, "", err
+		}
+		return queryPG(ctx, conn, query)
+	}
+	return 0, "", fmt.Errorf("不支持的数据源 %q", item.Datasource)
+}
+
+func queryMysql(ctx context.Context, db *sql.DB, query string) (int, string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, "", err
+	}
+	count, first := 0, ""
+	for rows.Next() {
+		if count == 0 && len(columns) > 0 {
+			values := make([]sql.NullString, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return 0, "", err
+			}
+			first = values[0].String
+		}
+		count++
+	}
+	return count, first, rows.Err()
+}
+
+func queryPG(ctx context.Context, conn *pgx.Conn, query string) (int, string, error) {
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+	count, first := 0, ""
+	for rows.Next() {
+		if count == 0 {
+			values, err := rows.Values()
+			if err != nil {
+				return 0, "", err
+			}
+			if len(values) > 0 {
+				first = pgText(values[0])
+			}
+		}
+		count++
+	}
+	return count, first, rows.Err()
+}
+
+// pgText 数值类型转为文本，numeric 按浮点数处理
+func pgText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case pgtype.Numeric:
+		f, err := v.Float64Value()
+		if err != nil || !f.Valid {
+			return ""
+		}
+		return strconv.FormatFloat(f.Float64, 'f', -1, 64)
+	case time.Time:
+		return v.Format(timeLayout)
+	}
+	return fmt.Sprint(value)
+}
+
+func (check *SQLCheck) Gather() {
+	cfg := check.Config.SQLCheck
+	if len(cfg.Checks) == 0 {
+		check.Logger.Infow("未配置 SQL 检查，跳过", "task", taskName)
+		return
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	conns := &connections{cfg: check.Config, mysql: map[string]*sql.DB{}, pg: map[string]*pgx.Conn{}, failed: map[string]error{}}
+	defer conns.close()
+
+	now := time.Now()
+	replacer := placeholders(now)
+	averages := check.history(now)
+	for _, item := range cfg.Checks {
+		result := &CheckResult{
+			Name:       item.Name,
+			Datasource: item.Datasource,
+			Condition:  item.Condition,
+			Severity:   notify.Severity(item.Severity),
+		}
+		if result.Severity == "" {
+			result.Severity = notify.SeverityWarning
+		}
+		check.Results = append(check.Results, result)
+
+		condition, err := config.ParseCondition(item.Condition)
+		if err != nil {
+			result.Status, result.Message = StatusError, err.Error()
+			continue
+		}
+		query := replacer.Replace(item.SQL)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		rows, first, err := conns.query(ctx, item, query)
+		cancel()
+		result.Duration = time.Since(start)
+		if err != nil {
+			check.Logger.Errorw("SQL 检查执行失败", "name", item.Name, "sql", query, "err", err)
+			result.Status, result.Message = StatusError, err.Error()
+			continue
+		}
+		result.Rows = rows
+		if value, err := strconv.ParseFloat(strings.TrimSpace(first), 64); err == nil {
+			result.Value = &value
+		}
+		evaluate(result, condition, averages[item.Name])
+	}
+}
+
+// history 同一环境最近 7 天各检查项的 value
+func (check *SQLCheck) history(now time.Time) map[string][]float64 {
+	values := map[string][]float64{}
+	for _, run := range task.Runs.List(taskName, now.Add(-avgWindow)) {
+		if run.Status != task.StatusDone || len(run.Data) == 0 || run.Profile != check.Config.Profile {
+			continue
+		}
+		var history SQLCheck
+		if err := json.Unmarshal(run.Data, &history); err != nil {
+			continue
+		}
+		for _, result := range history.Results {
+			if result.Value != nil && result.Status != StatusError {
+				values[result.Name] = append(values[result.Name], *result.Value)
+			}
+		}
+	}
+	return values
+}
+
+// evaluate 按条件判断结果，history 为 avg7d 条件使用的历史样本
+func evaluate(result *CheckResult, condition config.Condition, history []float64) {
+	result.Status = StatusPassed
+	switch condition.Subject {
+	case "rows":
+		if !condition.Compare(float64(result.Rows)) {
+			result.Status = StatusFailed
+			result.Message = fmt.Sprintf("结果行数 %d，期望 %s", result.Rows, result.Condition)
+		}
+		return
+	}
+	if result.Value == nil {
+		result.Status = StatusFailed
+		result.Message = "第一行第一列不是数字或没有结果"
+		return
+	}
+	value := *result.Value
+	if condition.Subject == "value" {
+		if !condition.Compare(value) {
+			result.Status = StatusFailed
+			result.Message = fmt.Sprintf("值为 %s，期望 %s", formatValue(value), result.Condition)
+		}
+		return
+	}
+
+	if len(history) < minAvgSamples {
+		result.Status = StatusSkipped
+		result.Message = fmt.Sprintf("最近 7 天只有 %d 次记录，至少 %d 次后与平均值比较", len(history), minAvgSamples)
+		return
+	}
+	sum := 0.0
+	for _, v := range history {
+		sum += v
+	}
+	average := sum / float64(len(history))
+	result.Average = &average
+	if average == 0 {
+		if value != 0 {
+			result.Status = StatusFailed
+			result.Message = fmt.Sprintf("值为 %s，7 天平均值为 0", formatValue(value))
+		}
+		return
+	}
+	deviation := math.Abs(value-average) / math.Abs(average) * 100
+	if deviation > condition.Value {
+		result.Status = StatusFailed
+		result.Message = fmt.Sprintf("值为 %s，偏离 7 天平均值 %s 达 %.1f%%，期望不超过 %s%%",
+			formatValue(value), formatValue(average), deviation, formatValue(condition.Value))
+	}
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (check *SQLCheck) TableRender() {
+	if len(check.Results) == 0 {
+		return
+	}
+	table := tablewriter.NewWriter(task.GetOutputWriter())
+	table.SetHeader([]string{"名称", "数据源", "条件", "行数", "值", "7天平均", "结果", "耗时", "说明"})
+	for _, result := range check.Results {
+		value, average := "-", "-"
+		if result.Value != nil {
+			value = formatValue(*result.Value)
+		}
+		if result.Average != nil {
+			average = strconv.FormatFloat(*result.Average, 'f', 2, 64)
+		}
+		table.Append([]string{
+			result.Name,
+			result.Datasource,
+			result.Condition,
+			strconv.Itoa(result.Rows),
+			value,
+			average,
+			result.Status,
+			result.Duration.Round(time.Millisecond).String(),
+			result.Message,
+		})
+	}
+	table.SetCaption(true, fmt.Sprintf("未通过: %d/%d", len(check.Findings()), len(check.Results)))
+	table.Render()
+}
+
+func (check *SQLCheck) ReportRobot() {
+	if len(check.Results) == 0 {
+		return
+	}
+	findings := check.Findings()
+	var builder strings.Builder
+	builder.WriteString("# SQL 检查 \n")
+	builder.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
+	builder.WriteString("**巡检时间：**<font color='info'>" + time.Now().Format("2006-01-02") + "</font>\n")
+	builder.WriteString("**检查项数：**<font color='info'>" + strconv.Itoa(len(check.Results)) + "</font>\n")
+	builder.WriteString("**未通过数：**<font color='info'>" + strconv.Itoa(len(findings)) + "</font>\n")
+	if len(findings) == 0 {
+		builder.WriteString("\n<font color='info'>**所有检查项均通过**</font>\n")
+	} else {
+		builder.WriteString("==================\n")
+	}
+	severity := notify.SeverityInfo
+	for _, result := range check.Results {
+		if result.Status != StatusFailed && result.Status != StatusError {
+			continue
+		}
+		color := "warning"
+		if result.Severity == notify.SeverityCritical {
+			color = "red"
+		}
+		builder.WriteString(fmt.Sprintf("> <font color='%s'>%s</font>：%s\n", color, result.Name, result.Message))
+		if result.Severity.Level() > severity.Level() {
+			severity = result.Severity
+		}
+	}
+	switch severity {
+	case notify.SeverityCritical:
+		builder.WriteString("\n<font color='red'>**注意！SQL 检查存在严重问题！**</font>" + task.CallUser(notify.Mentions(taskName, severity)))
+	case notify.SeverityWarning:
+		builder.WriteString("\n<font color='warning'>**注意！SQL 检查未通过！**</font>" + task.CallUser(notify.Mentions(taskName, severity)))
+	}
+
+	markdown := &notify.WeChatMarkdown{
+		MsgType: "markdown",
+		Markdown: &notify.Markdown{
+			Content: builder.String(),
+		},
+	}
+	notify.Send(markdown, taskName)
+}
+
+// Findings 实现 task.Finder，未通过和执行失败的检查按配置的级别告警
+func (check *SQLCheck) Findings() []notify.Finding {
+	var findings []notify.Finding
+	for _, result := range check.Results {
+		switch result.Status {
+		case StatusFailed:
+			findings = append(findings, notify.Finding{Key: "check:" + result.Name, Severity: result.Severity, Message: "SQL 检查未通过 " + result.Name + ": " + result.Message})
+		case StatusError:
+			findings = append(findings, notify.Finding{Key: "error:" + result.Name, Severity: result.Severity, Message: "SQL 检查执行失败 " + result.Name + ": " + result.Message})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,57 @@
+package sqlcheck
+
+import (
+	"testing"
+	"time"
+	"vhagar/config"
+)
+
+func TestEvaluate(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	condition := func(text string) config.Condition {
+		c, err := config.ParseCondition(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	tests := []struct {
+		name      string
+		condition string
+		rows      int
+		value     *float64
+		history   []float64
+		want      string
+	}{
+		{"行数满足", "rows == 0", 0, nil, nil, StatusPassed},
+		{"行数不满足", "rows == 0", 3, nil, nil, StatusFailed},
+		{"值满足", "value > 100", 1, value(101), nil, StatusPassed},
+		{"值不满足", "value > 100", 1, value(100), nil, StatusFailed},
+		{"值不是数字", "value > 100", 1, nil, nil, StatusFailed},
+		{"历史样本不足", "value within 20% of avg7d", 1, value(100), []float64{100, 100}, StatusSkipped},
+		{"在平均值范围内", "value within 20% of avg7d", 1, value(119), []float64{90, 100, 110}, StatusPassed},
+		{"偏离平均值", "value within 20% of avg7d", 1, value(121), []float64{90, 100, 110}, StatusFailed},
+		{"低于平均值", "value within 20% of avg7d", 1, value(79), []float64{90, 100, 110}, StatusFailed},
+		{"平均值为 0", "value within 20% of avg7d", 1, value(0), []float64{0, 0, 0}, StatusPassed},
+		{"平均值为 0 且值不为 0", "value within 20% of avg7d", 1, value(1), []float64{0, 0, 0}, StatusFailed},
+	}
+	for _, tt := range tests {
+		result := &CheckResult{Condition: tt.condition, Rows: tt.rows, Value: tt.value}
+		evaluate(result, condition(tt.condition), tt.history)
+		if result.Status != tt.want {
+			t.Errorf("%s: status = %s (%s), want %s", tt.name, result.Status, result.Message, tt.want)
+		}
+		if result.Status == StatusFailed && result.Message == "" {
+			t.Errorf("%s: 未通过时应说明原因", tt.name)
+		}
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	now := time.Date(2025, 3, 1, 8, 30, 15, 0, time.Local)
+	got := placeholders(now).Replace("{{today}} {{yesterday}} {{yesterday_ymd}} '{{today_time}}' '{{yesterday_time}}' '{{now}}'")
+	want := "2025-03-01 2025-02-28 20250228 '2025-03-01 00:00:00' '2025-02-28 00:00:00' '2025-03-01 08:30:15'"
+	if got != want {
+		t.Errorf("placeholders = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,215 @@
+// Package task @Author lanpang
+// @Date 2025/8/4 上午10:20:00
+// @Desc 巡检运行记录：内存保留最近的结果，完成的结果追加写入文件，重启后可继续查询历史
+package task
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+)
+
+const (
+	defaultHistoryFile  = "runs.jsonl"
+	defaultHistoryLimit = 1000
+)
+
+// runStore 运行记录，按开始时间顺序保存
+type runStore struct {
+	mu    sync.Mutex
+	once  sync.Once
+	runs  []*Result
+	index map[string]*Result
+	lines int // 文件中的记录行数，超过保留条数两倍时压缩
+}
+
+// Runs 全局运行记录
+var Runs = &runStore{index: map[string]*Result{}}
+
+func historyFile() string {
+	if file := config.Get().History.File; file != "" {
+		return file
+	}
+	return defaultHistoryFile
+}
+
+func historyLimit() int {
+	if limit := config.Get().History.Limit; limit > 0 {
+		return limit
+	}
+	return defaultHistoryLimit
+}
+
+func newRunID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return time.Now().Format("20060102150405") + "-" + hex.EncodeToString(b)
+}
+
+// load 首次访问时读取历史文件
+func (s *runStore) load() {
+	s.once.Do(func() {
+		file, err := os.Open(historyFile())
+		if err != nil {
+			if !os.IsNotExist(err) {
+				libs.Logger.Errorw("读取运行记录失败", "err", err)
+			}
+			return
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			result := &Result{}
+			if err := json.Unmarshal(scanner.Bytes(), result); err != nil {
+				continue
+			}
+			s.lines++
+			s.put(result)
+		}
+		if err := scanner.Err(); err != nil {
+			libs.Logger.Errorw("读取运行记录失败", "err", err)
+		}
+	})
+}
+
+// put 新增或替换记录，调用方持有锁
+func (s *runStore) put(result *Result) {
+	if _, ok := s.index[result.ID]; ok {
+		for i, run := range s.runs {
+			if run.ID == result.ID {
+				s.runs[i] = result
+				break
+			}
+		}
+	} else {
+		s.runs = append(s.runs, result)
+	}
+	s.index[result.ID] = result
+	if limit := historyLimit(); len(s.runs) > limit {
+		for _, run := range s.runs[:len(s.runs)-limit] {
+			delete(s.index, run.ID)
+		}
+		s.runs = append([]*Result(nil), s.runs[len(s.runs)-limit:]...)
+	}
+}
+
+// add 记录一次开始的运行
+func (s *runStore) add(result *Result) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(result)
+}
+
+// finish 用完成的结果替换运行中的记录并写入文件
+func (s *runStore) finish(result *Result) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(result)
+	data, err := json.Marshal(result)
+	if err != nil {
+		libs.Logger.Errorw("序列化运行记录失败", "id", result.ID, "err", err)
+		return
+	}
+	file, err := os.OpenFile(historyFile(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		libs.Logger.Errorw("写入运行记录失败", "err", err)
+		return
+	}
+	_, err = file.Write(append(data, '\n'))
+	_ = file.Close()
+	if err != nil {
+		libs.Logger.Errorw("写入运行记录失败", "err", err)
+		return
+	}
+	s.lines++
+	if s.lines > 2*historyLimit() {
+		s.compact()
+	}
+}
+
+// compact 只保留内存中已完成的记录重写文件，调用方持有锁
+func (s *runStore) compact() {
+	file := historyFile()
+	tmp, err := os.OpenFile(file+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		libs.Logger.Errorw("压缩运行记录失败", "err", err)
+		return
+	}
+	writer := bufio.NewWriter(tmp)
+	lines := 0
+	for _, run := range s.runs {
+		if run.Status == StatusRunning {
+			continue
+		}
+		data, err := json.Marshal(run)
+		if err != nil {
+			continue
+		}
+		_, _ = writer.Write(append(data, '\n'))
+		lines++
+	}
+	if err := writer.Flush(); err != nil {
+		_ = tmp.Close()
+		libs.Logger.Errorw("压缩运行记录失败", "err", err)
+		return
+	}
+	_ = tmp.Close()
+	if err := os.Rename(file+".tmp", file); err != nil {
+		libs.Logger.Errorw("压缩运行记录失败", "err", err)
+		return
+	}
+	s.lines = lines
+}
+
+// Get 按 ID 查询运行记录
+func (s *runStore) Get(id string) (*Result, bool) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.index[id]
+	return result, ok
+}
+
+// List 按任务和开始时间过滤，task 为空时不限任务，结果按开始时间倒序
+func (s *runStore) List(taskName string, since time.Time) []*Result {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []*Result
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		run := s.runs[i]
+		if taskName != "" && run.Task != taskName {
+			continue
+		}
+		if run.StartTime.Before(since) {
+			continue
+		}
+		list = append(list, run)
+	}
+	return list
+}
+
+// Latest 任务最近一次运行记录
+func (s *runStore) Latest(taskName string) (*Result, bool) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		if s.runs[i].Task == taskName {
+			return s.runs[i], true
+		}
+	}
+	return nil, false
+}
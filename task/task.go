@@ -3,11 +3,20 @@
 // @Desc
 package task
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+	"vhagar/notify"
+)
 
 var Creators = map[string]Creator{}
 
-type Creator func() Tasker
+// Creator 按本次运行的配置创建任务
+type Creator func(cfg *config.CfgType) Tasker
 
 type Tasker interface {
 	Check()
@@ -18,12 +27,17 @@ type Initializer interface {
 	Init() error
 }
 
+// Finder 可选接口，返回本次巡检发现的问题，用于告警升级和运行结果
+type Finder interface {
+	Findings() []notify.Finding
+}
+
 func Add(name string, creator Creator) {
 	Creators[name] = creator
 }
 
-func Get(name string) Tasker {
-	return Creators[name]()
+func Get(name string, cfg *config.CfgType) Tasker {
+	return Creators[name](cfg)
 }
 
 func MayInit(t interface{}) error {
@@ -33,16 +47,105 @@ func MayInit(t interface{}) error {
 	return nil
 }
 
-func Do(name string) {
-	message := fmt.Sprintf("开始巡检 %s 状态信息", name)
+// RunOptions 单次运行参数
+type RunOptions struct {
+	Report    bool // 推送机器人，否则只输出表格
+	Scheduled bool // 定时调度触发，只有定时运行的报告参与汇总、跟踪告警升级
+}
+
+// runMu 任务共用全局配置和输出，同一时间只运行一个任务
+var runMu sync.Mutex
+
+// Do 同步运行任务，是否推送机器人取决于当前配置
+func Do(name string) *Result {
+	return Run(name, RunOptions{Report: config.Get().Report})
+}
+
+// Run 按指定选项同步运行任务
+func Run(name string, opts RunOptions) *Result {
+	return execute(newRun(name), opts)
+}
+
+// Start 异步运行任务，立即返回运行中的记录，完成后可通过 Runs 按 ID 查询
+func Start(name string, opts RunOptions) *Result {
+	result := newRun(name)
+	go execute(result, opts)
+	return result
+}
+
+// Exclusive 等待正在运行的任务结束后执行 fn，期间不会开始新任务，用于配置热加载
+func Exclusive(fn func()) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	fn()
+}
+
+func newRun(name string) *Result {
+	result := &Result{ID: newRunID(), Task: name, Profile: config.Get().Profile, Status: StatusRunning, StartTime: time.Now()}
+	Runs.add(result)
+	return result
+}
+
+// execute 执行任务并生成结果。运行中的记录可能正被 API 读取，因此在副本上填充结果，完成后整体替换
+func execute(running *Result, opts RunOptions) *Result {
+	runMu.Lock()
+	defer runMu.Unlock()
+	// 本次运行使用配置副本，推送选项只作用于本次运行，不修改全局配置
+	cfg := *config.Get()
+	cfg.Report = opts.Report
+
+	result := *running
+	result.StartTime = time.Now()
+	startCapture()
+	defer func() {
+		if err := recover(); err != nil {
+			libs.Logger.Errorw("任务运行异常", "task", result.Task, "err", err)
+			result.Error = fmt.Sprintf("任务运行异常: %v", err)
+		}
+		result.Output = stopCapture()
+		result.EndTime = time.Now()
+		result.Severity = maxSeverity(result.Findings)
+		result.Status = StatusDone
+		if result.Error != "" {
+			result.Status = StatusError
+		}
+		Runs.finish(&result)
+	}()
+
+	message := fmt.Sprintf("开始巡检 %s 状态信息", result.Task)
 	echoPrompt(message)
-	tasker := Get(name)
+	tasker := Get(result.Task, &cfg)
 	err := MayInit(tasker)
 	if err != nil {
-		return
+		result.Error = err.Error()
+		return &result
+	}
+	if opts.Scheduled {
+		notify.BeginHold(result.Task)
+		// 任务异常退出时也要结束暂存，避免之后的手动运行被暂存
+		defer notify.TakeHeld(result.Task)
 	}
 	// 采集数据
 	tasker.Gather()
 	// 检查数据
 	tasker.Check()
+	if finder, ok := tasker.(Finder); ok {
+		result.Findings = finder.Findings()
+		for i := range result.Findings {
+			result.Findings[i].Task = result.Task
+		}
+	}
+	// 只跟踪定时调度的推送，避免手动运行和 API 重新检查时累加次数
+	if opts.Scheduled && opts.Report {
+		notify.Escalate(result.Task, result.Findings)
+	}
+	// 汇总机器人投递记录，投递状态由队列异步更新
+	result.Deliveries = notify.TakeDeliveries(result.Task)
+	result.Reports = notify.TakeHeld(result.Task)
+	if data, err := json.Marshal(tasker); err == nil {
+		result.Data = data
+	} else {
+		libs.Logger.Warnw("序列化巡检数据失败", "task", result.Task, "err", err)
+	}
+	return &result
 }
@@ -17,12 +17,12 @@ var version = config.VERSION
 const taskName = "tenant"
 
 type Tenanter struct {
-	Config *config.CfgType
-	Logger *zap.SugaredLogger
+	Config *config.CfgType    `json:"-"`
+	Logger *zap.SugaredLogger `json:"-"`
 	Corp   []*config.Corp
 	//ESClient *elastic.Client
-	MysqlClient *sql.DB
-	PGClient    *libs.PGClienter
+	MysqlClient *sql.DB          `json:"-"`
+	PGClient    *libs.PGClienter `json:"-"`
 }
 
 func NewTenanter(cfg *config.CfgType, logger *zap.SugaredLogger) *Tenanter {
@@ -23,8 +23,8 @@ import (
 )
 
 func init() {
-	task.Add(taskName, func() task.Tasker {
-		return NewTenanter(config.Config, libs.Logger)
+	task.Add(taskName, func(cfg *config.CfgType) task.Tasker {
+		return NewTenanter(cfg, libs.Logger)
 	})
 }
 
@@ -80,25 +80,25 @@ func (tenant *Tenanter) ReportWshoto() {
 
 func (tenant *Tenanter) Gather() {
 	// 创建ESClient，PGClienter
-	//esClient, err := libs.NewESClient(config.Config.ES)
+	//esClient, err := libs.NewESClient(config.Get().ES)
 	//if err != nil {
 	//	log.Printf("Failed info: %s \n", err)
 	//	return
 	//}
 	// 创建 mysqlClinet，PGCliente
-	mysqlClinet, err := libs.NewMysqlClient(config.Config.Doris.DB, "wshoto")
+	mysqlClinet, err := libs.NewMysqlClient(config.Get().Doris.DB, "wshoto")
 	if err != nil {
 		libs.Logger.Errorw("Failed to create mysql client", "err", err)
 		return
 	}
-	pgClient, err := libs.NewPGClienter(config.Config.PG)
+	pgClient, err := libs.NewPGClienter(config.Get().PG)
 	if err != nil {
 		log.Printf("Failed info: %s \n", err)
 		return
 	}
-	if config.Config.Customer.HasValue() {
+	if config.Get().Customer.HasValue() {
 		libs.Logger.Info("读取新的customer库")
-		conn, err := libs.NewPGClient(config.Config.Customer, "customer")
+		conn, err := libs.NewPGClient(config.Get().Customer, "customer")
 		if err != nil {
 			log.Printf("Failed info: %s \n", err)
 			return
@@ -204,7 +204,7 @@ func headCorpString() string {
 	var builder strings.Builder
 	// 组装巡检内容
 	builder.WriteString("# 每日巡检报告 " + version + "\n")
-	builder.WriteString("**项目名称：**<font color='info'>" + config.Config.ProjectName + "</font>\n")
+	builder.WriteString("**项目名称：**<font color='info'>" + config.Get().ProjectName + "</font>\n")
 	builder.WriteString("**巡检时间：**<font color='info'>" + time.Now().Format("2006-01-02") + "</font>\n")
 	builder.WriteString("**巡检内容：**\n")
 
@@ -0,0 +1,130 @@
+// Package web @Author lanpang
+// @Date 2025/8/4 下午2:00:00
+// @Desc 巡检 REST API：查询任务、异步触发巡检、读取运行结果和历史
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+	"vhagar/config"
+	"vhagar/libs"
+	"vhagar/task"
+)
+
+// TaskInfo 已注册任务及最近一次运行
+type TaskInfo struct {
+	Name    string       `json:"name"`
+	Cron    string       `json:"cron,omitempty"`
+	LastRun *task.Result `json:"lastRun,omitempty"`
+}
+
+// runRequest POST /api/tasks/{name}/run 的请求体，可为空
+type runRequest struct {
+	Report bool `json:"report"` // 推送机器人
+}
+
+// RegisterAPI 在 mux 上注册 /api 路由
+func RegisterAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/tasks", listTasks)
+	mux.HandleFunc("POST /api/tasks/{name}/run", runTask)
+	mux.HandleFunc("GET /api/runs/{id}", getRun)
+	mux.HandleFunc("GET /api/runs", listRuns)
+}
+
+func listTasks(w http.ResponseWriter, r *http.Request) {
+	var tasks []TaskInfo
+	for name := range task.Creators {
+		info := TaskInfo{Name: name, Cron: cronSpec(name)}
+		if result, ok := task.Runs.Latest(name); ok {
+			info.LastRun = result
+		}
+		tasks = append(tasks, info)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func runTask(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, ok := task.Creators[name]; !ok {
+		writeError(w, libs.NewErrorWithDetail(libs.ErrCodeNotFound, "任务不存在", name))
+		return
+	}
+	req := runRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, libs.WrapError(libs.ErrCodeInvalidParam, "请求体格式错误", err))
+			return
+		}
+	}
+	result := task.Start(name, task.RunOptions{Report: req.Report})
+	libs.Logger.Infow("API 触发巡检", "task", name, "id", result.ID, "report", req.Report, "remote", r.RemoteAddr)
+	w.Header().Set("Location", "/api/runs/"+result.ID)
+	writeJSON(w, http.StatusAccepted, result)
+}
+
+func getRun(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	result, ok := task.Runs.Get(id)
+	if !ok {
+		writeError(w, libs.NewErrorWithDetail(libs.ErrCodeNotFound, "运行记录不存在", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// listRuns GET /api/runs?task=es&since=2025-08-01T00:00:00+08:00，since 也支持 24h 这类相对时长
+func listRuns(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	since, err := parseSince(query.Get("since"))
+	if err != nil {
+		writeError(w, libs.WrapError(libs.ErrCodeInvalidParam, "since 参数格式错误", err))
+		return
+	}
+	runs := task.Runs.List(query.Get("task"), since)
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit > 0 && limit < len(runs) {
+		runs = runs[:limit]
+	}
+	if runs == nil {
+		runs = []*task.Result{}
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		libs.Logger.Errorw("响应输出失败", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err *libs.AppError) {
+	writeJSON(w, err.GetHTTPStatus(), err)
+}
+
+// cronSpec 已开启定时的任务返回 cron 表达式
+func cronSpec(name string) string {
+	if cron, ok := config.Get().Cron[name]; ok && cron.Crontab {
+		return cron.Scheducron
+	}
+	return ""
+}
@@ -0,0 +1,20 @@
+// Package web @Author lanpang
+// @Date 2025/8/5 上午10:00:00
+// @Desc 内置巡检面板，页面打包进二进制，客户现场离线可用
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var staticFS embed.FS
+
+// RegisterDashboard 在 mux 上注册巡检面板，首页为 /
+func RegisterDashboard(mux *http.ServeMux) {
+	static, _ := fs.Sub(staticFS, "static")
+	mux.Handle("GET /{$}", http.FileServerFS(static))
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+}